@@ -0,0 +1,131 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Bounds of the delay before StreamActivity reconnects, doubling after
+// each failed attempt. Tests shorten them.
+var (
+	activityRetryMin = 500 * time.Millisecond
+	activityRetryMax = 30 * time.Second
+)
+
+// ActivityEvent : Entry of the server's activity feed. Type is one of the
+// Event constants; DownloadID is 0 for user events.
+type ActivityEvent struct {
+	Type       string    `json:"type"`
+	UserName   string    `json:"user_name"`
+	DownloadID int       `json:"download_id"`
+	Time       time.Time `json:"time"`
+}
+
+// StreamActivity : Function to follow the server's activity feed, sending
+// every event on the first channel. A non-empty userFilter keeps only the
+// events of that user. Dropped connections are reported on the second
+// channel and reopened with a backoff of up to 30 seconds; a server without
+// the feed, refusing it or rejecting the credentials ends the stream with
+// that error instead. Both channels are closed once ctx is cancelled or the
+// client is closed.
+func (s *AdminService) StreamActivity(ctx context.Context, userFilter string) (<-chan ActivityEvent, <-chan error) {
+	events := make(chan ActivityEvent)
+	errs := make(chan error, 1)
+	err := s.client.startWatcher(ctx, func(ctx context.Context) {
+		defer close(events)
+		defer close(errs)
+		s.client.streamActivity(ctx, strings.TrimSpace(userFilter), events, errs)
+	})
+	if err != nil {
+		errs <- err
+		close(events)
+		close(errs)
+	}
+	return events, errs
+}
+
+func (b *Client) streamActivity(ctx context.Context, userFilter string, events chan<- ActivityEvent, errs chan<- error) {
+	query := url.Values{}
+	query.Set("follow", "true")
+	if userFilter != "" {
+		query.Set("user_name", userFilter)
+	}
+	endpoint := "/api/activity?" + query.Encode()
+
+	delay := activityRetryMin
+	for {
+		received, err := b.followActivity(ctx, endpoint, userFilter, events)
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, ErrNotSupported) || errors.Is(err, ErrNotFound) || errors.Is(err, ErrForbidden) || errors.Is(err, ErrNotAuthenticated) {
+			sendError(ctx, errs, err)
+			return
+		}
+		if err != nil && !sendError(ctx, errs, err) {
+			return
+		}
+		if received {
+			delay = activityRetryMin
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return
+		}
+		if delay *= 2; delay > activityRetryMax {
+			delay = activityRetryMax
+		}
+	}
+}
+
+// followActivity : Function to read one connection of the activity feed
+// until it ends, reporting whether any event came through
+func (b *Client) followActivity(ctx context.Context, endpoint string, userFilter string, events chan<- ActivityEvent) (bool, error) {
+	response, err := b.stream(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+
+	received := false
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var event ActivityEvent
+		if err := b.decode([]byte(line), &event); err != nil {
+			return received, err
+		}
+		received = true
+		// Servers may ignore the user_name filter
+		if userFilter != "" && event.UserName != userFilter {
+			continue
+		}
+		select {
+		case events <- event:
+		case <-ctx.Done():
+			return received, ctx.Err()
+		}
+	}
+	return received, scanner.Err()
+}
@@ -0,0 +1,124 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// shortenActivityRetry : Helper function to speed up reconnects for a test
+func shortenActivityRetry(t *testing.T) {
+	min, max := activityRetryMin, activityRetryMax
+	activityRetryMin, activityRetryMax = time.Millisecond, 5*time.Millisecond
+	t.Cleanup(func() { activityRetryMin, activityRetryMax = min, max })
+}
+
+func TestStreamActivity(t *testing.T) {
+	shortenActivityRetry(t)
+	var connections int32
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/activity" || r.URL.Query().Get("user_name") != "rand" || r.URL.Query().Get("follow") != "true" {
+			t.Errorf("unexpected request %s?%s", r.URL.Path, r.URL.RawQuery)
+		}
+		switch atomic.AddInt32(&connections, 1) {
+		case 1:
+			// This server ignores the filter, then drops the connection
+			w.Write([]byte(`{"type": "download.added", "user_name": "rand", "download_id": 3}` + "\n"))
+			w.Write([]byte(`{"type": "download.added", "user_name": "mat", "download_id": 4}` + "\n"))
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte(`{"type": "download.completed", "user_name": "rand", "download_id": 3}` + "\n"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	events, errs := b.Admin.StreamActivity(ctx, " rand ")
+
+	var got []ActivityEvent
+	for len(got) < 2 {
+		select {
+		case event := <-events:
+			got = append(got, event)
+		case err := <-errs:
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("unexpected error %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out with events %+v", got)
+		}
+	}
+	if got[0].Type != EventDownloadAdded || got[0].DownloadID != 3 || got[1].Type != EventDownloadCompleted {
+		t.Errorf("events = %+v", got)
+	}
+
+	cancel()
+	for range events {
+		t.Error("event after cancellation")
+	}
+	for range errs {
+	}
+	if n := atomic.LoadInt32(&connections); n != 3 {
+		t.Errorf("%d connections, want 3", n)
+	}
+}
+
+func TestStreamActivityNotSupported(t *testing.T) {
+	shortenActivityRetry(t)
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotImplemented)
+	}))
+	events, errs := b.Admin.StreamActivity(context.Background(), "")
+	if err := <-errs; !errors.Is(err, ErrNotSupported) {
+		t.Errorf("got %v, want ErrNotSupported", err)
+	}
+	if _, ok := <-events; ok {
+		t.Error("events channel left open")
+	}
+}
+
+func TestStreamActivityUnauthorized(t *testing.T) {
+	shortenActivityRetry(t)
+	var attempts int32
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	events, errs := b.Admin.StreamActivity(context.Background(), "")
+	if err := <-errs; !errors.Is(err, ErrNotAuthenticated) {
+		t.Errorf("got %v, want ErrNotAuthenticated", err)
+	}
+	if _, ok := <-events; ok {
+		t.Error("events channel left open")
+	}
+	if n := atomic.LoadInt32(&attempts); n != 1 {
+		t.Errorf("attempts = %d, want no reconnect", n)
+	}
+}
+
+func TestStreamActivityClosedClient(t *testing.T) {
+	b := newTestClient(t, http.NotFoundHandler())
+	b.Close()
+	_, errs := b.Admin.StreamActivity(context.Background(), "")
+	if err := <-errs; !errors.Is(err, ErrClosed) {
+		t.Errorf("got %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,162 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package analytics
+
+import (
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	".."
+)
+
+// UsageReport : Aggregates over the downloads of a server, for dashboards
+type UsageReport struct {
+	// Daily holds the bytes completed by each user per UTC day, sorted by
+	// day and then user
+	Daily []DailyUsage
+	// FileTypes ranks the file extensions by number of downloads
+	FileTypes []FileTypeUsage
+	// QueueWait describes how long downloads waited before starting, over
+	// those whose start time the server reports
+	QueueWait WaitStats
+	// HeavyUsers is the server's ranking of users by disk space, as given
+	// to AnalyzeUsage
+	HeavyUsers []bassa.HeavyUser
+}
+
+// DailyUsage : Bytes of the downloads a user completed on one day
+type DailyUsage struct {
+	UserName string
+	// Day is midnight UTC of the day
+	Day   time.Time
+	Bytes int64
+	Count int
+}
+
+// FileTypeUsage : Downloads sharing a file extension. Extension is lower
+// case without the dot, empty for names without one such as magnet links.
+type FileTypeUsage struct {
+	Extension string
+	Count     int
+	Bytes     int64
+}
+
+// WaitStats : Distribution of the time downloads spent queued
+type WaitStats struct {
+	Samples int
+	Mean    time.Duration
+	Median  time.Duration
+	Max     time.Duration
+}
+
+// AnalyzeUsage : Function to aggregate a download listing and the ranking
+// of the heaviest users, e.g. from AdminService.UsageData, keeping the
+// topTypes most common file types, all of them if topTypes isn't positive.
+// It makes no request of its own.
+func AnalyzeUsage(downloads []bassa.Download, heavyUsers []bassa.HeavyUser, topTypes int) UsageReport {
+	type userDay struct {
+		userName string
+		day      time.Time
+	}
+	daily := map[userDay]*DailyUsage{}
+	types := map[string]*FileTypeUsage{}
+	var waits []time.Duration
+	for _, download := range downloads {
+		if download.Status == bassa.StatusCompleted && !download.CompletedAt.IsZero() {
+			t := download.CompletedAt.UTC()
+			key := userDay{download.UserName, time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)}
+			if daily[key] == nil {
+				daily[key] = &DailyUsage{UserName: key.userName, Day: key.day}
+			}
+			daily[key].Bytes += download.Size
+			daily[key].Count++
+		}
+
+		ext := fileExtension(download)
+		if types[ext] == nil {
+			types[ext] = &FileTypeUsage{Extension: ext}
+		}
+		types[ext].Count++
+		types[ext].Bytes += download.Size
+
+		if !download.AddedAt.IsZero() && !download.StartedAt.IsZero() && !download.StartedAt.Before(download.AddedAt) {
+			waits = append(waits, download.StartedAt.Sub(download.AddedAt))
+		}
+	}
+
+	var report UsageReport
+	for _, usage := range daily {
+		report.Daily = append(report.Daily, *usage)
+	}
+	sort.Slice(report.Daily, func(i, j int) bool {
+		a, b := report.Daily[i], report.Daily[j]
+		if !a.Day.Equal(b.Day) {
+			return a.Day.Before(b.Day)
+		}
+		return a.UserName < b.UserName
+	})
+	for _, usage := range types {
+		report.FileTypes = append(report.FileTypes, *usage)
+	}
+	sort.Slice(report.FileTypes, func(i, j int) bool {
+		a, b := report.FileTypes[i], report.FileTypes[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Extension < b.Extension
+	})
+	if topTypes > 0 && len(report.FileTypes) > topTypes {
+		report.FileTypes = report.FileTypes[:topTypes]
+	}
+	report.QueueWait = waitStats(waits)
+	report.HeavyUsers = heavyUsers
+	return report
+}
+
+// fileExtension : Helper function to get the file type of a download from
+// its name, or the path of its link for unnamed ones
+func fileExtension(download bassa.Download) string {
+	name := download.Name
+	if name == "" {
+		if u, err := url.Parse(download.Link); err == nil && u.Scheme != "magnet" {
+			name = u.Path
+		}
+	}
+	return strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))
+}
+
+// waitStats : Helper function to describe a set of queue waits
+func waitStats(waits []time.Duration) WaitStats {
+	if len(waits) == 0 {
+		return WaitStats{}
+	}
+	sort.Slice(waits, func(i, j int) bool { return waits[i] < waits[j] })
+	var total time.Duration
+	for _, wait := range waits {
+		total += wait
+	}
+	median := waits[len(waits)/2]
+	if len(waits)%2 == 0 {
+		median = (waits[len(waits)/2-1] + median) / 2
+	}
+	return WaitStats{
+		Samples: len(waits),
+		Mean:    total / time.Duration(len(waits)),
+		Median:  median,
+		Max:     waits[len(waits)-1],
+	}
+}
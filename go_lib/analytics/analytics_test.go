@@ -0,0 +1,60 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package analytics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	".."
+)
+
+func TestAnalyzeUsage(t *testing.T) {
+	day := func(d, h int) time.Time { return time.Date(2026, 5, d, h, 0, 0, 0, time.UTC) }
+	downloads := []bassa.Download{
+		{UserName: "rand", Name: "ubuntu.ISO", Status: bassa.StatusCompleted, Size: 100, AddedAt: day(1, 8), StartedAt: day(1, 9), CompletedAt: day(1, 10)},
+		{UserName: "rand", Name: "debian.iso", Status: bassa.StatusCompleted, Size: 50, AddedAt: day(1, 8), StartedAt: day(1, 11), CompletedAt: day(1, 23)},
+		{UserName: "mat", Link: "http://example.org/files/song.mp3?x=1", Status: bassa.StatusCompleted, Size: 5, CompletedAt: day(2, 1)},
+		{UserName: "mat", Link: "magnet:?xt=urn:btih:abc", Status: bassa.StatusQueued, Size: 7},
+		{UserName: "rand", Name: "arch.iso", Status: bassa.StatusDownloading, Size: 70, AddedAt: day(2, 0), StartedAt: day(2, 5)},
+	}
+	heavy := []bassa.HeavyUser{{UserName: "rand", Size: 220}}
+	report := AnalyzeUsage(downloads, heavy, 2)
+
+	wantDaily := []DailyUsage{
+		{UserName: "rand", Day: day(1, 0), Bytes: 150, Count: 2},
+		{UserName: "mat", Day: day(2, 0), Bytes: 5, Count: 1},
+	}
+	if !reflect.DeepEqual(report.Daily, wantDaily) {
+		t.Errorf("Daily = %+v, want %+v", report.Daily, wantDaily)
+	}
+	wantTypes := []FileTypeUsage{{Extension: "iso", Count: 3, Bytes: 220}, {Extension: "", Count: 1, Bytes: 7}}
+	if !reflect.DeepEqual(report.FileTypes, wantTypes) {
+		t.Errorf("FileTypes = %+v, want %+v", report.FileTypes, wantTypes)
+	}
+	wantWait := WaitStats{Samples: 3, Mean: 3 * time.Hour, Median: 3 * time.Hour, Max: 5 * time.Hour}
+	if report.QueueWait != wantWait {
+		t.Errorf("QueueWait = %+v, want %+v", report.QueueWait, wantWait)
+	}
+	if !reflect.DeepEqual(report.HeavyUsers, heavy) {
+		t.Errorf("HeavyUsers = %+v, want %+v", report.HeavyUsers, heavy)
+	}
+	if all := AnalyzeUsage(downloads, nil, 0); len(all.FileTypes) != 3 {
+		t.Errorf("got %d file types, want all 3", len(all.FileTypes))
+	}
+	if empty := AnalyzeUsage(nil, nil, 5); len(empty.Daily) != 0 || empty.QueueWait != (WaitStats{}) {
+		t.Errorf("empty listing: got %+v", empty)
+	}
+}
@@ -0,0 +1,98 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		want := "actor=rand&category=admin&limit=10&since=2026-01-01T00%3A00%3A00Z&until=2026-02-01T00%3A00%3A00Z"
+		if r.URL.Path != "/api/audit" || r.URL.RawQuery != want {
+			t.Errorf("unexpected request %s?%s", r.URL.Path, r.URL.RawQuery)
+		}
+		w.Write([]byte(`[{"id": 1, "time": "2026-01-05T10:00:00Z", "category": "admin", "action": "block_user",
+			"actor": "rand", "target": "mat", "detail": "spam", "remote_addr": "10.0.0.1"}]`))
+	}))
+	opts := AuditLogOptions{
+		Since:    time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Until:    time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+		Category: AuditAdmin,
+		Actor:    "rand",
+		Limit:    10,
+	}
+	entries, err := b.Admin.AuditLog(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []AuditEntry{{ID: 1, Time: time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC), Category: AuditAdmin, Action: "block_user", Actor: "rand", Target: "mat", Detail: "spam", RemoteAddr: "10.0.0.1"}}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got %+v, want %+v", entries, want)
+	}
+}
+
+func TestAuditLogValidation(t *testing.T) {
+	b := newOfflineClient()
+	now := time.Now()
+	opts := AuditLogOptions{Since: now, Until: now.Add(-time.Hour), Category: "logins", Limit: -1}
+	_, err := b.Admin.AuditLog(context.Background(), opts)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || !reflect.DeepEqual(errs.Fields(), []string{"until", "category", "limit"}) {
+		t.Errorf("got %v", err)
+	}
+	if _, _, err := b.Admin.AuditLogPages(opts, 10).Next(context.Background()); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("AuditLogPages: got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestAuditLogPages(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("category") != AuditDownload {
+			t.Errorf("query = %v", query)
+		}
+		offset, _ := strconv.Atoi(query.Get("offset"))
+		var page []string
+		for id := offset + 1; id <= 5 && id <= offset+2; id++ {
+			page = append(page, fmt.Sprintf(`{"id": %d}`, id))
+		}
+		fmt.Fprintf(w, "[%s]", strings.Join(page, ","))
+	}))
+	pages := b.Admin.AuditLogPages(AuditLogOptions{Category: AuditDownload, Limit: 1}, 2)
+	var ids []int64
+	for {
+		page, ok, err := pages.Next(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			break
+		}
+		for _, entry := range page {
+			ids = append(ids, entry.ID)
+		}
+	}
+	if !reflect.DeepEqual(ids, []int64{1, 2, 3, 4, 5}) {
+		t.Errorf("got %v", ids)
+	}
+}
@@ -0,0 +1,166 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+)
+
+// Err2FARequired : the account has two-factor authentication enabled, so
+// the login needs a TOTP code, see LoginWith2FA
+var Err2FARequired = errors.New("two-factor authentication required")
+
+// LoginResult : Account details returned by a successful login
+type LoginResult struct {
+	UserName string `json:"user_name"`
+	// AuthLevel is AuthLevelUnknown if the server didn't report it
+	AuthLevel AuthLevel `json:"auth_level"`
+	// Token is the session token, also kept by the client for later requests
+	Token string `json:"-"`
+}
+
+// IsAdmin : Function telling whether the login has admin rights. Logins
+// whose response didn't report the auth level aren't.
+func (r LoginResult) IsAdmin() bool {
+	return r.AuthLevel == AuthLevelAdmin
+}
+
+// LoginWith2FA : Function to login as a user with two-factor authentication,
+// passing the current TOTP code. totpCode may be empty for accounts without
+// 2FA; for those with it Err2FARequired is returned, and an *APIError when
+// the code is rejected.
+func (s *AuthService) LoginWith2FA(ctx context.Context, userName string, password string, totpCode string) (LoginResult, error) {
+	if strings.TrimSpace(userName) == "" {
+		return LoginResult{}, invalidParam("userName", "must not be empty")
+	}
+	if password == "" {
+		return LoginResult{}, invalidParam("password", "must not be empty")
+	}
+	form := url.Values{}
+	form.Add("user_name", userName)
+	form.Add("password", password)
+	if totpCode != "" {
+		form.Add("totp_code", strings.TrimSpace(totpCode))
+	}
+
+	account, err := s.client.login(ctx, userName, form)
+	if err != nil {
+		return LoginResult{}, err
+	}
+	return *account, nil
+}
+
+// login : Function to post the login form, failing over like any other
+// request, and keep the session token of a successful login. An account
+// with two-factor authentication but no code in form gives Err2FARequired.
+func (b *Client) login(ctx context.Context, userName string, form url.Values) (*LoginResult, error) {
+	response, err := b.roundTrip(ctx, b.clientFor(ctx), "POST", "/api/login", form, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	respBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactorRequired(respBody) {
+		return nil, Err2FARequired
+	}
+	token, err := tokenFromHeader(response.Header)
+	if err != nil {
+		return nil, err
+	}
+	account, err := newAccount(userName, token, respBody)
+	if err != nil {
+		return nil, err
+	}
+	b.setAccount(account)
+	return account, nil
+}
+
+// newAccount : Helper function to describe the account of a login from
+// its response body. The auth level stays AuthLevelUnknown when the server
+// doesn't report it.
+func newAccount(userName string, token string, body []byte) (*LoginResult, error) {
+	account := &LoginResult{AuthLevel: AuthLevelUnknown}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := decodeFields(body, account); err != nil {
+			return nil, fmt.Errorf("decoding login response: %w", err)
+		}
+	}
+	if account.UserName == "" {
+		account.UserName = userName
+	}
+	account.Token = token
+	return account, nil
+}
+
+// setAccount : Function to record who the client logged in as, switching
+// to the session token of the login
+func (b *Client) setAccount(account *LoginResult) {
+	b.mu.Lock()
+	b.account = account
+	b.token = account.Token
+	b.mu.Unlock()
+}
+
+// Token : Function returning the session token of the last Login, e.g. to
+// store it and resume the session later with SetToken or WithToken. It is
+// empty before a login.
+func (b *Client) Token() string {
+	return b.currentToken()
+}
+
+// SetToken : Function to switch to a session token obtained earlier, an
+// empty token logging the client out. The client forgets who it logged in
+// as, so methods acting on the own account, such as ChangePassword, must be
+// given an empty user name.
+func (b *Client) SetToken(token string) {
+	b.mu.Lock()
+	b.token = strings.TrimSpace(token)
+	b.account = nil
+	b.mu.Unlock()
+}
+
+// currentToken : Function returning the session token, empty before a login
+func (b *Client) currentToken() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.token
+}
+
+// currentAccount : Function returning who the client logged in as, nil
+// before a login
+func (b *Client) currentAccount() *LoginResult {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.account
+}
+
+// twoFactorRequired : Helper function to tell whether a successful login
+// response asks for a second factor anyway, which servers flag with
+// two_factor_required in the body. On a 401 errorForStatus reads the flag.
+func twoFactorRequired(body []byte) bool {
+	var flag struct {
+		TwoFactorRequired bool `json:"two_factor_required"`
+	}
+	return json.Unmarshal(body, &flag) == nil && flag.TwoFactorRequired
+}
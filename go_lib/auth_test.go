@@ -0,0 +1,240 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// twoFactorServer : Handler accepting rand/secret with the TOTP code 123456
+func twoFactorServer(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/api/login" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if r.FormValue("user_name") != "rand" || r.FormValue("password") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.FormValue("totp_code") {
+		case "":
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"two_factor_required": true}`))
+		case "123456":
+			w.Header().Set("token", "2fa-token")
+			w.Write([]byte(`{"user_name": "rand", "auth": 1}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message": "invalid code"}`))
+		}
+	}
+}
+
+func TestLoginWith2FA(t *testing.T) {
+	b := newTestClient(t, twoFactorServer(t))
+	result, err := b.Auth.LoginWith2FA(context.Background(), "rand", "secret", " 123456 ")
+	if err != nil {
+		t.Fatalf("LoginWith2FA: %v", err)
+	}
+	want := LoginResult{UserName: "rand", AuthLevel: 1, Token: "2fa-token"}
+	if result != want {
+		t.Errorf("got %+v, want %+v", result, want)
+	}
+	if b.token != "2fa-token" || !b.IsAuthenticated() {
+		t.Errorf("token = %q, want the session token kept", b.token)
+	}
+}
+
+func TestLoginWith2FARequired(t *testing.T) {
+	b := newTestClient(t, twoFactorServer(t))
+	if _, err := b.Auth.LoginWith2FA(context.Background(), "rand", "secret", ""); !errors.Is(err, Err2FARequired) {
+		t.Errorf("no code: got %v, want Err2FARequired", err)
+	}
+	var apiErr *APIError
+	if _, err := b.Auth.LoginWith2FA(context.Background(), "rand", "secret", "000000"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong code: got %v, want a 401 APIError", err)
+	}
+	if b.IsAuthenticated() {
+		t.Error("failed logins left the client authenticated")
+	}
+	if err := b.Auth.Login(context.Background(), "rand", "secret"); !errors.Is(err, Err2FARequired) {
+		t.Errorf("Login = %v, want Err2FARequired", err)
+	}
+}
+
+func TestLoginWith2FAFailover(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	downURL := down.URL
+	down.Close()
+	backup := httptest.NewServer(twoFactorServer(t))
+	defer backup.Close()
+
+	b, err := NewWithFailover(downURL, []string{backup.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Auth.LoginWith2FA(context.Background(), "rand", "secret", ""); !errors.Is(err, Err2FARequired) {
+		t.Fatalf("no code: got %v, want Err2FARequired", err)
+	}
+	if _, err := b.Auth.LoginWith2FA(context.Background(), "rand", "secret", "123456"); err != nil {
+		t.Fatalf("LoginWith2FA: %v", err)
+	}
+	if got := b.currentAPIURL(); got != backup.URL {
+		t.Errorf("current endpoint = %s, want the backup %s", got, backup.URL)
+	}
+}
+
+func TestLoginWith2FARequiredOnSuccess(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"two_factor_required": true}`))
+	}))
+	if _, err := b.Auth.LoginWith2FA(context.Background(), "rand", "secret", ""); !errors.Is(err, Err2FARequired) {
+		t.Errorf("got %v, want Err2FARequired", err)
+	}
+}
+
+func TestLoginMalformedResponse(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("token", "t")
+		w.Write([]byte(`{"user_name": 5}`))
+	}))
+	if _, err := b.Auth.LoginWith2FA(context.Background(), "rand", "secret", ""); err == nil {
+		t.Fatal("expected an error for an undecodable login response")
+	}
+	if b.IsAuthenticated() || b.currentAccount() != nil {
+		t.Error("a failed login left the client authenticated")
+	}
+}
+
+func TestLoginWith2FAValidation(t *testing.T) {
+	b := newOfflineClient()
+	if _, err := b.Auth.LoginWith2FA(context.Background(), "", "secret", "1"); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("empty user: got %v, want ErrInvalidParams", err)
+	}
+	if _, err := b.Auth.LoginWith2FA(context.Background(), "rand", "", "1"); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("empty password: got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestConcurrentLoginAndRequests(t *testing.T) {
+	var logins int32
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/login" {
+			w.Header().Set("token", fmt.Sprintf("token-%d", atomic.AddInt32(&logins, 1)))
+			return
+		}
+		w.Write([]byte("[]"))
+	}))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := b.Auth.Login(context.Background(), "rand", "secret"); err != nil {
+				t.Errorf("Login: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			b.Downloads.GetQueue(context.Background())
+			b.IsAuthenticated()
+		}()
+	}
+	wg.Wait()
+	if !b.IsAuthenticated() {
+		t.Error("client not authenticated after the logins")
+	}
+}
+
+func TestClone(t *testing.T) {
+	var tokens []string
+	var mu sync.Mutex
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/login" {
+			w.Header().Set("token", r.FormValue("user_name")+"-token")
+			return
+		}
+		mu.Lock()
+		tokens = append(tokens, r.Header.Get("token"))
+		mu.Unlock()
+		w.Write([]byte("[]"))
+	}))
+	if err := b.Auth.Login(context.Background(), "admin", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	clone := b.Clone()
+	if clone.IsAuthenticated() {
+		t.Fatal("clone kept the session")
+	}
+	if clone.doer != b.doer || clone.Users.client != clone {
+		t.Error("clone doesn't share the transport or its services point elsewhere")
+	}
+	if err := clone.Auth.Login(context.Background(), "rand", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	b.Downloads.GetQueue(context.Background())
+	clone.Downloads.GetQueue(context.Background())
+	if want := []string{"admin-token", "rand-token"}; !reflect.DeepEqual(tokens, want) {
+		t.Errorf("tokens = %v, want %v", tokens, want)
+	}
+	clone.Close()
+	if _, err := b.Downloads.GetQueue(context.Background()); err != nil {
+		t.Errorf("closing the clone broke the original: %v", err)
+	}
+}
+
+func TestTokenAccessors(t *testing.T) {
+	var got []string
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/login" {
+			w.Header().Set("token", "session-1")
+			return
+		}
+		got = append(got, r.Header.Get("token"))
+		w.Write([]byte("[]"))
+	}))
+	if b.Token() != "" {
+		t.Errorf("Token before Login = %q", b.Token())
+	}
+	if err := b.Auth.Login(context.Background(), "rand", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	stored := b.Token()
+	if stored != "session-1" {
+		t.Errorf("Token = %q, want session-1", stored)
+	}
+
+	resumed := newTestClient(t, http.NotFoundHandler())
+	resumed.SetToken(" " + stored + " ")
+	if resumed.Token() != stored || !resumed.IsAuthenticated() {
+		t.Errorf("SetToken: Token = %q", resumed.Token())
+	}
+	b.SetToken("")
+	if b.IsAuthenticated() || b.currentAccount() != nil {
+		t.Error("SetToken(\"\") didn't log out")
+	}
+	b.SetToken("session-2")
+	b.Downloads.GetQueue(context.Background())
+	if want := []string{"session-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("tokens sent = %v, want %v", got, want)
+	}
+}
@@ -15,809 +15,933 @@ package bassa
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gojektech/heimdall"
 	"github.com/gojektech/heimdall/httpclient"
 	"github.com/hokaccha/go-prettyjson"
-
-	logger "./utils"
 )
 
-// Bassa : Bassa Go object
-type Bassa struct {
+// Client : Bassa Go client, created with NewClient. Its methods are
+// grouped into services by what they act on, e.g. client.Users.GetQuota.
+type Client struct {
+	Auth      *AuthService
+	Users     *UsersService
+	Downloads *DownloadsService
+	Admin     *AdminService
+	// common is shared by the services
+	common service
+
 	apiURL     string
 	token      string
+	apiKey     string
+	account    *LoginResult // who the client logged in as, nil before Login
 	timeout    int
 	retryCount int
 	httpClient *httpclient.Client
+	// doer is the transport underneath httpClient, used directly for
+	// streams which must neither be retried nor cut off by the timeout
+	doer heimdall.Doer
+
+	// output, when set by WithOutputWriter, gets the responses of the
+	// legacy methods, indented and colored if prettyPrint is set
+	output      io.Writer
+	prettyPrint bool
+
+	// rules are checked before creating or updating accounts
+	rules ValidationRules
+
+	// bulkConcurrency, set by WithBulkConcurrency, is how many users bulk
+	// operations process at once, defaultBulkConcurrency when 0
+	bulkConcurrency int
+
+	// strictDecoding, set by WithStrictDecoding, rejects unknown response fields
+	strictDecoding bool
+
+	// compressRequests gzips request bodies larger than compressionThreshold
+	compressRequests bool
+	// basePath is the prefix set by WithBasePath, "" or starting with a
+	// slash and never ending with one
+	basePath string
+	// apiVersion is the version segment set by WithAPIVersion, "" for the
+	// unversioned routes
+	apiVersion string
+
+	// authRedirectPattern, set by WithAuthRedirectPattern, matches the
+	// targets of redirects to a login page
+	authRedirectPattern *regexp.Regexp
+
+	// redactedFields are the JSON fields set by WithResponseRedaction,
+	// dropped from responses before they are decoded
+	redactedFields map[string]bool
+
+	// tracer, when set by WithTracer, gets a span for every request
+	tracer Tracer
+
+	// serverKey is the key set by WithServerKey, "" for defaultServerKey
+	serverKey string
+
+	// signingSecret and signingHeader are set by WithRequestSigning
+	signingSecret []byte
+	signingHeader string
+
+	// configErr is the first invalid setting given to an Option, returned
+	// by every request
+	configErr error
+
+	// limiter spaces out requests, see WithRateLimit and ApplyRecommended
+	limiter rateLimiter
+
+	// mu guards apiURL once the client fails over between endpoints,
+	// closed/done when the client is closed, serverVersion and features
+	// once detected, minPollInterval, rateLimit, token and account, so that
+	// the client can be shared between goroutines
+	mu sync.Mutex
+	// endpoints holds the primary server followed by its backups
+	endpoints []string
+
+	// done is closed by Close to stop the watchers, tracked by watchers
+	done     chan struct{}
+	closed   bool
+	watchers sync.WaitGroup
+
+	// serverVersion is the version reported by the server's info endpoint,
+	// empty until detected
+	serverVersion string
+	// features are those advertised by the server; featuresDetected is set
+	// once the info endpoint answered, with or without them
+	features         ServerInfo
+	featuresDetected bool
+	// minPollInterval is the shortest polling interval recommended by the
+	// server, applied by ApplyRecommended
+	minPollInterval time.Duration
+	// rateLimit is the latest limit reported in the server's response headers
+	rateLimit rateLimitState
 }
 
 var (
-	errBadFormat        = errors.New("invalid format")
-	errIncompleteParams = errors.New("Some fields are not valid or empty")
+	errMissingToken = errors.New("login response carries no token")
 )
 
-// validateFormat : Helper function to validate email address
-func validateFormat(email string) error {
-	re := regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
-	if !re.MatchString(email) {
-		return errBadFormat
-	}
-	return nil
-}
-
-// Init : Initialization of Bassa
-func (b *Bassa) Init(apiURL string, timeout int, retryCount int) {
-	if apiURL == "" || timeout == 0 {
-		panic(errIncompleteParams)
-	}
-	u, err := url.Parse(apiURL)
-	if err != nil {
-		logger.InfoLogger.Println(u)
-		logger.ErrorLogger.Panic(err)
-	} else {
-		b.apiURL = apiURL
-		b.timeout = timeout
-		b.retryCount = retryCount
-		b.token = ""
-		timeout := time.Duration(timeout) * time.Millisecond
-		httpClient := httpclient.NewClient(
-			httpclient.WithHTTPTimeout(timeout),
-			httpclient.WithRetryCount(retryCount),
-			httpclient.WithRetrier(heimdall.NewRetrier(heimdall.NewConstantBackoff(10*time.Millisecond, 50*time.Millisecond))),
-		)
-		b.httpClient = httpClient
-	}
-}
-
-// Login : Function to login as a user
-func (b *Bassa) Login(userName string, password string) {
-	if userName == "" || password == "" {
-		panic(errIncompleteParams)
-	}
-	endpoint := "/api/login"
-	apiURL := b.apiURL + endpoint
+// Bassa : Former name of Client
+//
+// Deprecated: use Client.
+type Bassa = Client
 
-	form := url.Values{}
-	form.Add("user_name", userName)
-	form.Add("password", password)
+// Defaults of clients created by NewClient
+const (
+	defaultTimeout    = 5000
+	defaultRetryCount = 1
+)
 
-	response, err := http.PostForm(apiURL, form)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
+// NewClient : Function to create a client for the Bassa server at apiURL,
+// an absolute http(s) URL. The client uses a timeout of 5 seconds and a
+// single retry unless opts say otherwise, e.g. WithTimeout. An invalid
+// apiURL or option is returned as a *ValidationError.
+func NewClient(apiURL string, opts ...Option) (*Client, error) {
+	if err := validateEndpoint(apiURL); err != nil {
+		return nil, err
 	}
-	defer response.Body.Close()
-	b.token = response.Header["Token"][0]
-
-	respBody, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		logger.InfoLogger.Println(string(respBody))
-		logger.ErrorLogger.Panic(err)
+	b := &Client{}
+	b.setup(apiURL, defaultTimeout, defaultRetryCount, opts)
+	if b.configErr != nil {
+		return nil, b.configErr
 	}
+	return b, nil
 }
 
-// AddRegularUserRequest : Function add a regular user request
-func (b *Bassa) AddRegularUserRequest(userName string, password string, email string) {
-	if userName == "" || password == "" || email == "" {
-		panic(errIncompleteParams)
-	}
+// Init : Initialization of Bassa, opts are applied on top of the defaults.
+// It returns the first invalid argument or option.
+//
+// Deprecated: use NewClient, with WithTimeout and WithRetryCount.
+func (b *Client) Init(apiURL string, timeout int, retryCount int, opts ...Option) error {
+	var errs ValidationErrors
+	if apiURL == "" {
+		errs = append(errs, &ValidationError{Field: "apiURL", Reason: "must not be empty"})
+	} else if _, err := url.Parse(apiURL); err != nil {
+		errs = append(errs, &ValidationError{Field: "apiURL", Reason: "is not a valid URL"})
+	}
+	if timeout == 0 {
+		errs = append(errs, &ValidationError{Field: "timeout", Reason: "must not be 0"})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	b.setup(apiURL, timeout, retryCount, opts)
+	return b.configErr
+}
 
-	err := validateFormat(email)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
+// setup : Function to configure the client with the given defaults, then
+// apply opts on top of them
+func (b *Client) setup(apiURL string, timeout int, retryCount int, opts []Option) {
+	b.initServices()
+	b.apiURL = apiURL
+	b.timeout = timeout
+	b.retryCount = retryCount
+	b.token = ""
+	b.doer = &http.Client{Timeout: time.Duration(timeout) * time.Millisecond, CheckRedirect: b.checkRedirect}
+	b.httpClient = newHTTPClient(b.doer, retryCount)
+	for _, opt := range opts {
+		opt(b)
 	}
+}
 
-	endpoint := "/api/regularuser"
-	apiURL := b.apiURL + endpoint
-
-	requestBody, err := json.Marshal(map[string]string{
-		"user_name": userName,
-		"password":  password,
-		"email":     email})
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
+// validateEndpoint : Helper function to check that endpoint is an absolute
+// http(s) URL
+func validateEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return invalidParam("endpoint", strconv.Quote(endpoint)+" is not an absolute http(s) URL")
 	}
-	request, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(requestBody))
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
+	return nil
+}
+
+// NewWithFailover : Function to create a client for a highly available
+// deployment. Requests go to primary and move on to the next of backups,
+// in order, when a server can't be reached; the client then sticks with the
+// server that answered. opts are those of NewClient, applied to every server.
+func NewWithFailover(primary string, backups []string, opts ...Option) (*Client, error) {
+	endpoints := append([]string{primary}, backups...)
+	for _, endpoint := range endpoints {
+		if err := validateEndpoint(endpoint); err != nil {
+			return nil, err
+		}
 	}
-	request.Header.Set("token", b.token)
-	response, err := b.httpClient.Do(request)
+	b, err := NewClient(primary, opts...)
 	if err != nil {
-		logger.ErrorLogger.Panic(err)
+		return nil, err
 	}
+	b.endpoints = endpoints
+	return b, nil
+}
 
-	defer response.Body.Close()
-
-	respBody, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		logger.InfoLogger.Println(string(respBody))
-		logger.ErrorLogger.Panic(err)
-	}
+// Clone : Function to derive a client with the same settings and the same
+// transport, and so the same connection pool, but no session, e.g. to act
+// on behalf of several users at once. The clone is logged out and has its
+// own watchers; closing either client doesn't stop the other.
+func (b *Client) Clone() *Client {
+	b.mu.Lock()
+	clone := &Client{
+		apiURL:              b.apiURL,
+		apiKey:              b.apiKey,
+		serverKey:           b.serverKey,
+		timeout:             b.timeout,
+		retryCount:          b.retryCount,
+		httpClient:          b.httpClient,
+		doer:                b.doer,
+		output:              b.output,
+		prettyPrint:         b.prettyPrint,
+		bulkConcurrency:     b.bulkConcurrency,
+		strictDecoding:      b.strictDecoding,
+		compressRequests:    b.compressRequests,
+		basePath:            b.basePath,
+		apiVersion:          b.apiVersion,
+		rules:               b.rules,
+		authRedirectPattern: b.authRedirectPattern,
+		redactedFields:      b.redactedFields,
+		tracer:              b.tracer,
+		signingSecret:       b.signingSecret,
+		signingHeader:       b.signingHeader,
+		configErr:           b.configErr,
+		endpoints:           append([]string(nil), b.endpoints...),
+		serverVersion:       b.serverVersion,
+		features:            b.features,
+		featuresDetected:    b.featuresDetected,
+		minPollInterval:     b.minPollInterval,
+		rateLimit:           b.rateLimit,
+	}
+	b.mu.Unlock()
+	b.limiter.mu.Lock()
+	clone.limiter.interval = b.limiter.interval
+	b.limiter.mu.Unlock()
+	clone.initServices()
+	return clone
 }
 
-// AddUserRequest : Function to add a user request
-func (b *Bassa) AddUserRequest(userName string, password string, email string, authLevel int) {
-	if userName == "" || password == "" || email == "" {
-		panic(errIncompleteParams)
-	}
+// keepAliveDoer : heimdall marks every request with Close, which defeats the
+// transport's connection pool; undo that before the request goes out
+type keepAliveDoer struct {
+	doer heimdall.Doer
+}
 
-	err := validateFormat(email)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
+func (k keepAliveDoer) Do(request *http.Request) (*http.Response, error) {
+	request.Close = false
+	return k.doer.Do(request)
+}
 
-	endpoint := "/api/user"
-	apiURL := b.apiURL + endpoint
+// newHTTPClient : Helper function to build the retrying client used for requests
+func newHTTPClient(doer heimdall.Doer, retryCount int) *httpclient.Client {
+	return httpclient.NewClient(
+		httpclient.WithHTTPClient(keepAliveDoer{doer: doer}),
+		httpclient.WithRetryCount(retryCount),
+		httpclient.WithRetrier(heimdall.NewRetrier(heimdall.NewConstantBackoff(10*time.Millisecond, 50*time.Millisecond))),
+	)
+}
 
-	requestBody := []byte(fmt.Sprintf("{user_name:\"%s\", password: \"%s\", email: \"%s\", auth: %d}", userName, password, email, authLevel))
+// Warmup : Function to establish a connection to the server ahead of time.
+// It primes the transport's connection pool so that the first real call
+// doesn't pay the TCP/TLS handshake cost. Any HTTP response counts as success.
+func (b *Client) Warmup(ctx context.Context) error {
+	_, err := b.probe(ctx)
+	return err
+}
 
-	request, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(requestBody))
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	request.Header.Set("token", b.token)
-	response, err := b.httpClient.Do(request)
+// probe : Function to send a HEAD request to the server root, returning
+// the response whatever the status. Its body is already closed.
+func (b *Client) probe(ctx context.Context) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, "HEAD", b.endpointURL("/"), nil)
 	if err != nil {
-		logger.ErrorLogger.Panic(err)
+		return nil, err
 	}
-
-	defer response.Body.Close()
-	var r interface{}
-	if err := json.NewDecoder(response.Body).Decode(&r); err != nil {
-		logger.ErrorLogger.Panic(err)
+	if err := b.authorize(request); err != nil {
+		return nil, err
 	}
-	out, err := prettyjson.Marshal(r)
+	response, err := b.httpClient.Do(request)
 	if err != nil {
-		logger.InfoLogger.Println(out)
-		logger.ErrorLogger.Panic(err)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
 	}
-	logger.InfoLogger.Println(string(out))
+	// The body must be drained for the connection to be handed back to the pool
+	io.Copy(ioutil.Discard, response.Body)
+	response.Body.Close()
+	return response, nil
 }
 
-// RemoveUserRequest : Function to remove user
-func (b *Bassa) RemoveUserRequest(userName string) {
+// Login : Function to login as a user. Accounts with two-factor
+// authentication get Err2FARequired and must use LoginWith2FA.
+func (s *AuthService) Login(ctx context.Context, userName string, password string) error {
+	var errs ValidationErrors
 	if userName == "" {
-		panic(errIncompleteParams)
+		errs = append(errs, &ValidationError{Field: "userName", Reason: "must not be empty"})
 	}
-
-	endpoint := "/api/user" + "/" + userName
-	apiURL := b.apiURL + endpoint
-
-	request, err := http.NewRequest("DELETE", apiURL, nil)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
+	if password == "" {
+		errs = append(errs, &ValidationError{Field: "password", Reason: "must not be empty"})
 	}
-	request.Header.Set("token", b.token)
-	response, err := b.httpClient.Do(request)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
+	if len(errs) > 0 {
+		return errs
 	}
+	form := url.Values{}
+	form.Add("user_name", userName)
+	form.Add("password", password)
 
-	defer response.Body.Close()
-	var r interface{}
-	if err := json.NewDecoder(response.Body).Decode(&r); err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	out, err := prettyjson.Marshal(r)
-	if err != nil {
-		logger.InfoLogger.Println(out)
-		logger.ErrorLogger.Panic(err)
-	}
+	_, err := s.client.login(ctx, userName, form)
+	return err
 }
 
-// UpdateUserRequest : Function to update user request
-func (b *Bassa) UpdateUserRequest(userName string, newUserName string, password string, authLevel int, email string) {
-	if userName == "" || password == "" || email == "" || newUserName == "" {
-		panic(errIncompleteParams)
+// Call : Function to send a request to an endpoint the library doesn't
+// wrap yet, e.g. a feature of a newer server. It gets the authentication,
+// retries, failover and error mapping of the other methods. path is
+// relative to the server and its base path, e.g. "/api/user"; body, when
+// not nil, is sent as JSON and the response is decoded into out unless
+// out is nil.
+func (b *Client) Call(ctx context.Context, method string, path string, body interface{}, out interface{}) error {
+	if method == "" {
+		return invalidParam("method", "must not be empty")
 	}
-
-	err := validateFormat(email)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
+	if !strings.HasPrefix(path, "/") {
+		return invalidParam("path", "must start with a slash")
 	}
+	return b.do(ctx, strings.ToUpper(method), path, body, out)
+}
 
-	endpoint := "/api/user"
-	apiURL := b.apiURL + endpoint + "/" + userName
-
-	requestBody := []byte(fmt.Sprintf("{user_name:\"%s\", password: \"%s\", email: \"%s\", auth_level: %d}", newUserName, password, email, authLevel))
-
-	request, err := http.NewRequest("PUT", apiURL, bytes.NewBuffer(requestBody))
+// do : Function to send a request to the given endpoint and decode the JSON
+// response into out. body, when not nil, is encoded as JSON with the field
+// names of the detected server version; out may be nil to discard the response.
+func (b *Client) do(ctx context.Context, method string, endpoint string, body interface{}, out interface{}) error {
+	response, err := b.roundTrip(ctx, b.clientFor(ctx), method, endpoint, body, nil)
 	if err != nil {
-		logger.ErrorLogger.Panic(err)
+		return err
 	}
-	request.Header.Set("token", b.token)
-	response, err := b.httpClient.Do(request)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-
 	defer response.Body.Close()
-	var r interface{}
-	if err := json.NewDecoder(response.Body).Decode(&r); err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	out, err := prettyjson.Marshal(r)
-	if err != nil {
-		logger.InfoLogger.Println(out)
-		logger.ErrorLogger.Panic(err)
-	}
-	logger.InfoLogger.Println(string(out))
-}
-
-// GetUserRequest : Function to get user request
-func (b *Bassa) GetUserRequest() string {
 
-	endpoint := "/api/user"
-	apiURL := b.apiURL + endpoint
-
-	request, err := http.NewRequest("GET", apiURL, nil)
+	respBody, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		logger.ErrorLogger.Panic(err)
+		return err
 	}
-	request.Header.Set("token", b.token)
-	response, err := b.httpClient.Do(request)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
+	if out == nil || len(bytes.TrimSpace(respBody)) == 0 {
+		return nil
 	}
+	return b.decode(respBody, out)
+}
 
-	defer response.Body.Close()
-	var r interface{}
-	if err := json.NewDecoder(response.Body).Decode(&r); err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	out, err := prettyjson.Marshal(r)
+// decode : Function to decode a response body into out, unwrapping it from
+// its envelope, if any, and leaving the fields set by WithResponseRedaction
+// at their zero value. With WithStrictDecoding fields out doesn't declare
+// are an error.
+func (b *Client) decode(data []byte, out interface{}) error {
+	data, err := unwrapEnvelope(data)
 	if err != nil {
-		logger.InfoLogger.Println(out)
-		logger.ErrorLogger.Panic(err)
+		return err
 	}
-	logger.InfoLogger.Println(string(out))
-	return string(out)
+	return b.decodeValue(data, out)
 }
 
-// GetUserSignupRequests : Function to get user signup requests
-func (b *Bassa) GetUserSignupRequests() string {
-
-	endpoint := "/api/user/requests"
-	apiURL := b.apiURL + endpoint
-
-	request, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
+// decodeValue : Function to decode a JSON value already out of its envelope
+// into out, as decode does
+func (b *Client) decodeValue(data []byte, out interface{}) error {
+	var err error
+	if len(b.redactedFields) > 0 {
+		if data, err = redactFields(data, b.redactedFields); err != nil {
+			return err
+		}
 	}
-	request.Header.Set("token", b.token)
-	response, err := b.httpClient.Do(request)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
+	if !b.strictDecoding {
+		return decodeFields(data, out)
 	}
+	if data, err = canonicalFields(data, out); err != nil {
+		return err
+	}
+	return decodeStrict(data, out)
+}
 
-	defer response.Body.Close()
-	var r interface{}
-	if err := json.NewDecoder(response.Body).Decode(&r); err != nil {
-		logger.ErrorLogger.Panic(err)
+// clientFor : Function to get the retrying client for a call, honouring a
+// retry count set on ctx with WithCallRetries and a timeout set with
+// WithRequestTimeout, which replaces the client's
+func (b *Client) clientFor(ctx context.Context) heimdall.Doer {
+	retries := b.retriesFor(ctx)
+	if requestOptionsFrom(ctx).timeout > 0 {
+		return newHTTPClient(b.unboundedDoer(), retries)
 	}
-	out, err := prettyjson.Marshal(r)
-	if err != nil {
-		logger.InfoLogger.Println(out)
-		logger.ErrorLogger.Panic(err)
+	if retries != b.retryCount {
+		return newHTTPClient(b.doer, retries)
 	}
-	logger.InfoLogger.Println(string(out))
-	return string(out)
+	return b.httpClient
 }
 
-// ApproveUserRequest : Function to approve user request
-func (b *Bassa) ApproveUserRequest(userName string) {
-	if userName == "" {
-		panic(errIncompleteParams)
+// retriesFor : Function returning the retry count of a call, the one set on
+// ctx with WithCallRetries or else the client's
+func (b *Client) retriesFor(ctx context.Context) int {
+	if retries, ok := ctx.Value(callRetriesKey{}).(int); ok {
+		return retries
 	}
-	endpoint := "/api/user/approve"
-	apiURL := b.apiURL + endpoint + "/" + userName
+	return b.retryCount
+}
 
-	request, err := http.NewRequest("POST", apiURL, nil)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	request.Header.Set("token", b.token)
-	response, err := b.httpClient.Do(request)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
+// stream : Function to open a long-lived response, such as a live feed. The
+// request bypasses retries and the client timeout, so it only ends with ctx
+// or the server. header, when not nil, is added to the request. The caller
+// must close the returned body.
+func (b *Client) stream(ctx context.Context, method string, endpoint string, header http.Header) (*http.Response, error) {
+	return b.roundTrip(ctx, b.unboundedDoer(), method, endpoint, nil, header)
+}
 
-	defer response.Body.Close()
-	var r interface{}
-	if err := json.NewDecoder(response.Body).Decode(&r); err != nil {
-		logger.ErrorLogger.Panic(err)
+// unboundedDoer : Function to get the transport without retries or the
+// client timeout, for requests bounded by their context alone
+func (b *Client) unboundedDoer() heimdall.Doer {
+	if client, ok := b.doer.(*http.Client); ok && client.Timeout != 0 {
+		unbounded := *client
+		unbounded.Timeout = 0
+		return &unbounded
 	}
-	out, err := prettyjson.Marshal(r)
-	if err != nil {
-		logger.InfoLogger.Println(out)
-		logger.ErrorLogger.Panic(err)
-	}
-	logger.InfoLogger.Println(string(out))
+	return b.doer
 }
 
-// GetBlockedUserRequests : Function to get blocked user requests
-func (b *Bassa) GetBlockedUserRequests() string {
+// roundTrip : Function to send a request through doer, turning transport
+// failures and non-2xx responses into errors, and redirects to a login page
+// into ErrNotAuthenticated. When the server can't be reached the request
+// moves on to the next configured endpoint, which then serves later requests
+// too. Responses, including 4xx, never fail over. A timeout set with
+// WithRequestTimeout lasts until the response body is closed.
+func (b *Client) roundTrip(ctx context.Context, doer heimdall.Doer, method string, endpoint string, body interface{}, header http.Header) (response *http.Response, err error) {
+	if timeout := requestOptionsFrom(ctx).timeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer func() {
+			if response == nil {
+				cancel()
+				return
+			}
+			response.Body = cancelOnClose{ReadCloser: response.Body, cancel: cancel}
+		}()
+	}
+	if b.tracer != nil {
+		var span Span
+		ctx, span = startSpan(ctx, b.tracer, method, endpoint)
+		defer func() {
+			if response != nil {
+				span.SetAttribute("http.status_code", response.StatusCode)
+			}
+			endSpan(span, err)
+		}()
+	}
+	if err := b.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for _, base := range b.endpointOrder() {
+		request, err := b.newRequest(ctx, base, method, endpoint, body, header)
+		if err != nil {
+			return nil, err
+		}
+		response, err := doer.Do(request)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = err
+			continue
+		}
+		b.setAPIURL(base)
+		b.recordRateLimit(response.Header)
+		// Redirects are only returned when checkRedirect took them for a login page
+		if location := response.Header.Get("Location"); location != "" && response.StatusCode >= 300 && response.StatusCode <= 399 {
+			io.Copy(ioutil.Discard, response.Body)
+			response.Body.Close()
+			return nil, fmt.Errorf("%s %s: redirected to %s: %w", method, request.URL.Path, location, ErrNotAuthenticated)
+		}
+		if response.StatusCode < 200 || response.StatusCode > 299 {
+			err := errorForStatus(method, request.URL.Path, response)
+			io.Copy(ioutil.Discard, response.Body)
+			response.Body.Close()
+			return nil, err
+		}
+		return response, nil
+	}
+	return nil, lastErr
+}
 
-	endpoint := "/api/user/blocked"
-	apiURL := b.apiURL + endpoint
+// endpointOrder : Function listing the server URLs to try, the last one
+// known to be healthy first
+func (b *Client) endpointOrder() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	order := []string{b.apiURL}
+	for _, endpoint := range b.endpoints {
+		if endpoint != b.apiURL {
+			order = append(order, endpoint)
+		}
+	}
+	return order
+}
 
-	request, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	request.Header.Set("token", b.token)
-	response, err := b.httpClient.Do(request)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
+// setAPIURL : Function to record the endpoint requests are sent to
+func (b *Client) setAPIURL(apiURL string) {
+	b.mu.Lock()
+	b.apiURL = apiURL
+	b.mu.Unlock()
+}
 
-	defer response.Body.Close()
-	var r interface{}
-	if err := json.NewDecoder(response.Body).Decode(&r); err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	out, err := prettyjson.Marshal(r)
-	if err != nil {
-		logger.InfoLogger.Println(out)
-		logger.ErrorLogger.Panic(err)
-	}
-	logger.InfoLogger.Println(string(out))
-	return string(out)
+// currentAPIURL : Function returning the endpoint requests are sent to
+func (b *Client) currentAPIURL() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.apiURL
 }
 
-// BlockUserRequest : Function to block user request
-func (b *Bassa) BlockUserRequest(userName string) {
-	if userName == "" {
-		panic(errIncompleteParams)
-	}
-	endpoint := "/api/user/blocked"
-	apiURL := b.apiURL + endpoint + "/" + userName
+// endpointURL : Function to get the full URL of an endpoint on the current server
+func (b *Client) endpointURL(endpoint string) string {
+	return b.resolve(b.currentAPIURL(), endpoint)
+}
 
-	request, err := http.NewRequest("POST", apiURL, nil)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	request.Header.Set("token", b.token)
-	response, err := b.httpClient.Do(request)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
+// resolve : Helper function to join a server URL, the base path and an
+// endpoint. Every request URL is built here, so API endpoints also get the
+// version set by WithAPIVersion, /api/user becoming /api/v2/user.
+func (b *Client) resolve(apiURL string, endpoint string) string {
+	if b.apiVersion != "" && (endpoint == "/api" || strings.HasPrefix(endpoint, "/api/") || strings.HasPrefix(endpoint, "/api?")) {
+		endpoint = "/api/" + b.apiVersion + strings.TrimPrefix(endpoint, "/api")
 	}
+	return strings.TrimSuffix(apiURL, "/") + b.basePath + endpoint
+}
 
-	defer response.Body.Close()
-	var r interface{}
-	if err := json.NewDecoder(response.Body).Decode(&r); err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	out, err := prettyjson.Marshal(r)
-	if err != nil {
-		logger.InfoLogger.Println(out)
-		logger.ErrorLogger.Panic(err)
-	}
-	logger.InfoLogger.Println(string(out))
+// newRequest : Helper function to build a request carrying the credentials,
+// any extra header and the headers and query set on ctx with
+// WithRequestOptions. body is sent form encoded when it is a url.Values and
+// as JSON otherwise.
+func (b *Client) newRequest(ctx context.Context, apiURL string, method string, endpoint string, body interface{}, header http.Header) (*http.Request, error) {
+	var reader io.Reader
+	var contentType string
+	compressed := false
+	if body != nil {
+		requestBody, bodyType, err := b.encodeBody(body)
+		if err != nil {
+			return nil, err
+		}
+		contentType = bodyType
+		if b.compressRequests && bodyType == jsonContentType && len(requestBody) > compressionThreshold {
+			if requestBody, err = gzipBody(requestBody); err != nil {
+				return nil, err
+			}
+			compressed = true
+		}
+		reader = bytes.NewReader(requestBody)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, b.resolve(apiURL, endpoint), reader)
+	if err != nil {
+		return nil, err
+	}
+	opts := requestOptionsFrom(ctx)
+	if len(opts.query) > 0 {
+		query := request.URL.Query()
+		for key, values := range opts.query {
+			query[key] = append(query[key], values...)
+		}
+		request.URL.RawQuery = query.Encode()
+	}
+	for name, values := range opts.header {
+		request.Header[name] = append([]string(nil), values...)
+	}
+	for name, values := range header {
+		request.Header[name] = values
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", contentType)
+	}
+	if compressed {
+		request.Header.Set("Content-Encoding", "gzip")
+	}
+	if b.tracer != nil {
+		b.tracer.Inject(ctx, request.Header)
+	}
+	if err := b.authorize(request); err != nil {
+		return nil, err
+	}
+	return request, nil
 }
 
-// UnBlockUserRequest : Function to unblock user request
-func (b *Bassa) UnBlockUserRequest(userName string) {
-	if userName == "" {
-		panic(errIncompleteParams)
-	}
-	endpoint := "/api/user/blocked"
-	apiURL := b.apiURL + endpoint + "/" + userName
+// authorize : Helper function to attach the session token and, when one is
+// configured, the API key and the signature to a request. Every request goes
+// through it, so it also reports an invalid Option setting.
+func (b *Client) authorize(request *http.Request) error {
+	if b.configErr != nil {
+		return b.configErr
+	}
+	request.Header.Set("token", b.currentToken())
+	if b.apiKey != "" {
+		request.Header.Set(apiKeyHeader, b.apiKey)
+	}
+	if b.signingSecret == nil {
+		return nil
+	}
+	var body []byte
+	if request.GetBody != nil {
+		reader, err := request.GetBody()
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		if body, err = ioutil.ReadAll(reader); err != nil {
+			return err
+		}
+	}
+	request.Header.Set(b.signingHeader, signRequest(b.signingSecret, request.Method, request.URL, body))
+	return nil
+}
 
-	request, err := http.NewRequest("DELETE", apiURL, nil)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	request.Header.Set("token", b.token)
-	response, err := b.httpClient.Do(request)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
+// IsAuthenticated : Function to tell whether requests carry credentials,
+// either a token from Login or an API key
+func (b *Client) IsAuthenticated() bool {
+	return b.currentToken() != "" || b.apiKey != ""
+}
 
-	defer response.Body.Close()
-	var r interface{}
-	if err := json.NewDecoder(response.Body).Decode(&r); err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	out, err := prettyjson.Marshal(r)
-	if err != nil {
-		logger.InfoLogger.Println(out)
-		logger.ErrorLogger.Panic(err)
-	}
-	logger.InfoLogger.Println(string(out))
+// tokenFromHeader : Helper function to read the session token of a login
+// response. Proxies may duplicate the header or fold the copies into one
+// comma separated value, so the last non-empty value wins.
+func tokenFromHeader(header http.Header) (string, error) {
+	values := header.Values("Token")
+	for i := len(values) - 1; i >= 0; i-- {
+		parts := strings.Split(values[i], ",")
+		for j := len(parts) - 1; j >= 0; j-- {
+			if token := strings.TrimSpace(parts[j]); token != "" {
+				return token, nil
+			}
+		}
+	}
+	return "", errMissingToken
 }
 
-// GetDownloadUserRequests : Function to get download user requests
-func (b *Bassa) GetDownloadUserRequests(limit int) string {
-	if limit == 0 {
-		limit = 1
+// call : Function behind the legacy methods, sending a request and
+// returning the JSON response, also written to the writer set by
+// WithOutputWriter. header, when not nil, is added to the request.
+func (b *Client) call(ctx context.Context, method string, endpoint string, body interface{}, header http.Header) (string, error) {
+	response, err := b.roundTrip(ctx, b.clientFor(ctx), method, endpoint, body, header)
+	if err != nil {
+		return "", err
 	}
-	endpoint := "/api/user/downloads"
-	apiURL := b.apiURL + endpoint + "/" + string(limit)
+	defer response.Body.Close()
 
-	request, err := http.NewRequest("GET", apiURL, nil)
+	respBody, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		logger.ErrorLogger.Panic(err)
+		return "", err
 	}
-	request.Header.Set("token", b.token)
-	response, err := b.httpClient.Do(request)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
+	if len(bytes.TrimSpace(respBody)) == 0 {
+		return "", nil
 	}
-
-	defer response.Body.Close()
 	var r interface{}
-	if err := json.NewDecoder(response.Body).Decode(&r); err != nil {
-		logger.ErrorLogger.Panic(err)
+	if err := b.decode(respBody, &r); err != nil {
+		return "", fmt.Errorf("decoding response of %s %s: %w", method, endpoint, err)
 	}
-	out, err := prettyjson.Marshal(r)
+	out, err := json.Marshal(r)
 	if err != nil {
-		logger.InfoLogger.Println(out)
-		logger.ErrorLogger.Panic(err)
+		return "", err
 	}
-	logger.InfoLogger.Println(string(out))
-	return string(out)
+	if err := b.writeOutput(r, out); err != nil {
+		return "", err
+	}
+	return string(out), nil
 }
 
-// GetToptenHeaviestUsers : Function to get top ten heaviest users
-func (b *Bassa) GetToptenHeaviestUsers() string {
-
-	endpoint := "/api/user/heavy"
-	apiURL := b.apiURL + endpoint
-
-	request, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
+// writeOutput : Function to write a response decoded into r, and encoded
+// as out, to the writer set by WithOutputWriter, if any
+func (b *Client) writeOutput(r interface{}, out []byte) error {
+	if b.output == nil {
+		return nil
 	}
-	request.Header.Set("token", b.token)
-	response, err := b.httpClient.Do(request)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
+	if b.prettyPrint {
+		var err error
+		if out, err = prettyjson.Marshal(r); err != nil {
+			return err
+		}
 	}
+	_, err := fmt.Fprintf(b.output, "%s\n", out)
+	return err
+}
 
-	defer response.Body.Close()
-	var r interface{}
-	if err := json.NewDecoder(response.Body).Decode(&r); err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	out, err := prettyjson.Marshal(r)
-	if err != nil {
-		logger.InfoLogger.Println(out)
-		logger.ErrorLogger.Panic(err)
+// AddRegularUserRequest : Function add a regular user request
+func (s *UsersService) AddRegularUserRequest(ctx context.Context, userName string, password string, email string) error {
+	if errs := s.client.rules.validateUser("userName", userName, password, email); len(errs) > 0 {
+		return errs
 	}
-	logger.InfoLogger.Println(string(out))
-	return string(out)
+	requestBody := map[string]string{
+		"user_name": userName,
+		"password":  password,
+		"email":     email}
+	_, err := s.client.call(ctx, "POST", "/api/regularuser", requestBody, nil)
+	return err
 }
 
-// StartDownload : Function to start download
-func (b *Bassa) StartDownload(serverKey string) string {
-	if serverKey == "" {
-		serverKey = "123456789"
-		logger.InfoLogger.Println("Server Key not given, continuing with: ", serverKey)
+// AddUserRequest : Function to add a user request
+func (s *UsersService) AddUserRequest(ctx context.Context, input CreateUserInput) error {
+	if errs := input.validate(s.client.rules); len(errs) > 0 {
+		return errs
 	}
-	endpoint := "/api/download/start"
-	apiURL := b.apiURL + endpoint
+	return s.client.do(ctx, "POST", "/api/user", input, nil)
+}
 
-	request, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	request.Header.Set("token", b.token)
-	request.Header.Set("key", serverKey)
-	response, err := b.httpClient.Do(request)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
+// RemoveUserRequest : Function to remove user
+func (s *UsersService) RemoveUserRequest(ctx context.Context, userName string) error {
+	if userName == "" {
+		return invalidParam("userName", "must not be empty")
 	}
+	_, err := s.client.call(ctx, "DELETE", userEndpoint(userName, ""), nil, nil)
+	return err
+}
 
-	defer response.Body.Close()
-	var r interface{}
-	if err := json.NewDecoder(response.Body).Decode(&r); err != nil {
-		logger.ErrorLogger.Panic(err)
+// UpdateUserRequest : Function to update user request, replacing every
+// field of the user. UpdateUserFields changes some of them only.
+func (s *UsersService) UpdateUserRequest(ctx context.Context, userName string, input UpdateUserInput) error {
+	errs := input.validate(s.client.rules)
+	if userName == "" {
+		errs = append(ValidationErrors{{Field: "userName", Reason: "must not be empty"}}, errs...)
 	}
-	out, err := prettyjson.Marshal(r)
-	if err != nil {
-		logger.InfoLogger.Println(out)
-		logger.ErrorLogger.Panic(err)
+	if len(errs) > 0 {
+		return errs
 	}
-	logger.InfoLogger.Println(string(out))
-	return string(out)
+	return s.client.do(ctx, "PUT", userEndpoint(userName, ""), input, nil)
 }
 
-// KillDownload : Function to kill download
-func (b *Bassa) KillDownload(serverKey string) string {
-	if serverKey == "" {
-		serverKey = "123456789"
-		logger.InfoLogger.Println("Server Key not given, continuing with: ", serverKey)
+// GetUserRequest : Function to get the user accounts in the page set by
+// opts, all of them for the zero ListOptions
+func (s *UsersService) GetUserRequest(ctx context.Context, opts ListOptions) ([]User, error) {
+	endpoint, err := opts.endpoint("/api/user")
+	if err != nil {
+		return nil, err
 	}
-	endpoint := "/api/download/kill"
-	apiURL := b.apiURL + endpoint
+	var users []User
+	if err := s.client.do(ctx, "GET", endpoint, nil, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
 
-	request, err := http.NewRequest("GET", apiURL, nil)
+// GetUserSignupRequests : Function to get the signup requests in the page
+// set by opts, all of them for the zero ListOptions
+func (s *AdminService) GetUserSignupRequests(ctx context.Context, opts ListOptions) ([]SignupRequest, error) {
+	endpoint, err := opts.endpoint("/api/user/requests")
 	if err != nil {
-		logger.ErrorLogger.Panic(err)
+		return nil, err
 	}
-	request.Header.Set("token", b.token)
-	request.Header.Set("key", serverKey)
-	response, err := b.httpClient.Do(request)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
+	var requests []SignupRequest
+	if err := s.client.do(ctx, "GET", endpoint, nil, &requests); err != nil {
+		return nil, err
 	}
+	return requests, nil
+}
 
-	defer response.Body.Close()
-	var r interface{}
-	if err := json.NewDecoder(response.Body).Decode(&r); err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	out, err := prettyjson.Marshal(r)
-	if err != nil {
-		logger.InfoLogger.Println(out)
-		logger.ErrorLogger.Panic(err)
+// ApproveUserRequest : Function to approve user request
+func (s *AdminService) ApproveUserRequest(ctx context.Context, userName string) error {
+	if userName == "" {
+		return invalidParam("userName", "must not be empty")
 	}
-	logger.InfoLogger.Println(string(out))
-	return string(out)
+	_, err := s.client.call(ctx, "POST", "/api/user/approve/"+url.PathEscape(userName), nil, nil)
+	return err
 }
 
-// AddDownloadRequest : Function to add download request
-func (b *Bassa) AddDownloadRequest(downloadLink string) {
-	if downloadLink == "" {
-		panic(errIncompleteParams)
+// GetBlockedUserRequests : Function to get blocked user requests
+func (s *AdminService) GetBlockedUserRequests(ctx context.Context) ([]BlockedUser, error) {
+	var users []BlockedUser
+	if err := s.client.do(ctx, "GET", "/api/user/blocked", nil, &users); err != nil {
+		return nil, err
 	}
+	return users, nil
+}
 
-	endpoint := "/api/download"
-	apiURL := b.apiURL + endpoint
+// BlockUserRequest : Function to block user request
+func (s *AdminService) BlockUserRequest(ctx context.Context, userName string) error {
+	if userName == "" {
+		return invalidParam("userName", "must not be empty")
+	}
+	_, err := s.client.call(ctx, "POST", "/api/user/blocked/"+url.PathEscape(userName), nil, nil)
+	return err
+}
 
-	requestBody, err := json.Marshal(map[string]string{
-		"link": downloadLink})
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
+// UnBlockUserRequest : Function to unblock user request
+func (s *AdminService) UnBlockUserRequest(ctx context.Context, userName string) error {
+	if userName == "" {
+		return invalidParam("userName", "must not be empty")
 	}
-	request, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(requestBody))
+	_, err := s.client.call(ctx, "DELETE", "/api/user/blocked/"+url.PathEscape(userName), nil, nil)
+	return err
+}
+
+// GetDownloadUserRequests : Function to get the download history of the
+// logged in user, narrowed down by opts
+func (s *DownloadsService) GetDownloadUserRequests(ctx context.Context, opts ListDownloadsOptions) ([]DownloadRecord, error) {
+	query, err := opts.query()
 	if err != nil {
-		logger.ErrorLogger.Panic(err)
+		return nil, err
 	}
-	request.Header.Set("token", b.token)
-	response, err := b.httpClient.Do(request)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
+	var records []DownloadRecord
+	if err := s.client.do(ctx, "GET", withQuery("/api/user/downloads", query), nil, &records); err != nil {
+		return nil, err
 	}
+	return records, nil
+}
 
-	defer response.Body.Close()
-
-	var r interface{}
-	if err := json.NewDecoder(response.Body).Decode(&r); err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	out, err := prettyjson.Marshal(r)
-	if err != nil {
-		logger.InfoLogger.Println(out)
-		logger.ErrorLogger.Panic(err)
+// GetToptenHeaviestUsers : Function to get the ten users taking the most
+// space, heaviest first
+func (s *AdminService) GetToptenHeaviestUsers(ctx context.Context) ([]HeavyUser, error) {
+	var users []HeavyUser
+	if err := s.client.do(ctx, "GET", "/api/user/heavy", nil, &users); err != nil {
+		return nil, err
 	}
-	logger.InfoLogger.Println(string(out))
+	return users, nil
 }
 
-// RemoveDownloadRequest : Function to remove download request
-func (b *Bassa) RemoveDownloadRequest(id int) {
+// defaultServerKey : Key of a server left with its default configuration,
+// used by StartDownload and KillDownload when none is given
+const defaultServerKey = "123456789"
 
-	endpoint := "/api/download"
-	apiURL := b.apiURL + endpoint + string(id)
-
-	request, err := http.NewRequest("DELETE", apiURL, nil)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	request.Header.Set("token", b.token)
-	response, err := b.httpClient.Do(request)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
+// serverKeyHeader : Helper function to build the header carrying the key of
+// the server for StartDownload and KillDownload
+func serverKeyHeader(serverKey string) http.Header {
+	if serverKey == "" {
+		serverKey = defaultServerKey
 	}
+	return http.Header{"Key": {serverKey}}
+}
 
-	defer response.Body.Close()
+// StartDownload : Function to start download
+func (s *AdminService) StartDownload(ctx context.Context, serverKey string) (string, error) {
+	return s.client.call(ctx, "GET", "/api/download/start", nil, serverKeyHeader(serverKey))
+}
 
-	var r interface{}
-	if err := json.NewDecoder(response.Body).Decode(&r); err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	out, err := prettyjson.Marshal(r)
-	if err != nil {
-		logger.InfoLogger.Println(out)
-		logger.ErrorLogger.Panic(err)
-	}
-	logger.InfoLogger.Println(string(out))
+// KillDownload : Function to kill download
+func (s *AdminService) KillDownload(ctx context.Context, serverKey string) (string, error) {
+	return s.client.call(ctx, "GET", "/api/download/kill", nil, serverKeyHeader(serverKey))
 }
 
-// RateDownloadRequest : Function to rate a download request
-func (b *Bassa) RateDownloadRequest(id int, rate int) {
-	if rate == 0 {
-		logger.InfoLogger.Println("Continuing with 0 rating")
-	}
-	endpoint := "/api/download"
-	apiURL := b.apiURL + endpoint + string(id)
-	requestBody, err := json.Marshal(map[string]int{
-		"rate": rate})
-	request, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(requestBody))
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	request.Header.Set("token", b.token)
-	response, err := b.httpClient.Do(request)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
+// AddDownloadRequest : Function to add download request, see Add for adding
+// one with options and getting the new download
+func (s *DownloadsService) AddDownloadRequest(ctx context.Context, downloadLink string) error {
+	if err := validateDownloadLink(downloadLink); err != nil {
+		return err
 	}
+	requestBody := map[string]string{
+		"link": downloadLink}
+	_, err := s.client.call(ctx, "POST", "/api/download", requestBody, nil)
+	return err
+}
 
-	defer response.Body.Close()
+// RemoveDownloadRequest : Function to remove download request, see Remove
+func (s *DownloadsService) RemoveDownloadRequest(ctx context.Context, id int) error {
+	if id <= 0 {
+		return invalidParam("id", "must be positive")
+	}
+	_, err := s.client.call(ctx, "DELETE", "/api/download/"+strconv.Itoa(id), nil, nil)
+	return err
+}
 
-	var r interface{}
-	if err := json.NewDecoder(response.Body).Decode(&r); err != nil {
-		logger.ErrorLogger.Panic(err)
+// RateDownloadRequest : Function to rate a download request, see Rate
+func (s *DownloadsService) RateDownloadRequest(ctx context.Context, id int, rate int) error {
+	if id <= 0 {
+		return invalidParam("id", "must be positive")
 	}
-	out, err := prettyjson.Marshal(r)
-	if err != nil {
-		logger.InfoLogger.Println(out)
-		logger.ErrorLogger.Panic(err)
+	if rate < MinRating || rate > MaxRating {
+		return invalidParam("rate", fmt.Sprintf("must be from %d to %d", MinRating, MaxRating))
 	}
-	logger.InfoLogger.Println(string(out))
+	requestBody := map[string]int{
+		"rate": rate}
+	_, err := s.client.call(ctx, "POST", "/api/download/"+strconv.Itoa(id), requestBody, nil)
+	return err
 }
 
 // GetDownloadRequests : Function to get all download requests
-func (b *Bassa) GetDownloadRequests(limit int) string {
+func (s *DownloadsService) GetDownloadRequests(ctx context.Context, limit int) ([]Download, error) {
 	if limit == 0 {
-		panic(errIncompleteParams)
+		return nil, invalidParam("limit", "must not be 0")
 	}
-	endpoint := "/api/downloads"
-	apiURL := b.apiURL + endpoint + "/" + string(limit)
-
-	request, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	request.Header.Set("token", b.token)
-	response, err := b.httpClient.Do(request)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
+	var downloads []Download
+	if err := s.client.do(ctx, "GET", "/api/downloads/"+strconv.Itoa(limit), nil, &downloads); err != nil {
+		return nil, err
 	}
-
-	defer response.Body.Close()
-	var r interface{}
-	if err := json.NewDecoder(response.Body).Decode(&r); err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	out, err := prettyjson.Marshal(r)
-	if err != nil {
-		logger.InfoLogger.Println(out)
-		logger.ErrorLogger.Panic(err)
-	}
-	logger.InfoLogger.Println(string(out))
-	return string(out)
+	return downloads, nil
 }
 
 // GetDownloadRequest : Function to get a download request
-func (b *Bassa) GetDownloadRequest(id int) string {
-
-	endpoint := "/api/download"
-	apiURL := b.apiURL + endpoint + "/" + string(id)
-
-	request, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	request.Header.Set("token", b.token)
-	response, err := b.httpClient.Do(request)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-
-	defer response.Body.Close()
-	var r interface{}
-	if err := json.NewDecoder(response.Body).Decode(&r); err != nil {
-		logger.ErrorLogger.Panic(err)
+func (s *DownloadsService) GetDownloadRequest(ctx context.Context, id int) (Download, error) {
+	if id <= 0 {
+		return Download{}, invalidParam("id", "must be positive")
 	}
-	out, err := prettyjson.Marshal(r)
-	if err != nil {
-		logger.InfoLogger.Println(out)
-		logger.ErrorLogger.Panic(err)
+	var download Download
+	if err := s.client.do(ctx, "GET", "/api/download/"+strconv.Itoa(id), nil, &download); err != nil {
+		return Download{}, err
 	}
-	logger.InfoLogger.Println(string(out))
-	return string(out)
+	return download, nil
 }
 
 // StartCompression : Function to start compression of files
-func (b *Bassa) StartCompression(gidList []string) {
+func (s *DownloadsService) StartCompression(ctx context.Context, gidList []string) error {
 	if len(gidList) == 0 {
-		panic(errIncompleteParams)
-	}
-	endpoint := "/api/compress"
-	apiURL := b.apiURL + endpoint
-	requestBody, err := json.Marshal(map[string][]string{
-		"gid": gidList})
-	request, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(requestBody))
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	request.Header.Set("token", b.token)
-	response, err := b.httpClient.Do(request)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-
-	defer response.Body.Close()
-	var r interface{}
-	if err := json.NewDecoder(response.Body).Decode(&r); err != nil {
-		logger.ErrorLogger.Panic(err)
+		return invalidParam("gidList", "must not be empty")
 	}
-	out, err := prettyjson.Marshal(r)
-	if err != nil {
-		logger.InfoLogger.Println(out)
-		logger.ErrorLogger.Panic(err)
-	}
-	logger.InfoLogger.Println(string(out))
+	requestBody := map[string][]string{
+		"gid": gidList}
+	_, err := s.client.call(ctx, "POST", "/api/compress", requestBody, nil)
+	return err
 }
 
 // GetCompressionProgress : Function to get compression progress
-func (b *Bassa) GetCompressionProgress(id int) string {
-
-	endpoint := "/api/compression-progress"
-	apiURL := b.apiURL + endpoint + "/" + string(id)
-
-	request, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	request.Header.Set("token", b.token)
-	response, err := b.httpClient.Do(request)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-
-	defer response.Body.Close()
-	var r interface{}
-	if err := json.NewDecoder(response.Body).Decode(&r); err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	out, err := prettyjson.Marshal(r)
-	if err != nil {
-		logger.InfoLogger.Println(out)
-		logger.ErrorLogger.Panic(err)
-	}
-	logger.InfoLogger.Println(string(out))
-	return string(out)
+func (s *DownloadsService) GetCompressionProgress(ctx context.Context, id int) (string, error) {
+	return s.client.call(ctx, "GET", "/api/compression-progress/"+strconv.Itoa(id), nil, nil)
 }
 
 // SendFileFromPath : Function to send file from the local server
-func (b *Bassa) SendFileFromPath(id int) string {
-
-	endpoint := "/api/file"
-	apiURL := b.apiURL + endpoint
-	requestBody, err := json.Marshal(map[string]int{
-		"gid": id})
-	request, err := http.NewRequest("GET", apiURL, bytes.NewBuffer(requestBody))
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	request.Header.Set("token", b.token)
-	response, err := b.httpClient.Do(request)
-	if err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-
-	defer response.Body.Close()
-	var r interface{}
-	if err := json.NewDecoder(response.Body).Decode(&r); err != nil {
-		logger.ErrorLogger.Panic(err)
-	}
-	out, err := prettyjson.Marshal(r)
-	if err != nil {
-		logger.InfoLogger.Println(out)
-		logger.ErrorLogger.Panic(err)
-	}
-	logger.InfoLogger.Println(string(out))
-	return string(out)
+func (s *DownloadsService) SendFileFromPath(ctx context.Context, id int) (string, error) {
+	requestBody := map[string]int{
+		"gid": id}
+	return s.client.call(ctx, "GET", "/api/file", requestBody, nil)
 }
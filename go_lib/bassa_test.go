@@ -0,0 +1,402 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient : Helper function returning a client talking to handler
+func newTestClient(t *testing.T, handler http.Handler, opts ...Option) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	b := &Client{}
+	b.Init(server.URL, 1000, 0, opts...)
+	return b
+}
+
+// newOfflineClient : Helper function returning a client with no server, for
+// calls expected to fail before sending anything
+func newOfflineClient() *Client {
+	b := &Client{}
+	b.initServices()
+	return b
+}
+
+// countingTransport : Transport counting the connections it dials
+func countingTransport(dials *int32) *http.Transport {
+	dialer := &net.Dialer{}
+	return &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			atomic.AddInt32(dials, 1)
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}
+}
+
+func TestWarmupReusesConnection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+
+	var dials int32
+	b := &Client{}
+	b.Init(server.URL, 1000, 0)
+	b.httpClient = newHTTPClient(&http.Client{Transport: countingTransport(&dials)}, 0)
+
+	if err := b.Warmup(context.Background()); err != nil {
+		t.Fatalf("Warmup: %v", err)
+	}
+	if got := atomic.LoadInt32(&dials); got != 1 {
+		t.Fatalf("dials after Warmup = %d, want 1", got)
+	}
+	if _, err := b.Users.GetUserRequest(context.Background(), ListOptions{}); err != nil {
+		t.Fatalf("GetUserRequest: %v", err)
+	}
+	if got := atomic.LoadInt32(&dials); got != 1 {
+		t.Errorf("dials after first call = %d, want 1 (connection not reused)", got)
+	}
+}
+
+func TestWarmupTransportError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	b := &Client{}
+	b.Init(url, 1000, 0)
+	if err := b.Warmup(context.Background()); err == nil {
+		t.Fatal("Warmup against a closed server returned nil error")
+	}
+}
+
+func TestLoginDuplicatedTokenHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Token", "stale-token")
+		w.Header().Add("Token", "  fresh-token ")
+		w.Header().Add("Token", "")
+		w.Write([]byte(`{"status": "success"}`))
+	}))
+	defer server.Close()
+
+	b := &Client{}
+	b.Init(server.URL, 1000, 0)
+	if err := b.Auth.Login(context.Background(), "rand", "pass"); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if b.token != "fresh-token" {
+		t.Errorf("token = %q, want %q", b.token, "fresh-token")
+	}
+}
+
+func TestTokenFromHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    string
+		wantErr bool
+	}{
+		{name: "single", values: []string{"abc"}, want: "abc"},
+		{name: "trimmed", values: []string{" abc\t"}, want: "abc"},
+		{name: "last wins", values: []string{"abc", "def"}, want: "def"},
+		{name: "trailing empty", values: []string{"abc", " "}, want: "abc"},
+		{name: "folded", values: []string{"abc, def"}, want: "def"},
+		{name: "all empty", values: []string{"", " "}, wantErr: true},
+		{name: "missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for _, value := range tt.values {
+				header.Add("Token", value)
+			}
+			got, err := tokenFromHeader(header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewWithFailover(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	downURL := down.URL
+	down.Close()
+
+	var hits int32
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte(`[{"user_name": "rand", "bytes_used": 1}]`))
+	}))
+	defer backup.Close()
+
+	b, err := NewWithFailover(downURL, []string{backup.URL}, WithRequestCompression())
+	if err != nil {
+		t.Fatalf("NewWithFailover: %v", err)
+	}
+	if !b.compressRequests {
+		t.Error("options were not applied")
+	}
+	for i := 0; i < 2; i++ {
+		usage, err := b.Admin.GetStorageByUser(context.Background())
+		if err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+		if len(usage) != 1 {
+			t.Fatalf("call %d: got %+v", i, usage)
+		}
+	}
+	if got := atomic.LoadInt32(&hits); got != 2 {
+		t.Errorf("backup hits = %d, want 2", got)
+	}
+	if got := b.currentAPIURL(); got != backup.URL {
+		t.Errorf("current endpoint = %s, want the backup %s", got, backup.URL)
+	}
+}
+
+func TestLoginFailover(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	downURL := down.URL
+	down.Close()
+
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/login" || r.FormValue("user_name") != "rand" {
+			t.Errorf("unexpected request %s %s", r.URL.Path, r.FormValue("user_name"))
+		}
+		w.Header().Set("token", "backup-token")
+	}))
+	defer backup.Close()
+
+	b, err := NewWithFailover(downURL, []string{backup.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Auth.Login(context.Background(), "rand", "secret"); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if b.token != "backup-token" {
+		t.Errorf("token = %q, want backup-token", b.token)
+	}
+	if got := b.currentAPIURL(); got != backup.URL {
+		t.Errorf("current endpoint = %s, want the backup %s", got, backup.URL)
+	}
+}
+
+func TestFailoverIgnoresClientErrors(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer primary.Close()
+	var hits int32
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer backup.Close()
+
+	b, err := NewWithFailover(primary.URL, []string{backup.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Admin.GetStorageByUser(context.Background()); !errors.Is(err, ErrForbidden) {
+		t.Errorf("got %v, want ErrForbidden", err)
+	}
+	if got := atomic.LoadInt32(&hits); got != 0 {
+		t.Errorf("backup hits = %d, want 0", got)
+	}
+}
+
+func TestFailoverAllDown(t *testing.T) {
+	var urls []string
+	for i := 0; i < 2; i++ {
+		server := httptest.NewServer(http.NotFoundHandler())
+		urls = append(urls, server.URL)
+		server.Close()
+	}
+	b, err := NewWithFailover(urls[0], urls[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.httpClient = newHTTPClient(b.doer, 0)
+	if _, err := b.Admin.GetStorageByUser(context.Background()); err == nil {
+		t.Fatal("expected an error with every endpoint down")
+	}
+}
+
+func TestNewWithFailoverValidation(t *testing.T) {
+	if _, err := NewWithFailover("http://localhost:5000", []string{"localhost:5001"}); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("got %v, want ErrInvalidParams", err)
+	}
+	if _, err := NewWithFailover("", nil); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestInitValidation(t *testing.T) {
+	var errs ValidationErrors
+	if err := (&Client{}).Init("", 0, 0); !errors.As(err, &errs) || !reflect.DeepEqual(errs.Fields(), []string{"apiURL", "timeout"}) {
+		t.Errorf("got %v, want apiURL and timeout errors", err)
+	}
+	if err := (&Client{}).Init("http://localhost:5000", 1000, 0, WithAPIKey("")); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("invalid option: got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestLegacyMethodErrors(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/download/3" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message": "no download 3"}`))
+	}))
+	if _, err := b.Downloads.GetDownloadRequest(context.Background(), 3); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetDownloadRequest = %v, want ErrNotFound", err)
+	}
+	var apiErr *APIError
+	if err := b.Downloads.RemoveDownloadRequest(context.Background(), 3); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("RemoveDownloadRequest = %v, want a 404 APIError", err)
+	}
+	if err := b.Users.RemoveUserRequest(context.Background(), ""); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("RemoveUserRequest empty user = %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestLegacyDownloadValidation(t *testing.T) {
+	b := newOfflineClient()
+	ctx := context.Background()
+	for _, id := range []int{0, -1} {
+		if _, err := b.Downloads.GetDownloadRequest(ctx, id); !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("GetDownloadRequest(%d) = %v, want ErrInvalidParams", id, err)
+		}
+		if err := b.Downloads.RemoveDownloadRequest(ctx, id); !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("RemoveDownloadRequest(%d) = %v, want ErrInvalidParams", id, err)
+		}
+		if err := b.Downloads.RateDownloadRequest(ctx, id, 3); !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("RateDownloadRequest(%d) = %v, want ErrInvalidParams", id, err)
+		}
+	}
+	for _, rate := range []int{MinRating - 1, MaxRating + 1} {
+		var invalid *ValidationError
+		if err := b.Downloads.RateDownloadRequest(ctx, 3, rate); !errors.As(err, &invalid) || invalid.Field != "rate" {
+			t.Errorf("RateDownloadRequest rate %d = %v, want a rate violation", rate, err)
+		}
+	}
+}
+
+func TestTypedListResponses(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/user/downloads":
+			w.Write([]byte(`[{"id": 1, "link": "http://example.com/a.iso", "status": "completed", "size": 2048, "rating": 4}]`))
+		case "/api/user/heavy":
+			w.Write([]byte(`[{"user_name": "rand", "size": 4096}, {"user_name": "mat", "size": 10}]`))
+		case "/api/user/requests":
+			w.Write([]byte(`[{"user_name": "new", "email": "new@bassa.org"}]`))
+		case "/api/user/blocked":
+			w.Write([]byte(`[{"user_name": "spam", "email": "spam@bassa.org", "reason": "abuse"}]`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	ctx := context.Background()
+	records, err := b.Downloads.GetDownloadUserRequests(ctx, ListDownloadsOptions{})
+	if err != nil {
+		t.Fatalf("GetDownloadUserRequests: %v", err)
+	}
+	if want := []DownloadRecord{{ID: 1, Link: "http://example.com/a.iso", Status: StatusCompleted, Size: 2048, Rating: 4}}; !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %+v, want %+v", records, want)
+	}
+	heavy, err := b.Admin.GetToptenHeaviestUsers(ctx)
+	if err != nil {
+		t.Fatalf("GetToptenHeaviestUsers: %v", err)
+	}
+	if want := []HeavyUser{{UserName: "rand", Size: 4096}, {UserName: "mat", Size: 10}}; !reflect.DeepEqual(heavy, want) {
+		t.Errorf("heavy users = %+v, want %+v", heavy, want)
+	}
+	signups, err := b.Admin.GetUserSignupRequests(ctx, ListOptions{})
+	if err != nil {
+		t.Fatalf("GetUserSignupRequests: %v", err)
+	}
+	if want := []SignupRequest{{UserName: "new", Email: "new@bassa.org"}}; !reflect.DeepEqual(signups, want) {
+		t.Errorf("signups = %+v, want %+v", signups, want)
+	}
+	blocked, err := b.Admin.GetBlockedUserRequests(ctx)
+	if err != nil {
+		t.Fatalf("GetBlockedUserRequests: %v", err)
+	}
+	if want := []BlockedUser{{UserName: "spam", Email: "spam@bassa.org", Reason: "abuse"}}; !reflect.DeepEqual(blocked, want) {
+		t.Errorf("blocked = %+v, want %+v", blocked, want)
+	}
+}
+
+func TestLegacyMethodContext(t *testing.T) {
+	release := make(chan struct{})
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := b.Admin.GetToptenHeaviestUsers(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/api/custom/thing" || r.Header.Get("token") != "tok" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		var body map[string]int
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["n"] == 0 {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		fmt.Fprintf(w, `{"double": %d}`, 2*body["n"])
+	}), WithToken("tok"))
+	var out struct {
+		Double int `json:"double"`
+	}
+	if err := b.Call(context.Background(), "post", "/api/custom/thing", map[string]int{"n": 21}, &out); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if out.Double != 42 {
+		t.Errorf("double = %d, want 42", out.Double)
+	}
+	if err := b.Call(context.Background(), "POST", "/api/custom/thing", map[string]int{}, nil); !errors.Is(err, ErrConflict) {
+		t.Errorf("got %v, want ErrConflict", err)
+	}
+	if err := b.Call(context.Background(), "GET", "api/user", nil, nil); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("relative path: got %v, want ErrInvalidParams", err)
+	}
+}
@@ -0,0 +1,279 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// minServerVersion : Oldest server release the client is known to work with
+const minServerVersion = "0.5.0"
+
+// Names of the checks run by ValidateConnection, in the order they run
+const (
+	CheckReachability = "reachability"
+	CheckTLS          = "tls"
+	CheckAuth         = "auth"
+	CheckVersion      = "version"
+	CheckAPIVersion   = "api"
+)
+
+// CheckResult : Outcome of one check of ValidateConnection. Hint suggests
+// a fix when the check failed or was skipped for a reason worth acting on.
+type CheckResult struct {
+	Name    string
+	Passed  bool
+	Skipped bool
+	Detail  string
+	Hint    string
+}
+
+// ConnectionReport : Results of ValidateConnection, one per check
+type ConnectionReport struct {
+	Checks []CheckResult
+}
+
+// OK : Function to tell whether no check failed. Skipped checks don't count
+// as failures.
+func (r ConnectionReport) OK() bool {
+	return len(r.Failed()) == 0
+}
+
+// Failed : Function to get the checks which failed
+func (r ConnectionReport) Failed() []CheckResult {
+	var failed []CheckResult
+	for _, check := range r.Checks {
+		if !check.Passed && !check.Skipped {
+			failed = append(failed, check)
+		}
+	}
+	return failed
+}
+
+// Check : Function to get the result of the named check
+func (r ConnectionReport) Check(name string) (CheckResult, bool) {
+	for _, check := range r.Checks {
+		if check.Name == name {
+			return check, true
+		}
+	}
+	return CheckResult{}, false
+}
+
+// ValidateConnection : Function to diagnose the client configuration against
+// the live server, e.g. for setup wizards. It checks that the server can be
+// reached, that TLS verifies, that the credentials are accepted and that the
+// server release is supported. Failures are reported in the returned report;
+// the error is only set when ctx ends first. Checks depending on a failed one
+// are skipped.
+func (b *Client) ValidateConnection(ctx context.Context) (ConnectionReport, error) {
+	var report ConnectionReport
+	add := func(check CheckResult) {
+		report.Checks = append(report.Checks, check)
+	}
+
+	response, err := b.probe(ctx)
+	if ctx.Err() != nil {
+		return report, ctx.Err()
+	}
+	secure := strings.HasPrefix(b.currentAPIURL(), "https:")
+	switch {
+	case err != nil && isTLSError(err):
+		add(CheckResult{Name: CheckReachability, Passed: true, Detail: "server reached"})
+		add(CheckResult{Name: CheckTLS, Detail: err.Error(), Hint: "the server certificate doesn't verify; install its CA or fix the host name in the URL"})
+	case err != nil:
+		add(CheckResult{Name: CheckReachability, Detail: err.Error(), Hint: "check the API URL, that the server is running and that no firewall blocks it"})
+		add(CheckResult{Name: CheckTLS, Skipped: true, Detail: "server unreachable"})
+	case secure && response.TLS != nil:
+		add(CheckResult{Name: CheckReachability, Passed: true, Detail: response.Status})
+		add(CheckResult{Name: CheckTLS, Passed: true, Detail: tls.VersionName(response.TLS.Version)})
+	default:
+		add(CheckResult{Name: CheckReachability, Passed: true, Detail: response.Status})
+		add(CheckResult{Name: CheckTLS, Skipped: true, Detail: "connection is not encrypted", Hint: "use an https URL so that credentials aren't sent in the clear"})
+	}
+	if err != nil {
+		add(CheckResult{Name: CheckAuth, Skipped: true, Detail: "no connection"})
+		add(CheckResult{Name: CheckVersion, Skipped: true, Detail: "no connection"})
+		add(CheckResult{Name: CheckAPIVersion, Skipped: true, Detail: "no connection"})
+		return report, nil
+	}
+
+	authCheck, err := b.checkAuth(ctx)
+	if err != nil {
+		return report, err
+	}
+	add(authCheck)
+	versionCheck, err := b.checkVersion(ctx)
+	if err != nil {
+		return report, err
+	}
+	add(versionCheck)
+	apiCheck, err := b.checkAPIVersion(ctx)
+	if err != nil {
+		return report, err
+	}
+	add(apiCheck)
+	return report, nil
+}
+
+// PingResult : Outcome of Ping. Detail says why the server is unavailable,
+// or how it answered otherwise.
+type PingResult struct {
+	Available bool
+	Latency   time.Duration
+	Detail    string
+}
+
+// Ping : Function to check that the server is up, e.g. for readiness probes.
+// It asks the health endpoint, falling back to the server root on servers
+// without one, once and without retries. The server is available when it
+// answers, even if it refuses the credentials, unless it answers with a 5xx
+// status. The error is only set when ctx ends first or the client is
+// misconfigured.
+func (b *Client) Ping(ctx context.Context) (PingResult, error) {
+	if b.configErr != nil {
+		return PingResult{}, b.configErr
+	}
+	start := timeNow()
+	response, err := b.roundTrip(ctx, newHTTPClient(b.doer, 0), "GET", "/api/health", nil, nil)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode == http.StatusMethodNotAllowed) {
+		start = timeNow()
+		response, err = b.probe(ctx)
+	}
+	result := PingResult{Latency: timeNow().Sub(start)}
+	if ctx.Err() != nil {
+		return PingResult{}, ctx.Err()
+	}
+	switch {
+	case errors.As(err, &apiErr):
+		result.Available = apiErr.StatusCode < 500
+		result.Detail = err.Error()
+	case err != nil:
+		result.Detail = err.Error()
+	case response.StatusCode >= 500:
+		result.Detail = response.Status
+	default:
+		result.Available = true
+		result.Detail = response.Status
+	}
+	if response != nil {
+		io.Copy(ioutil.Discard, response.Body)
+		response.Body.Close()
+	}
+	return result, nil
+}
+
+// checkAuth : Function to check that the credentials are accepted
+func (b *Client) checkAuth(ctx context.Context) (CheckResult, error) {
+	check := CheckResult{Name: CheckAuth}
+	if !b.IsAuthenticated() {
+		check.Detail = "no credentials"
+		check.Hint = "call Login or configure WithAPIKey"
+		return check, nil
+	}
+	err := b.do(ctx, "GET", "/api/downloads/1", nil, nil)
+	var apiErr *APIError
+	switch {
+	case ctx.Err() != nil:
+		return check, ctx.Err()
+	case err == nil:
+		check.Passed = true
+		check.Detail = "credentials accepted"
+	case errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden):
+		check.Detail = err.Error()
+		check.Hint = "the token may have expired or the API key been revoked; log in again or issue a new key"
+	default:
+		check.Detail = err.Error()
+		check.Hint = "the server failed an authenticated request; check its logs"
+	}
+	return check, nil
+}
+
+// checkVersion : Function to check that the server release is supported
+func (b *Client) checkVersion(ctx context.Context) (CheckResult, error) {
+	check := CheckResult{Name: CheckVersion}
+	info, err := b.getServerInfo(ctx)
+	switch {
+	case ctx.Err() != nil:
+		return check, ctx.Err()
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrNotSupported), err == nil && info.Version == "":
+		check.Skipped = true
+		check.Detail = "server doesn't report its version"
+	case err != nil:
+		check.Detail = err.Error()
+		check.Hint = "the server failed to describe itself; check its logs"
+	case compareVersions(info.Version, minServerVersion) < 0:
+		check.Detail = "server version " + info.Version
+		check.Hint = "upgrade the server to " + minServerVersion + " or later"
+	default:
+		check.Passed = true
+		check.Detail = "server version " + info.Version
+	}
+	return check, nil
+}
+
+// checkAPIVersion : Function to check that the server supports the API
+// version of the client
+func (b *Client) checkAPIVersion(ctx context.Context) (CheckResult, error) {
+	check := CheckResult{Name: CheckAPIVersion}
+	min, max, err := b.GetAPIVersions(ctx)
+	switch {
+	case ctx.Err() != nil:
+		return check, ctx.Err()
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrNotSupported):
+		check.Skipped = true
+		check.Detail = "server doesn't report its API versions"
+		return check, nil
+	case err != nil:
+		check.Detail = err.Error()
+		check.Hint = "the server reported unreadable API versions; check its release"
+		return check, nil
+	}
+	check.Detail = "client API " + clientAPIVersion + ", server supports " + min + " to " + max
+	switch apiCompatibility(min, max) {
+	case -1:
+		check.Hint = "the client is too old for the server; upgrade the client library"
+	case 1:
+		check.Hint = "the client is too new for the server; upgrade the server or use an older client"
+	default:
+		check.Passed = true
+	}
+	return check, nil
+}
+
+// isTLSError : Helper function to tell handshake and certificate failures
+// from other transport errors. The retrying client flattens errors into
+// text, so their messages are inspected as well.
+func isTLSError(err error) bool {
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	var verification *tls.CertificateVerificationError
+	var header tls.RecordHeaderError
+	if errors.As(err, &unknownAuthority) || errors.As(err, &hostname) ||
+		errors.As(err, &invalid) || errors.As(err, &verification) || errors.As(err, &header) {
+		return true
+	}
+	message := err.Error()
+	return strings.Contains(message, "x509:") || strings.Contains(message, "tls:")
+}
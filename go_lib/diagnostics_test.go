@@ -0,0 +1,249 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// validationServer : Handler answering like a server of the given version,
+// rejecting requests without the expected token. Servers from 1.1.0 on
+// support API versions 1.0 to 1.1.
+func validationServer(version string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+		case "/api/versions":
+			if version == "" || compareVersions(version, "1.1.0") < 0 {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`{"min": "1.0", "max": "1.1"}`))
+		case "/api/info":
+			if version == "" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`{"version": "` + version + `"}`))
+		default:
+			if r.Header.Get("token") != "good" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`[]`))
+		}
+	}
+}
+
+// reportSummary : Helper function to condense a report into name=state pairs
+func reportSummary(report ConnectionReport) string {
+	var parts []string
+	for _, check := range report.Checks {
+		state := "fail"
+		if check.Passed {
+			state = "pass"
+		} else if check.Skipped {
+			state = "skip"
+		}
+		parts = append(parts, check.Name+"="+state)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestValidateConnection(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		token   string
+		want    string
+		ok      bool
+	}{
+		{"all good", "1.2.0", "good", "reachability=pass tls=skip auth=pass version=pass api=pass", true},
+		{"no credentials", "1.2.0", "", "reachability=pass tls=skip auth=fail version=pass api=pass", false},
+		{"rejected token", "1.2.0", "stale", "reachability=pass tls=skip auth=fail version=pass api=pass", false},
+		{"old server", "0.4.9", "good", "reachability=pass tls=skip auth=pass version=fail api=skip", false},
+		{"unknown version", "", "good", "reachability=pass tls=skip auth=pass version=skip api=skip", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestClient(t, validationServer(tt.version))
+			b.token = tt.token
+			report, err := b.ValidateConnection(context.Background())
+			if err != nil {
+				t.Fatalf("ValidateConnection: %v", err)
+			}
+			if got := reportSummary(report); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			if report.OK() != tt.ok {
+				t.Errorf("OK = %v, want %v", report.OK(), tt.ok)
+			}
+			for _, check := range report.Failed() {
+				if check.Hint == "" {
+					t.Errorf("failed check %s has no hint", check.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateConnectionUnreachable(t *testing.T) {
+	server := httptest.NewServer(validationServer("1.0.0"))
+	server.Close()
+	b := &Client{}
+	b.Init(server.URL, 1000, 0)
+	report, err := b.ValidateConnection(context.Background())
+	if err != nil {
+		t.Fatalf("ValidateConnection: %v", err)
+	}
+	if got, want := reportSummary(report), "reachability=fail tls=skip auth=skip version=skip api=skip"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestValidateConnectionTLS(t *testing.T) {
+	server := httptest.NewTLSServer(validationServer("1.0.0"))
+	defer server.Close()
+
+	// The test certificate isn't trusted by a default client
+	b := &Client{}
+	b.Init(server.URL, 1000, 0)
+	b.token = "good"
+	report, err := b.ValidateConnection(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := reportSummary(report), "reachability=pass tls=fail auth=skip version=skip api=skip"; got != want {
+		t.Errorf("untrusted: got %s, want %s", got, want)
+	}
+
+	b.doer = server.Client()
+	b.httpClient = newHTTPClient(b.doer, 0)
+	report, err = b.ValidateConnection(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := reportSummary(report), "reachability=pass tls=pass auth=pass version=pass api=skip"; got != want {
+		t.Errorf("trusted: got %s, want %s", got, want)
+	}
+	if check, _ := report.Check(CheckTLS); !strings.HasPrefix(check.Detail, "TLS") {
+		t.Errorf("TLS detail = %q", check.Detail)
+	}
+}
+
+func TestValidateConnectionCancelled(t *testing.T) {
+	b := newTestClient(t, validationServer("1.0.0"))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := b.ValidateConnection(ctx); err != context.Canceled {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestValidateConnectionAPIVersions(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     string
+		hint     string
+	}{
+		{"supported", `{"min": "1.0", "max": "2.0"}`, "pass", ""},
+		{"client too old", `{"min": "v2.0.0", "max": "3.0"}`, "fail", "too old"},
+		{"client too new", `{"min": "0.1", "max": "0.9-beta"}`, "fail", "too new"},
+		{"garbage", `{"min": "latest", "max": "3.0"}`, "fail", "unreadable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/api/versions" {
+					w.Write([]byte(tt.response))
+					return
+				}
+				validationServer("1.0.0")(w, r)
+			}))
+			b.token = "good"
+			report, err := b.ValidateConnection(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			check, _ := report.Check(CheckAPIVersion)
+			if state := map[bool]string{true: "pass", false: "fail"}[check.Passed]; state != tt.want {
+				t.Errorf("api check = %+v, want %s", check, tt.want)
+			}
+			if !strings.Contains(check.Hint, tt.hint) {
+				t.Errorf("hint = %q, want it to mention %q", check.Hint, tt.hint)
+			}
+		})
+	}
+}
+
+func TestPing(t *testing.T) {
+	tests := []struct {
+		name      string
+		handler   http.HandlerFunc
+		available bool
+	}{
+		{"health endpoint", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/health" {
+				t.Errorf("unexpected request to %s", r.URL.Path)
+			}
+			w.Write([]byte(`{"status": "ok"}`))
+		}, true},
+		{"no health endpoint", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/api/health" {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}, true},
+		{"credentials refused", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		}, true},
+		{"server failing", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestClient(t, tt.handler)
+			result, err := b.Ping(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Available != tt.available || result.Detail == "" || result.Latency < 0 {
+				t.Errorf("got %+v, want available %v", result, tt.available)
+			}
+		})
+	}
+}
+
+func TestPingUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	b, err := NewClient(server.URL, WithRetryCount(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := b.Ping(context.Background())
+	if err != nil || result.Available || result.Detail == "" {
+		t.Errorf("got %+v, %v; want unavailable", result, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := b.Ping(ctx); err != context.Canceled {
+		t.Errorf("cancelled: got %v", err)
+	}
+}
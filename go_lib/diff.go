@@ -0,0 +1,43 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+// DiffDownloads : Function to compare two snapshots of a download listing.
+// Downloads are matched by ID: added holds the ones only in newer, removed
+// the ones only in older and changed the newer copy of those whose status or
+// progress moved. Each result keeps the order of the snapshot it came from.
+func DiffDownloads(older, newer []Download) (added, removed, changed []Download) {
+	before := make(map[int]Download, len(older))
+	for _, download := range older {
+		before[download.ID] = download
+	}
+	after := make(map[int]bool, len(newer))
+	for _, download := range newer {
+		after[download.ID] = true
+		previous, ok := before[download.ID]
+		if !ok {
+			added = append(added, download)
+			continue
+		}
+		if previous.Status != download.Status || previous.Progress != download.Progress {
+			changed = append(changed, download)
+		}
+	}
+	for _, download := range older {
+		if !after[download.ID] {
+			removed = append(removed, download)
+		}
+	}
+	return added, removed, changed
+}
@@ -0,0 +1,90 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffDownloads(t *testing.T) {
+	queued := Download{ID: 1, Status: StatusQueued}
+	running := Download{ID: 1, Status: StatusDownloading, Progress: 10}
+	halfway := Download{ID: 1, Status: StatusDownloading, Progress: 50}
+	done := Download{ID: 2, Status: StatusCompleted, Progress: 100}
+	renamed := Download{ID: 2, Status: StatusCompleted, Progress: 100, Name: "score.jpg"}
+	other := Download{ID: 3, Status: StatusQueued}
+
+	tests := []struct {
+		name                   string
+		older, newer           []Download
+		added, removed, change []Download
+	}{
+		{name: "both empty"},
+		{
+			name:  "everything added",
+			newer: []Download{queued, done},
+			added: []Download{queued, done},
+		},
+		{
+			name:    "everything removed",
+			older:   []Download{queued, done},
+			removed: []Download{queued, done},
+		},
+		{
+			name:  "unchanged",
+			older: []Download{queued, done},
+			newer: []Download{done, queued},
+		},
+		{
+			name:   "status change",
+			older:  []Download{queued},
+			newer:  []Download{running},
+			change: []Download{running},
+		},
+		{
+			name:   "progress change",
+			older:  []Download{running},
+			newer:  []Download{halfway},
+			change: []Download{halfway},
+		},
+		{
+			name:  "other fields ignored",
+			older: []Download{done},
+			newer: []Download{renamed},
+		},
+		{
+			name:    "mixed",
+			older:   []Download{queued, done},
+			newer:   []Download{other, halfway},
+			added:   []Download{other},
+			removed: []Download{done},
+			change:  []Download{halfway},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed, changed := DiffDownloads(tt.older, tt.newer)
+			if !reflect.DeepEqual(added, tt.added) {
+				t.Errorf("added = %+v, want %+v", added, tt.added)
+			}
+			if !reflect.DeepEqual(removed, tt.removed) {
+				t.Errorf("removed = %+v, want %+v", removed, tt.removed)
+			}
+			if !reflect.DeepEqual(changed, tt.change) {
+				t.Errorf("changed = %+v, want %+v", changed, tt.change)
+			}
+		})
+	}
+}
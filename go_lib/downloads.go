@@ -0,0 +1,614 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DownloadStatus : State of a download on the server
+type DownloadStatus string
+
+// Download states reported by the server
+const (
+	StatusQueued      DownloadStatus = "queued"
+	StatusDownloading DownloadStatus = "downloading"
+	StatusCompleted   DownloadStatus = "completed"
+	StatusFailed      DownloadStatus = "failed"
+)
+
+var downloadStatuses = map[DownloadStatus]bool{
+	StatusQueued:      true,
+	StatusDownloading: true,
+	StatusCompleted:   true,
+	StatusFailed:      true,
+}
+
+// Download : A download queued on the Bassa server
+type Download struct {
+	ID       int    `json:"id"`
+	Link     string `json:"link"`
+	UserName string `json:"user_name"`
+	// Name is the display name, derived from the link unless renamed
+	Name     string         `json:"download_name"`
+	Status   DownloadStatus `json:"status"`
+	Category string         `json:"category"`
+	// Destination is the directory the files are saved to
+	Destination string `json:"destination"`
+	Size        int64  `json:"size"`
+	// Progress is the completed percentage, 0 to 100
+	Progress float64 `json:"progress"`
+	// Downloaded is the number of bytes fetched so far
+	Downloaded int64 `json:"downloaded"`
+	// Uploaded is the number of bytes seeded to other peers, for torrents
+	Uploaded int64 `json:"uploaded"`
+	// Speed is the current transfer rate in bytes per second
+	Speed   int64     `json:"speed"`
+	AddedAt time.Time `json:"added_time"`
+	// StartedAt is when the transfer began, zero if the server doesn't say
+	StartedAt   time.Time `json:"started_time"`
+	CompletedAt time.Time `json:"completed_time"`
+	// BandwidthLimit is the download's own cap in bytes per second, 0 for unlimited
+	BandwidthLimit int64 `json:"bandwidth_limit"`
+	// ExpiresAt is when the server deletes the completed download, zero for never
+	ExpiresAt time.Time `json:"expires_at"`
+	Note      string    `json:"note"`
+	Tags      []string  `json:"tags"`
+	// FailureReason explains a failed download, if the server reports it
+	FailureReason string `json:"failure_reason"`
+}
+
+// ListDownloadsOptions : Narrows down the download history returned by
+// GetDownloadUserRequests. The zero value gets all of it.
+type ListDownloadsOptions struct {
+	// Limit is the maximum number of records, 0 for no limit
+	Limit  int
+	Offset int
+	// Status, when set, only keeps the downloads in that state
+	Status DownloadStatus
+	// Since, when set, only keeps the downloads added from then on
+	Since time.Time
+}
+
+// query : Function to encode the options as query parameters
+func (o ListDownloadsOptions) query() (url.Values, error) {
+	var errs ValidationErrors
+	if o.Limit < 0 {
+		errs = append(errs, &ValidationError{Field: "limit", Reason: "must not be negative"})
+	}
+	if o.Offset < 0 {
+		errs = append(errs, &ValidationError{Field: "offset", Reason: "must not be negative"})
+	}
+	if o.Status != "" && !downloadStatuses[o.Status] {
+		errs = append(errs, &ValidationError{Field: "status", Reason: "has unknown value " + strconv.Quote(string(o.Status))})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	query := url.Values{}
+	if o.Status != "" {
+		query.Set("status", string(o.Status))
+	}
+	setPaging(query, o.Since, o.Limit, o.Offset)
+	return query, nil
+}
+
+// DownloadRecord : Entry in the download history of the logged in user
+type DownloadRecord struct {
+	ID          int            `json:"id"`
+	Link        string         `json:"link"`
+	Name        string         `json:"download_name"`
+	Status      DownloadStatus `json:"status"`
+	Size        int64          `json:"size"`
+	AddedAt     time.Time      `json:"added_time"`
+	CompletedAt time.Time      `json:"completed_time"`
+	// Rating is the one given with RateDownloadRequest, 0 if unrated
+	Rating int `json:"rating"`
+}
+
+// ErrETAUnknown : No estimate is possible as nothing is being transferred
+var ErrETAUnknown = errors.New("completion time unknown: no download is progressing")
+
+// ErrStillRunning : The download is queued or in progress, so it hasn't
+// failed (yet)
+var ErrStillRunning = errors.New("download still running")
+
+// MaxNoteLength : Longest note, in characters, accepted by SetDownloadNote
+const MaxNoteLength = 1024
+
+// Range of the ratings accepted by Rate
+const (
+	MinRating = 1
+	MaxRating = 5
+)
+
+// downloadEndpoint : Helper function to build the endpoint of a single download
+func downloadEndpoint(downloadID int, action string) string {
+	endpoint := "/api/download/" + strconv.Itoa(downloadID)
+	if action != "" {
+		endpoint += "/" + action
+	}
+	return endpoint
+}
+
+// getDownload : Function to get the current state of one download
+func (b *Client) getDownload(ctx context.Context, downloadID int) (Download, error) {
+	var download Download
+	if err := b.do(ctx, "GET", downloadEndpoint(downloadID, ""), nil, &download); err != nil {
+		return Download{}, err
+	}
+	return download, nil
+}
+
+// GetDownloadFailureReason : Function to get why a download failed. The
+// reason comes from the download itself or, for servers keeping it apart,
+// from its failure endpoint. Downloads still queued or in progress return
+// ErrStillRunning; a completed download has no reason. Servers tracking no
+// reasons return ErrNotSupported.
+func (s *DownloadsService) GetDownloadFailureReason(ctx context.Context, downloadID int) (string, error) {
+	if downloadID <= 0 {
+		return "", invalidParam("downloadID", "must be positive")
+	}
+	download, err := s.client.getDownload(ctx, downloadID)
+	if err != nil {
+		return "", err
+	}
+	switch download.Status {
+	case StatusQueued, StatusDownloading:
+		return "", fmt.Errorf("download %d is %s: %w", downloadID, download.Status, ErrStillRunning)
+	case StatusCompleted:
+		return "", nil
+	}
+	if download.FailureReason != "" {
+		return download.FailureReason, nil
+	}
+	var failure struct {
+		Reason string `json:"reason"`
+	}
+	if err := s.client.do(ctx, "GET", downloadEndpoint(downloadID, "failure"), nil, &failure); err != nil {
+		// The download exists, so a 404 is the endpoint missing
+		if errors.Is(err, ErrNotFound) {
+			return "", fmt.Errorf("failure reason: %w", ErrNotSupported)
+		}
+		return "", err
+	}
+	return failure.Reason, nil
+}
+
+// SetDownloadBandwidthLimit : Function to cap the bandwidth of one download.
+// A limit of 0 removes the cap. Servers without per-download limits return
+// ErrNotSupported.
+func (s *DownloadsService) SetDownloadBandwidthLimit(ctx context.Context, downloadID int, bytesPerSec int64) error {
+	if downloadID <= 0 {
+		return invalidParam("downloadID", "must be positive")
+	}
+	if bytesPerSec < 0 {
+		return invalidParam("bytesPerSec", "must not be negative")
+	}
+	body := map[string]int64{"bandwidth_limit": bytesPerSec}
+	return s.client.do(ctx, "PUT", downloadEndpoint(downloadID, "bandwidth"), body, nil)
+}
+
+// SetDownloadExpiry : Function to have the server delete a download ttl after
+// it completes. ttl is sent in whole seconds. Servers without expiry policies
+// return ErrNotSupported.
+func (s *DownloadsService) SetDownloadExpiry(ctx context.Context, downloadID int, ttl time.Duration) error {
+	if downloadID <= 0 {
+		return invalidParam("downloadID", "must be positive")
+	}
+	if ttl < time.Second {
+		return invalidParam("ttl", "must be at least one second")
+	}
+	body := map[string]int64{"ttl": int64(ttl / time.Second)}
+	return s.client.do(ctx, "PUT", downloadEndpoint(downloadID, "expiry"), body, nil)
+}
+
+// RenameDownload : Function to change the display name of a download,
+// e.g. to replace one derived from a magnet link. Servers without renaming
+// return ErrNotSupported.
+func (s *DownloadsService) RenameDownload(ctx context.Context, downloadID int, name string) error {
+	if downloadID <= 0 {
+		return invalidParam("downloadID", "must be positive")
+	}
+	if strings.TrimSpace(name) == "" {
+		return invalidParam("name", "must not be empty")
+	}
+	body := map[string]string{"download_name": name}
+	return s.client.do(ctx, "PUT", downloadEndpoint(downloadID, "name"), body, nil)
+}
+
+// SetDownloadNote : Function to attach a note of at most MaxNoteLength
+// characters to a download. An empty note clears it. Servers without notes
+// return ErrNotSupported.
+func (s *DownloadsService) SetDownloadNote(ctx context.Context, downloadID int, note string) error {
+	if downloadID <= 0 {
+		return invalidParam("downloadID", "must be positive")
+	}
+	if utf8.RuneCountInString(note) > MaxNoteLength {
+		return invalidParam("note", "must be at most "+strconv.Itoa(MaxNoteLength)+" characters")
+	}
+	body := map[string]string{"note": note}
+	return s.client.do(ctx, "PUT", downloadEndpoint(downloadID, "note"), body, nil)
+}
+
+// GetDownloadLogs : Function to get the server-side log lines of a download.
+// Unknown downloads return ErrNotFound.
+func (s *DownloadsService) GetDownloadLogs(ctx context.Context, downloadID int) ([]string, error) {
+	if downloadID <= 0 {
+		return nil, invalidParam("downloadID", "must be positive")
+	}
+	var lines []string
+	if err := s.client.do(ctx, "GET", downloadEndpoint(downloadID, "logs"), nil, &lines); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// StreamDownloadLogs : Function to tail the log of a download, calling fn
+// for every line as the server writes it. It blocks until ctx is cancelled
+// or the server ends the stream. Servers without live tailing return
+// ErrNotSupported; GetDownloadLogs still works with those.
+func (s *DownloadsService) StreamDownloadLogs(ctx context.Context, downloadID int, fn func(line string)) error {
+	if downloadID <= 0 {
+		return invalidParam("downloadID", "must be positive")
+	}
+	response, err := s.client.stream(ctx, "GET", downloadEndpoint(downloadID, "logs")+"?follow=true", nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fn(scanner.Text())
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return scanner.Err()
+}
+
+// PeerStats : Swarm health of a torrent download
+type PeerStats struct {
+	Seeds     int `json:"seeds"`
+	Peers     int `json:"peers"`
+	Connected int `json:"connected"`
+}
+
+// GetDownloadPeers : Function to get the seed and peer counts of a torrent
+// download. Unknown downloads return ErrNotFound and servers without swarm
+// statistics ErrNotSupported.
+func (s *DownloadsService) GetDownloadPeers(ctx context.Context, downloadID int) (PeerStats, error) {
+	if downloadID <= 0 {
+		return PeerStats{}, invalidParam("downloadID", "must be positive")
+	}
+	var stats PeerStats
+	if err := s.client.do(ctx, "GET", downloadEndpoint(downloadID, "peers"), nil, &stats); err != nil {
+		return PeerStats{}, err
+	}
+	return stats, nil
+}
+
+// GetRecentlyCompleted : Function to get the last limit completed downloads,
+// most recently completed first
+func (s *DownloadsService) GetRecentlyCompleted(ctx context.Context, limit int) ([]Download, error) {
+	if limit <= 0 {
+		return nil, invalidParam("limit", "must be positive")
+	}
+	query := url.Values{}
+	query.Set("status", string(StatusCompleted))
+	query.Set("sort", "-completed_time")
+	endpoint := withQuery("/api/downloads/"+strconv.Itoa(limit), query)
+
+	var downloads []Download
+	if err := s.client.do(ctx, "GET", endpoint, nil, &downloads); err != nil {
+		return nil, err
+	}
+	// Don't rely on the server honouring the sort and limit
+	sort.SliceStable(downloads, func(i, j int) bool {
+		return downloads[i].CompletedAt.After(downloads[j].CompletedAt)
+	})
+	if len(downloads) > limit {
+		downloads = downloads[:limit]
+	}
+	return downloads, nil
+}
+
+// PauseAll : Function to pause every active download on the server, e.g.
+// during maintenance. Needs an admin login; other users get ErrForbidden.
+func (s *AdminService) PauseAll(ctx context.Context) error {
+	return s.client.do(ctx, "POST", "/api/downloads/pause", nil, nil)
+}
+
+// ResumeAll : Function to resume the downloads stopped by PauseAll. Needs an
+// admin login. Downloads that were paused individually before PauseAll may
+// stay paused, depending on the server.
+func (s *AdminService) ResumeAll(ctx context.Context) error {
+	return s.client.do(ctx, "POST", "/api/downloads/resume", nil, nil)
+}
+
+// EstimateQueueCompletion : Function to estimate how long until every queued
+// and active download is done, from the bytes left and the current combined
+// speed. It returns ErrETAUnknown when bytes are left but nothing is moving.
+func (s *DownloadsService) EstimateQueueCompletion(ctx context.Context) (time.Duration, error) {
+	downloads, err := s.client.Downloads.GetDownloadsFiltered(ctx, DownloadFilter{}.Status(StatusQueued, StatusDownloading))
+	if err != nil {
+		return 0, err
+	}
+	return estimateCompletion(downloads)
+}
+
+// estimateCompletion : Helper function computing the ETA of a listing
+func estimateCompletion(downloads []Download) (time.Duration, error) {
+	var remaining, speed int64
+	for _, download := range downloads {
+		if left := download.Size - download.Downloaded; left > 0 {
+			remaining += left
+		}
+		if download.Speed > 0 {
+			speed += download.Speed
+		}
+	}
+	if remaining == 0 {
+		return 0, nil
+	}
+	if speed == 0 {
+		return 0, ErrETAUnknown
+	}
+	seconds := float64(remaining) / float64(speed)
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// MoveDownload : Function to have the server move the files of a completed
+// download into destDir. A rejected directory is reported as ErrInvalidPath
+// and a full target as ErrInsufficientSpace, both wrapped in an *APIError.
+func (s *DownloadsService) MoveDownload(ctx context.Context, downloadID int, destDir string) error {
+	if downloadID <= 0 {
+		return invalidParam("downloadID", "must be positive")
+	}
+	if strings.TrimSpace(destDir) == "" {
+		return invalidParam("destDir", "must not be empty")
+	}
+	body := map[string]string{"destination": destDir}
+	return s.client.do(ctx, "POST", downloadEndpoint(downloadID, "move"), body, nil)
+}
+
+// AddOption : Optional setting of a download added with DownloadsService.Add
+type AddOption func(*addRequest)
+
+// addRequest : Body of a request adding a download
+type addRequest struct {
+	Link        string   `json:"link"`
+	Name        string   `json:"download_name,omitempty"`
+	Category    string   `json:"category,omitempty"`
+	Destination string   `json:"destination,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+}
+
+// WithDownloadName : Add option to set the display name of the download
+// instead of deriving it from the link
+func WithDownloadName(name string) AddOption {
+	return func(r *addRequest) {
+		r.Name = strings.TrimSpace(name)
+	}
+}
+
+// WithCategory : Add option to file the download under category
+func WithCategory(category string) AddOption {
+	return func(r *addRequest) {
+		r.Category = strings.TrimSpace(category)
+	}
+}
+
+// WithDestination : Add option to save the files of the download to the
+// server directory dir. A directory the server rejects is reported as
+// ErrInvalidPath.
+func WithDestination(dir string) AddOption {
+	return func(r *addRequest) {
+		r.Destination = strings.TrimSpace(dir)
+	}
+}
+
+// WithTags : Add option to tag the download, see TagDownload
+func WithTags(tags ...string) AddOption {
+	return func(r *addRequest) {
+		r.Tags = tags
+	}
+}
+
+// Add : Function to queue a download of link, an http(s), ftp or magnet
+// link, returning the download with the id and initial status given by the
+// server. Servers replying without the download return one with only the
+// link set.
+func (s *DownloadsService) Add(ctx context.Context, link string, opts ...AddOption) (Download, error) {
+	link = strings.TrimSpace(link)
+	if err := validateDownloadLink(link); err != nil {
+		return Download{}, err
+	}
+	request := addRequest{Link: link}
+	for _, opt := range opts {
+		opt(&request)
+	}
+	if len(request.Tags) > 0 {
+		tags, err := cleanTags(request.Tags)
+		if err != nil {
+			return Download{}, err
+		}
+		request.Tags = tags
+	}
+	var created Download
+	if err := s.client.do(ctx, "POST", "/api/download", request, &created); err != nil {
+		return Download{}, err
+	}
+	if created.Link == "" {
+		created.Link = link
+	}
+	return created, nil
+}
+
+// DuplicateDownload : Function to add a download of newLink with the
+// category, destination and tags of an existing download, returning the
+// new download
+func (s *DownloadsService) DuplicateDownload(ctx context.Context, downloadID int, newLink string) (Download, error) {
+	if downloadID <= 0 {
+		return Download{}, invalidParam("downloadID", "must be positive")
+	}
+	newLink = strings.TrimSpace(newLink)
+	if err := validateDownloadLink(newLink); err != nil {
+		return Download{}, err
+	}
+	source, err := s.client.getDownload(ctx, downloadID)
+	if err != nil {
+		return Download{}, err
+	}
+	return s.Add(ctx, newLink, WithCategory(source.Category), WithDestination(source.Destination), WithTags(source.Tags...))
+}
+
+// Remove : Function to delete a queued or completed download. A download
+// that doesn't exist gives ErrNotFound and one added by another user
+// ErrForbidden, both wrapped in an *APIError.
+func (s *DownloadsService) Remove(ctx context.Context, downloadID int) error {
+	if downloadID <= 0 {
+		return invalidParam("downloadID", "must be positive")
+	}
+	return s.client.do(ctx, "DELETE", downloadEndpoint(downloadID, ""), nil, nil)
+}
+
+// Rate : Function to rate a completed download from MinRating to
+// MaxRating. Rating a download still queued or in progress gives
+// ErrStillRunning and an unknown download ErrNotFound, both wrapped in an
+// *APIError.
+func (s *DownloadsService) Rate(ctx context.Context, downloadID int, rating int) error {
+	if downloadID <= 0 {
+		return invalidParam("downloadID", "must be positive")
+	}
+	if rating < MinRating || rating > MaxRating {
+		return invalidParam("rating", fmt.Sprintf("must be from %d to %d", MinRating, MaxRating))
+	}
+	body := map[string]int{"rate": rating}
+	return s.client.do(ctx, "POST", downloadEndpoint(downloadID, ""), body, nil)
+}
+
+// validateDownloadLink : Helper function to check that link is an absolute
+// http(s) or ftp URL, or a magnet link
+func validateDownloadLink(link string) error {
+	if strings.HasPrefix(strings.ToLower(link), "magnet:") {
+		return validateMagnet(link)
+	}
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "ftp") || u.Host == "" {
+		return invalidParam("link", "is not an http(s), ftp or magnet link")
+	}
+	return nil
+}
+
+// ReorderQueue : Function to set the order in which queued downloads start,
+// first id first. Rejections by the server, e.g. for ids not in the queue,
+// are returned as an *APIError.
+func (s *DownloadsService) ReorderQueue(ctx context.Context, orderedIDs []int) error {
+	if len(orderedIDs) == 0 {
+		return invalidParam("orderedIDs", "must not be empty")
+	}
+	seen := make(map[int]bool, len(orderedIDs))
+	for _, id := range orderedIDs {
+		if id <= 0 {
+			return invalidParam("orderedIDs", "has non-positive id "+strconv.Itoa(id))
+		}
+		if seen[id] {
+			return invalidParam("orderedIDs", "has duplicate id "+strconv.Itoa(id))
+		}
+		seen[id] = true
+	}
+	body := map[string][]int{"order": orderedIDs}
+	return s.client.do(ctx, "PUT", "/api/downloads/queue", body, nil)
+}
+
+// GetTopBandwidthDownloads : Function to get the n active downloads with the
+// highest current speed, fastest first, e.g. to pick which ones to throttle
+// with SetDownloadBandwidthLimit or stop with KillDownload
+func (s *DownloadsService) GetTopBandwidthDownloads(ctx context.Context, n int) ([]Download, error) {
+	if n <= 0 {
+		return nil, invalidParam("n", "must be positive")
+	}
+	downloads, err := s.client.Downloads.GetActive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(downloads, func(i, j int) bool {
+		return downloads[i].Speed > downloads[j].Speed
+	})
+	if len(downloads) > n {
+		downloads = downloads[:n]
+	}
+	return downloads, nil
+}
+
+// GetCurrentThroughput : Function to get the combined speed of every active
+// download in bytes per second. Servers reporting it on their throughput
+// endpoint are asked directly; for the others the speeds returned by
+// GetActive are summed, which costs a listing of the active downloads.
+func (s *DownloadsService) GetCurrentThroughput(ctx context.Context) (int64, error) {
+	var throughput struct {
+		BytesPerSecond int64 `json:"bytes_per_second"`
+	}
+	err := s.client.do(ctx, "GET", "/api/downloads/throughput", nil, &throughput)
+	if err == nil {
+		return throughput.BytesPerSecond, nil
+	}
+	if !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrNotSupported) {
+		return 0, err
+	}
+	downloads, err := s.client.Downloads.GetActive(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, download := range downloads {
+		total += download.Speed
+	}
+	return total, nil
+}
+
+// GetDownloadRatio : Function to get the share ratio of a torrent download,
+// bytes uploaded over bytes downloaded. It is 0 while nothing has been
+// downloaded. Servers not tracking uploads return ErrNotSupported.
+func (s *DownloadsService) GetDownloadRatio(ctx context.Context, downloadID int) (float64, error) {
+	if downloadID <= 0 {
+		return 0, invalidParam("downloadID", "must be positive")
+	}
+	var transfer struct {
+		Downloaded int64  `json:"downloaded"`
+		Uploaded   *int64 `json:"uploaded"`
+	}
+	if err := s.client.do(ctx, "GET", downloadEndpoint(downloadID, ""), nil, &transfer); err != nil {
+		return 0, err
+	}
+	if transfer.Uploaded == nil {
+		return 0, fmt.Errorf("upload ratio: %w", ErrNotSupported)
+	}
+	if transfer.Downloaded <= 0 {
+		return 0, nil
+	}
+	return float64(*transfer.Uploaded) / float64(transfer.Downloaded), nil
+}
@@ -0,0 +1,948 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetDownloadBandwidthLimit(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/api/download/7/bandwidth" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		var body map[string]int64
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body["bandwidth_limit"] != 1<<20 {
+			t.Errorf("bandwidth_limit = %d, want %d", body["bandwidth_limit"], 1<<20)
+		}
+		w.Write([]byte(`{"status": "success"}`))
+	}))
+	if err := b.Downloads.SetDownloadBandwidthLimit(context.Background(), 7, 1<<20); err != nil {
+		t.Fatalf("SetDownloadBandwidthLimit: %v", err)
+	}
+}
+
+func TestSetDownloadBandwidthLimitValidation(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("no request expected, got %s %s", r.Method, r.URL.Path)
+	}))
+	tests := []struct {
+		id    int
+		limit int64
+	}{
+		{0, 100},
+		{-1, 100},
+		{3, -1},
+	}
+	for _, tt := range tests {
+		err := b.Downloads.SetDownloadBandwidthLimit(context.Background(), tt.id, tt.limit)
+		if !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("SetDownloadBandwidthLimit(%d, %d) = %v, want ErrInvalidParams", tt.id, tt.limit, err)
+		}
+	}
+}
+
+func TestSetDownloadBandwidthLimitNotSupported(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	err := b.Downloads.SetDownloadBandwidthLimit(context.Background(), 7, 0)
+	if !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("got %v, want ErrNotSupported", err)
+	}
+}
+
+func TestDownloadDecodesBandwidthLimit(t *testing.T) {
+	var download Download
+	body := `{"id": 7, "link": "http://www.scorelab.org/assets/img/score.jpg", "status": "downloading", "bandwidth_limit": 2048}`
+	if err := decodeFields([]byte(body), &download); err != nil {
+		t.Fatal(err)
+	}
+	if download.ID != 7 || download.Status != StatusDownloading || download.BandwidthLimit != 2048 {
+		t.Errorf("got %+v", download)
+	}
+}
+
+func TestGetRecentlyCompleted(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/downloads/2" {
+			t.Errorf("path = %s, want /api/downloads/2", r.URL.Path)
+		}
+		if got := r.URL.Query().Get("status"); got != "completed" {
+			t.Errorf("status = %q, want completed", got)
+		}
+		if got := r.URL.Query().Get("sort"); got != "-completed_time" {
+			t.Errorf("sort = %q, want -completed_time", got)
+		}
+		w.Write([]byte(`[
+			{"id": 1, "status": "completed", "completed_time": "2020-06-01T10:00:00Z"},
+			{"id": 2, "status": "completed", "completed_time": "2020-06-03T10:00:00Z"},
+			{"id": 3, "status": "completed", "completed_time": "2020-06-02T10:00:00Z"}
+		]`))
+	}))
+
+	downloads, err := b.Downloads.GetRecentlyCompleted(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("GetRecentlyCompleted: %v", err)
+	}
+	if len(downloads) != 2 {
+		t.Fatalf("got %d downloads, want 2", len(downloads))
+	}
+	if downloads[0].ID != 2 || downloads[1].ID != 3 {
+		t.Errorf("order = [%d %d], want [2 3]", downloads[0].ID, downloads[1].ID)
+	}
+	if want := time.Date(2020, 6, 3, 10, 0, 0, 0, time.UTC); !downloads[0].CompletedAt.Equal(want) {
+		t.Errorf("CompletedAt = %v, want %v", downloads[0].CompletedAt, want)
+	}
+}
+
+func TestGetRecentlyCompletedValidation(t *testing.T) {
+	b := newOfflineClient()
+	if _, err := b.Downloads.GetRecentlyCompleted(context.Background(), 0); !errors.Is(err, ErrInvalidParams) {
+		t.Fatalf("got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestPauseAllResumeAll(t *testing.T) {
+	var calls []string
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		w.Write([]byte(`{"status": "success"}`))
+	}))
+	if err := b.Admin.PauseAll(context.Background()); err != nil {
+		t.Fatalf("PauseAll: %v", err)
+	}
+	if err := b.Admin.ResumeAll(context.Background()); err != nil {
+		t.Fatalf("ResumeAll: %v", err)
+	}
+	want := []string{"POST /api/downloads/pause", "POST /api/downloads/resume"}
+	if len(calls) != 2 || calls[0] != want[0] || calls[1] != want[1] {
+		t.Errorf("calls = %q, want %q", calls, want)
+	}
+}
+
+func TestPauseAllForbidden(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	if err := b.Admin.PauseAll(context.Background()); !errors.Is(err, ErrForbidden) {
+		t.Errorf("PauseAll = %v, want ErrForbidden", err)
+	}
+	if err := b.Admin.ResumeAll(context.Background()); !errors.Is(err, ErrForbidden) {
+		t.Errorf("ResumeAll = %v, want ErrForbidden", err)
+	}
+}
+
+func TestSetDownloadExpiry(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/api/download/7/expiry" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		var body map[string]int64
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body["ttl"] != 3*24*60*60 {
+			t.Errorf("ttl = %d, want %d", body["ttl"], 3*24*60*60)
+		}
+		w.Write([]byte(`{"status": "success"}`))
+	}))
+	if err := b.Downloads.SetDownloadExpiry(context.Background(), 7, 72*time.Hour); err != nil {
+		t.Fatalf("SetDownloadExpiry: %v", err)
+	}
+}
+
+func TestSetDownloadExpiryValidation(t *testing.T) {
+	b := newOfflineClient()
+	for _, ttl := range []time.Duration{0, -time.Hour, time.Millisecond} {
+		if err := b.Downloads.SetDownloadExpiry(context.Background(), 7, ttl); !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("ttl %v: got %v, want ErrInvalidParams", ttl, err)
+		}
+	}
+	if err := b.Downloads.SetDownloadExpiry(context.Background(), 0, time.Hour); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("id 0: got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestSetDownloadExpiryNotSupported(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotImplemented)
+	}))
+	if err := b.Downloads.SetDownloadExpiry(context.Background(), 7, time.Hour); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("got %v, want ErrNotSupported", err)
+	}
+}
+
+func TestDownloadExpiryRoundTrip(t *testing.T) {
+	expires := time.Date(2020, 7, 1, 12, 30, 0, 0, time.UTC)
+	data, err := json.Marshal(Download{ID: 7, ExpiresAt: expires})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var download Download
+	if err := decodeFields(data, &download); err != nil {
+		t.Fatal(err)
+	}
+	if !download.ExpiresAt.Equal(expires) {
+		t.Errorf("ExpiresAt = %v, want %v", download.ExpiresAt, expires)
+	}
+}
+
+func TestSetDownloadNoteRoundTrip(t *testing.T) {
+	notes := map[string]string{}
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "PUT" && r.URL.Path == "/api/download/7/note":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			notes["7"] = body["note"]
+			w.Write([]byte(`{"status": "success"}`))
+		case r.Method == "GET" && r.URL.Path == "/api/downloads":
+			json.NewEncoder(w).Encode([]Download{{ID: 7, Note: notes["7"]}})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	note := "Lecture recordings, keep until exams ✓"
+	if err := b.Downloads.SetDownloadNote(context.Background(), 7, note); err != nil {
+		t.Fatalf("SetDownloadNote: %v", err)
+	}
+	downloads, err := b.Downloads.GetDownloadsFiltered(context.Background(), DownloadFilter{})
+	if err != nil {
+		t.Fatalf("GetDownloadsFiltered: %v", err)
+	}
+	if len(downloads) != 1 || downloads[0].Note != note {
+		t.Errorf("got %+v, want note %q", downloads, note)
+	}
+}
+
+func TestSetDownloadNoteValidation(t *testing.T) {
+	b := newOfflineClient()
+	if err := b.Downloads.SetDownloadNote(context.Background(), 0, "hi"); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("id 0: got %v, want ErrInvalidParams", err)
+	}
+	long := strings.Repeat("✓", MaxNoteLength+1)
+	if err := b.Downloads.SetDownloadNote(context.Background(), 7, long); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("long note: got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestSetDownloadNoteMaxLength(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "success"}`))
+	}))
+	if err := b.Downloads.SetDownloadNote(context.Background(), 7, strings.Repeat("✓", MaxNoteLength)); err != nil {
+		t.Errorf("note of MaxNoteLength characters: %v", err)
+	}
+}
+
+func TestSetDownloadNoteNotSupported(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	if err := b.Downloads.SetDownloadNote(context.Background(), 7, "hi"); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("got %v, want ErrNotSupported", err)
+	}
+}
+
+func TestGetDownloadLogs(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/api/download/7/logs" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`["[#2089b0 0B/0B CN:1 DL:0B]", "Download complete: /downloads/score.jpg"]`))
+	}))
+	lines, err := b.Downloads.GetDownloadLogs(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("GetDownloadLogs: %v", err)
+	}
+	if len(lines) != 2 || lines[1] != "Download complete: /downloads/score.jpg" {
+		t.Errorf("got %q", lines)
+	}
+}
+
+func TestGetDownloadLogsNotFound(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "no such download"}`, http.StatusNotFound)
+	}))
+	if _, err := b.Downloads.GetDownloadLogs(context.Background(), 7); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+	if _, err := b.Downloads.GetDownloadLogs(context.Background(), -1); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestStreamDownloadLogs(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/download/7/logs" || r.URL.Query().Get("follow") != "true" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		for _, line := range []string{"first", "second", "third"} {
+			w.Write([]byte(line + "\n"))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	// A timeout shorter than the stream would cut it off if it applied
+	b.doer.(*http.Client).Timeout = time.Nanosecond
+
+	var lines []string
+	err := b.Downloads.StreamDownloadLogs(context.Background(), 7, func(line string) {
+		lines = append(lines, line)
+	})
+	if err != nil {
+		t.Fatalf("StreamDownloadLogs: %v", err)
+	}
+	if strings.Join(lines, ",") != "first,second,third" {
+		t.Errorf("got %q", lines)
+	}
+}
+
+func TestStreamDownloadLogsCancel(t *testing.T) {
+	release := make(chan struct{})
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("first\n"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := b.Downloads.StreamDownloadLogs(ctx, 7, func(line string) {
+		cancel()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+}
+
+func TestStreamDownloadLogsNotSupported(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotImplemented)
+	}))
+	err := b.Downloads.StreamDownloadLogs(context.Background(), 7, func(string) {})
+	if !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("got %v, want ErrNotSupported", err)
+	}
+}
+
+func TestEstimateCompletion(t *testing.T) {
+	tests := []struct {
+		name      string
+		downloads []Download
+		want      time.Duration
+		err       error
+	}{
+		{name: "empty queue"},
+		{
+			name:      "one active",
+			downloads: []Download{{Size: 1000, Downloaded: 400, Speed: 100}},
+			want:      6 * time.Second,
+		},
+		{
+			name: "queued ones add bytes but no speed",
+			downloads: []Download{
+				{Size: 1000, Downloaded: 500, Speed: 250},
+				{Size: 1500, Status: StatusQueued},
+			},
+			want: 8 * time.Second,
+		},
+		{
+			name:      "nothing moving",
+			downloads: []Download{{Size: 1000, Status: StatusQueued}},
+			err:       ErrETAUnknown,
+		},
+		{
+			name:      "unknown sizes",
+			downloads: []Download{{Downloaded: 300, Speed: 100}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := estimateCompletion(tt.downloads)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimateQueueCompletion(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("status"); got != "queued,downloading" {
+			t.Errorf("status = %q, want queued,downloading", got)
+		}
+		w.Write([]byte(`[
+			{"id": 1, "status": "downloading", "size": 4096, "downloaded": 1024, "speed": 512},
+			{"id": 2, "status": "queued", "size": 1024}
+		]`))
+	}))
+	eta, err := b.Downloads.EstimateQueueCompletion(context.Background())
+	if err != nil {
+		t.Fatalf("EstimateQueueCompletion: %v", err)
+	}
+	if eta != 8*time.Second {
+		t.Errorf("eta = %v, want 8s", eta)
+	}
+}
+
+func TestGetDownloadPeers(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/download/7/peers":
+			w.Write([]byte(`{"seeds": 12, "peers": 40, "connected": 9}`))
+		case "/api/download/8/peers":
+			http.Error(w, `{"error": "no such download"}`, http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	}))
+	stats, err := b.Downloads.GetDownloadPeers(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("GetDownloadPeers: %v", err)
+	}
+	if want := (PeerStats{Seeds: 12, Peers: 40, Connected: 9}); stats != want {
+		t.Errorf("got %+v, want %+v", stats, want)
+	}
+	if _, err := b.Downloads.GetDownloadPeers(context.Background(), 8); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing download: got %v, want ErrNotFound", err)
+	}
+	if _, err := b.Downloads.GetDownloadPeers(context.Background(), 9); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("old server: got %v, want ErrNotSupported", err)
+	}
+	if _, err := b.Downloads.GetDownloadPeers(context.Background(), 0); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("id 0: got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestRenameDownload(t *testing.T) {
+	name := "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a"
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "PUT" && r.URL.Path == "/api/download/7/name":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			name = body["download_name"]
+			w.Write([]byte(`{"status": "success"}`))
+		case r.Method == "GET" && r.URL.Path == "/api/download/7":
+			json.NewEncoder(w).Encode(Download{ID: 7, Name: name})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	if err := b.Downloads.RenameDownload(context.Background(), 7, "Ubuntu 20.04 ISO"); err != nil {
+		t.Fatalf("RenameDownload: %v", err)
+	}
+	download, err := b.getDownload(context.Background(), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if download.Name != "Ubuntu 20.04 ISO" {
+		t.Errorf("Name = %q, want the new name", download.Name)
+	}
+}
+
+func TestRenameDownloadErrors(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotImplemented)
+	}))
+	if err := b.Downloads.RenameDownload(context.Background(), 7, "name"); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("got %v, want ErrNotSupported", err)
+	}
+	if err := b.Downloads.RenameDownload(context.Background(), 7, " "); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("empty name: got %v, want ErrInvalidParams", err)
+	}
+	if err := b.Downloads.RenameDownload(context.Background(), -2, "name"); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("negative id: got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestMoveDownload(t *testing.T) {
+	var method, path string
+	var body map[string]string
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"status": "success"}`))
+	}))
+	if err := b.Downloads.MoveDownload(context.Background(), 12, "/srv/media/isos"); err != nil {
+		t.Fatalf("MoveDownload: %v", err)
+	}
+	if method != "POST" || path != "/api/download/12/move" {
+		t.Errorf("request = %s %s, want POST /api/download/12/move", method, path)
+	}
+	if body["destination"] != "/srv/media/isos" {
+		t.Errorf("destination = %q, want /srv/media/isos", body["destination"])
+	}
+}
+
+func TestMoveDownloadErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{name: "invalid dir", status: http.StatusBadRequest, body: `{"code": "invalid_path", "message": "outside the storage root"}`, want: ErrInvalidPath},
+		{name: "no space", status: http.StatusConflict, body: `{"code": "insufficient_space"}`, want: ErrInsufficientSpace},
+		{name: "507", status: http.StatusInsufficientStorage, want: ErrInsufficientSpace},
+		{name: "missing", status: http.StatusNotFound, want: ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			err := b.Downloads.MoveDownload(context.Background(), 12, "/srv/media")
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+				t.Errorf("got %#v, want an *APIError with status %d", err, tt.status)
+			}
+		})
+	}
+	b := newOfflineClient()
+	if err := b.Downloads.MoveDownload(context.Background(), 0, "/srv"); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("zero id: got %v, want ErrInvalidParams", err)
+	}
+	if err := b.Downloads.MoveDownload(context.Background(), 3, ""); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("empty dir: got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestReorderQueue(t *testing.T) {
+	var method, path string
+	var body map[string][]int
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		json.NewDecoder(r.Body).Decode(&body)
+	}))
+	if err := b.Downloads.ReorderQueue(context.Background(), []int{9, 2, 14}); err != nil {
+		t.Fatalf("ReorderQueue: %v", err)
+	}
+	if method != "PUT" || path != "/api/downloads/queue" {
+		t.Errorf("request = %s %s, want PUT /api/downloads/queue", method, path)
+	}
+	if !reflect.DeepEqual(body["order"], []int{9, 2, 14}) {
+		t.Errorf("order = %v, want [9 2 14]", body["order"])
+	}
+}
+
+func TestReorderQueueErrors(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"message": "download 3 is not queued"}`))
+	}))
+	var apiErr *APIError
+	err := b.Downloads.ReorderQueue(context.Background(), []int{3, 1})
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict || apiErr.Message != "download 3 is not queued" {
+		t.Errorf("got %#v, want the server's APIError", err)
+	}
+	for _, ids := range [][]int{nil, {}, {1, 2, 1}, {4, 0}} {
+		if err := b.Downloads.ReorderQueue(context.Background(), ids); !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("%v: got %v, want ErrInvalidParams", ids, err)
+		}
+	}
+}
+
+func TestGetTopBandwidthDownloads(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/downloads" || r.URL.Query().Get("status") != "downloading" {
+			t.Errorf("unexpected request %s?%s", r.URL.Path, r.URL.RawQuery)
+		}
+		w.Write([]byte(`[
+			{"id": 1, "status": "downloading", "speed": 200},
+			{"id": 2, "status": "downloading", "speed": 900},
+			{"id": 3, "status": "downloading", "speed": 0},
+			{"id": 4, "status": "downloading", "speed": 500}
+		]`))
+	}))
+	tests := []struct {
+		n    int
+		want []int
+	}{
+		{1, []int{2}},
+		{3, []int{2, 4, 1}},
+		{10, []int{2, 4, 1, 3}},
+	}
+	for _, tt := range tests {
+		downloads, err := b.Downloads.GetTopBandwidthDownloads(context.Background(), tt.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int
+		for _, download := range downloads {
+			ids = append(ids, download.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("n=%d: ids = %v, want %v", tt.n, ids, tt.want)
+		}
+	}
+	if _, err := b.Downloads.GetTopBandwidthDownloads(context.Background(), 0); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("n=0: got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestGetDownloadRatio(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     float64
+		wantErr  error
+	}{
+		{"seeding", `{"id": 8, "downloaded": 2000, "uploaded": 3000}`, 1.5, nil},
+		{"nothing downloaded", `{"id": 8, "downloaded": 0, "uploaded": 0}`, 0, nil},
+		{"no upload tracking", `{"id": 8, "downloaded": 2000}`, 0, ErrNotSupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/download/8" {
+					t.Errorf("unexpected path %s", r.URL.Path)
+				}
+				w.Write([]byte(tt.response))
+			}))
+			ratio, err := b.Downloads.GetDownloadRatio(context.Background(), 8)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if ratio != tt.want {
+				t.Errorf("ratio = %v, want %v", ratio, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloadDecodesUploaded(t *testing.T) {
+	var download Download
+	if err := decodeFields([]byte(`{"id": 1, "uploaded": 512}`), &download); err != nil {
+		t.Fatal(err)
+	}
+	if download.Uploaded != 512 {
+		t.Errorf("Uploaded = %d, want 512", download.Uploaded)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	var added map[string]interface{}
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/api/download" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&added)
+		w.Write([]byte(`{"id": 12, "link": "http://example.com/a.iso", "download_name": "a", "status": "queued"}`))
+	}))
+	download, err := b.Downloads.Add(context.Background(), " http://example.com/a.iso ",
+		WithDownloadName(" a "), WithCategory("linux"), WithDestination("/srv/isos"), WithTags(" distro ", "iso"))
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	want := map[string]interface{}{
+		"link":          "http://example.com/a.iso",
+		"download_name": "a",
+		"category":      "linux",
+		"destination":   "/srv/isos",
+		"tags":          []interface{}{"distro", "iso"},
+	}
+	if !reflect.DeepEqual(added, want) {
+		t.Errorf("added with %v, want %v", added, want)
+	}
+	if download.ID != 12 || download.Status != StatusQueued || download.Name != "a" {
+		t.Errorf("got %+v", download)
+	}
+}
+
+func TestAddWithoutDownloadInResponse(t *testing.T) {
+	var added map[string]interface{}
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&added)
+	}))
+	download, err := b.Downloads.Add(context.Background(), "http://example.com/a.iso")
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if want := map[string]interface{}{"link": "http://example.com/a.iso"}; !reflect.DeepEqual(added, want) {
+		t.Errorf("added with %v, want %v", added, want)
+	}
+	if download.ID != 0 || download.Link != "http://example.com/a.iso" {
+		t.Errorf("got %+v", download)
+	}
+}
+
+func TestAddValidation(t *testing.T) {
+	b := newOfflineClient()
+	for _, link := range []string{"", "example.com/a.iso", "file:///etc/passwd"} {
+		if _, err := b.Downloads.Add(context.Background(), link); !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("%q: got %v, want ErrInvalidParams", link, err)
+		}
+	}
+	if _, err := b.Downloads.Add(context.Background(), "http://example.com/a.iso", WithTags("iso", " ")); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("empty tag: got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" {
+			t.Errorf("method = %s, want DELETE", r.Method)
+		}
+		switch r.URL.Path {
+		case "/api/download/1":
+		case "/api/download/2":
+			w.WriteHeader(http.StatusNotFound)
+		case "/api/download/3":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	ctx := context.Background()
+	if err := b.Downloads.Remove(ctx, 1); err != nil {
+		t.Errorf("Remove(1): %v", err)
+	}
+	var apiErr *APIError
+	if err := b.Downloads.Remove(ctx, 2); !errors.Is(err, ErrNotFound) || !errors.As(err, &apiErr) {
+		t.Errorf("Remove(2): got %v, want an ErrNotFound APIError", err)
+	}
+	if err := b.Downloads.Remove(ctx, 3); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Remove(3): got %v, want ErrForbidden", err)
+	}
+	if err := newOfflineClient().Downloads.Remove(ctx, 0); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("Remove(0): got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestRate(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]int
+		json.NewDecoder(r.Body).Decode(&body)
+		switch {
+		case r.Method != "POST":
+			t.Errorf("method = %s, want POST", r.Method)
+		case r.URL.Path == "/api/download/1" && body["rate"] == 4:
+		case r.URL.Path == "/api/download/2":
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"code": "still_running"}`))
+		default:
+			t.Errorf("unexpected request %s with %v", r.URL.Path, body)
+		}
+	}))
+	ctx := context.Background()
+	if err := b.Downloads.Rate(ctx, 1, 4); err != nil {
+		t.Errorf("Rate(1): %v", err)
+	}
+	if err := b.Downloads.Rate(ctx, 2, 5); !errors.Is(err, ErrStillRunning) {
+		t.Errorf("Rate(2): got %v, want ErrStillRunning", err)
+	}
+}
+
+func TestRateValidation(t *testing.T) {
+	b := newOfflineClient()
+	for _, rating := range []int{MinRating - 1, MaxRating + 1, -3} {
+		var invalid *ValidationError
+		if err := b.Downloads.Rate(context.Background(), 1, rating); !errors.As(err, &invalid) || invalid.Field != "rating" {
+			t.Errorf("rating %d: got %v, want a rating violation", rating, err)
+		}
+	}
+	if err := b.Downloads.Rate(context.Background(), 0, 3); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("id 0: got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestDuplicateDownload(t *testing.T) {
+	var created map[string]interface{}
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/api/download/4":
+			w.Write([]byte(`{"id": 4, "link": "http://example.com/a.iso", "category": "linux",
+				"destination": "/srv/isos", "tags": ["distro"], "status": "completed", "note": "old"}`))
+		case r.Method == "POST" && r.URL.Path == "/api/download":
+			json.NewDecoder(r.Body).Decode(&created)
+			w.Write([]byte(`{"id": 9, "link": "http://example.com/b.iso", "category": "linux", "destination": "/srv/isos", "status": "queued"}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	download, err := b.Downloads.DuplicateDownload(context.Background(), 4, " http://example.com/b.iso ")
+	if err != nil {
+		t.Fatalf("DuplicateDownload: %v", err)
+	}
+	want := map[string]interface{}{
+		"link":        "http://example.com/b.iso",
+		"category":    "linux",
+		"destination": "/srv/isos",
+		"tags":        []interface{}{"distro"},
+	}
+	if !reflect.DeepEqual(created, want) {
+		t.Errorf("created with %v, want %v", created, want)
+	}
+	if download.ID != 9 || download.Category != "linux" || download.Destination != "/srv/isos" {
+		t.Errorf("got %+v", download)
+	}
+}
+
+func TestDuplicateDownloadValidation(t *testing.T) {
+	b := newOfflineClient()
+	for _, link := range []string{"", "example.com/a.iso", "file:///etc/passwd", "magnet:?dn=nohash"} {
+		if _, err := b.Downloads.DuplicateDownload(context.Background(), 4, link); !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("%q: got %v, want ErrInvalidParams", link, err)
+		}
+	}
+	if _, err := b.Downloads.DuplicateDownload(context.Background(), 0, "http://example.com/a.iso"); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("id 0: got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestGetDownloadFailureReason(t *testing.T) {
+	downloads := map[string]string{
+		"/api/download/1": `{"id": 1, "status": "failed", "failure_reason": "disk full"}`,
+		"/api/download/2": `{"id": 2, "status": "failed"}`,
+		"/api/download/3": `{"id": 3, "status": "downloading"}`,
+		"/api/download/4": `{"id": 4, "status": "completed"}`,
+		"/api/download/5": `{"id": 5, "status": "failed"}`,
+		"/api/download/6": `{"id": 6, "status": "failed"}`,
+	}
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/download/2/failure":
+			w.Write([]byte(`{"reason": "404 from origin"}`))
+		case "/api/download/5/failure":
+			w.WriteHeader(http.StatusNotFound)
+		case "/api/download/6/failure":
+			// Restarted in the meantime
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"code": "still_running"}`))
+		default:
+			body, ok := downloads[r.URL.Path]
+			if !ok {
+				t.Errorf("unexpected request %s", r.URL.Path)
+			}
+			w.Write([]byte(body))
+		}
+	}))
+	tests := []struct {
+		id      int
+		reason  string
+		wantErr error
+	}{
+		{id: 1, reason: "disk full"},
+		{id: 2, reason: "404 from origin"},
+		{id: 3, wantErr: ErrStillRunning},
+		{id: 4},
+		{id: 5, wantErr: ErrNotSupported},
+		{id: 6, wantErr: ErrStillRunning},
+	}
+	for _, tt := range tests {
+		reason, err := b.Downloads.GetDownloadFailureReason(context.Background(), tt.id)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("download %d: got %v, want %v", tt.id, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || reason != tt.reason {
+			t.Errorf("download %d: got %q, %v; want %q", tt.id, reason, err, tt.reason)
+		}
+	}
+}
+
+func TestGetCurrentThroughput(t *testing.T) {
+	tests := []struct {
+		name       string
+		native     int // status of the throughput endpoint
+		throughput int64
+		listed     bool
+	}{
+		{name: "server-native", native: http.StatusOK, throughput: 4096},
+		{name: "summed, no endpoint", native: http.StatusNotFound, throughput: 1600, listed: true},
+		{name: "summed, not implemented", native: http.StatusNotImplemented, throughput: 1600, listed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listed := false
+			b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/api/downloads/throughput":
+					w.WriteHeader(tt.native)
+					w.Write([]byte(`{"bytes_per_second": 4096}`))
+				case "/api/downloads":
+					listed = true
+					w.Write([]byte(`[
+						{"id": 1, "status": "downloading", "speed": 1000},
+						{"id": 2, "status": "downloading", "speed": 600},
+						{"id": 3, "status": "queued", "speed": 50}
+					]`))
+				}
+			}))
+			throughput, err := b.Downloads.GetCurrentThroughput(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if throughput != tt.throughput || listed != tt.listed {
+				t.Errorf("got %d (listed %v), want %d (listed %v)", throughput, listed, tt.throughput, tt.listed)
+			}
+		})
+	}
+}
+
+func TestGetDownloadUserRequestsOptions(t *testing.T) {
+	var query url.Values
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/user/downloads" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		query = r.URL.Query()
+		w.Write([]byte(`[{"id": 3, "status": "failed"}]`))
+	}))
+	since := time.Date(2020, 6, 1, 14, 0, 0, 0, time.FixedZone("CEST", 2*3600))
+	records, err := b.Downloads.GetDownloadUserRequests(context.Background(), ListDownloadsOptions{Limit: 25, Offset: 50, Status: StatusFailed, Since: since})
+	if err != nil {
+		t.Fatalf("GetDownloadUserRequests: %v", err)
+	}
+	if len(records) != 1 || records[0].ID != 3 {
+		t.Errorf("records = %+v", records)
+	}
+	want := url.Values{"limit": {"25"}, "offset": {"50"}, "status": {"failed"}, "since": {"2020-06-01T12:00:00Z"}}
+	if !reflect.DeepEqual(query, want) {
+		t.Errorf("query = %v, want %v", query, want)
+	}
+}
+
+func TestListDownloadsOptionsValidation(t *testing.T) {
+	_, err := newOfflineClient().Downloads.GetDownloadUserRequests(context.Background(), ListDownloadsOptions{Limit: -1, Status: "paused"})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || !reflect.DeepEqual(errs.Fields(), []string{"limit", "status"}) {
+		t.Errorf("got %v, want limit and status errors", err)
+	}
+}
@@ -0,0 +1,44 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"net/url"
+)
+
+const (
+	jsonContentType = "application/json"
+	formContentType = "application/x-www-form-urlencoded"
+)
+
+// encodeBody : Function to encode a request body, returning it with its
+// content type. url.Values are form-encoded, as the login endpoint expects;
+// any other value is marshalled to JSON through its json tags, with the
+// field names of the detected server version. Strings and byte slices are
+// refused rather than sent as JSON strings, since they are almost always a
+// document encoded by hand; pass a json.RawMessage to send one as is, after
+// it is checked to be valid JSON.
+func (b *Client) encodeBody(body interface{}) ([]byte, string, error) {
+	switch v := body.(type) {
+	case url.Values:
+		return []byte(v.Encode()), formContentType, nil
+	case string, []byte:
+		return nil, "", invalidParam("body", "must be a Go value, not encoded text")
+	}
+	data, err := b.fields().marshal(body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, jsonContentType, nil
+}
@@ -0,0 +1,100 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeBodyRoundTrip(t *testing.T) {
+	b := newOfflineClient()
+	tests := []struct {
+		name string
+		body interface{}
+		out  interface{}
+	}{
+		{"create user", CreateUserInput{UserName: `quo"te`, Password: "secret\npass", Email: "kmehant@scorelab.org", AuthLevel: AuthLevelAdmin}, &CreateUserInput{}},
+		{"update user", UpdateUserInput{UserName: "ünïcode", Password: "newsecretpass", Email: "kmehant@gmail.com"}, &UpdateUserInput{}},
+		{"policy", UserPolicy{MaxConcurrentDownloads: 3, QuotaBytes: 1 << 40}, &UserPolicy{}},
+		{"map", map[string]string{"reason": "spam {user_name:x}"}, &map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, contentType, err := b.encodeBody(tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if contentType != jsonContentType || !json.Valid(data) {
+				t.Fatalf("got %s %s, want valid JSON", contentType, data)
+			}
+			if err := json.Unmarshal(data, tt.out); err != nil {
+				t.Fatal(err)
+			}
+			if got := reflect.ValueOf(tt.out).Elem().Interface(); !reflect.DeepEqual(got, tt.body) {
+				t.Errorf("round trip gave %+v, want %+v", got, tt.body)
+			}
+		})
+	}
+}
+
+func TestEncodeBodyForm(t *testing.T) {
+	form := url.Values{"user_name": {"rand&user"}, "password": {"pass=word"}}
+	data, contentType, err := newOfflineClient().encodeBody(form)
+	if err != nil || contentType != formContentType {
+		t.Fatalf("got %s, %v", contentType, err)
+	}
+	decoded, err := url.ParseQuery(string(data))
+	if err != nil || !reflect.DeepEqual(decoded, form) {
+		t.Errorf("round trip gave %v, %v; want %v", decoded, err, form)
+	}
+}
+
+func TestEncodeBodyRejectsText(t *testing.T) {
+	b := newOfflineClient()
+	for _, body := range []interface{}{`{user_name:"x"}`, []byte(`{"user_name":"x"}`)} {
+		if _, _, err := b.encodeBody(body); !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("%T body: got %v, want ErrInvalidParams", body, err)
+		}
+	}
+	if _, _, err := b.encodeBody(json.RawMessage(`{user_name:"x"}`)); err == nil {
+		t.Error("invalid json.RawMessage should be refused")
+	}
+	if data, _, err := b.encodeBody(json.RawMessage(`{"user_name": "x"}`)); err != nil || string(data) != `{"user_name":"x"}` {
+		t.Errorf("valid json.RawMessage: got %s, %v", data, err)
+	}
+}
+
+func TestAddUserRequestSendsJSON(t *testing.T) {
+	want := CreateUserInput{UserName: "rand", Password: "secretpass", Email: "kmehant@scorelab.org", AuthLevel: AuthLevelRegular}
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != jsonContentType {
+			t.Errorf("Content-Type = %q", r.Header.Get("Content-Type"))
+		}
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		var got CreateUserInput
+		if err := decoder.Decode(&got); err != nil || got != want {
+			t.Errorf("server decoded %+v, %v; want %+v", got, err, want)
+		}
+	}))
+	if err := b.Users.AddUserRequest(context.Background(), want); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,96 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// envelopeFields : Keys of the envelope some servers wrap response data in,
+// e.g. {"status": "success", "data": {...}}
+var envelopeFields = map[string]bool{"status": true, "message": true, "data": true}
+
+// envelope : Response data wrapped with the status of the call
+type envelope struct {
+	Status  json.RawMessage `json:"status"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// unwrapEnvelope : Helper function to return the data of a response wrapped
+// in an envelope, or the response itself when it isn't one. Only objects
+// with a data key and no keys besides status, message and data count as
+// envelopes. An envelope reporting an error status gives an error with its
+// message.
+func unwrapEnvelope(body []byte) ([]byte, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return body, nil
+	}
+	var keys map[string]json.RawMessage
+	if json.Unmarshal(trimmed, &keys) != nil {
+		return body, nil
+	}
+	if _, ok := keys["data"]; !ok {
+		return body, nil
+	}
+	for key := range keys {
+		if !envelopeFields[key] {
+			return body, nil
+		}
+	}
+	var env envelope
+	if err := json.Unmarshal(trimmed, &env); err != nil {
+		return body, nil
+	}
+	if err := envelopeError(env); err != nil {
+		return nil, err
+	}
+	return env.Data, nil
+}
+
+// envelopeError : Helper function returning the error reported by the
+// status of an envelope, nil when it reports success or no status
+func envelopeError(env envelope) error {
+	var status string
+	if json.Unmarshal(env.Status, &status) != nil {
+		return nil
+	}
+	switch strings.ToLower(status) {
+	case "error", "fail", "failed":
+		if env.Message == "" {
+			env.Message = "no message"
+		}
+		return fmt.Errorf("server reported %s: %s", status, env.Message)
+	}
+	return nil
+}
+
+// decodeStrict : Helper function to decode data into v, failing on fields v
+// doesn't declare and on anything after the JSON value
+func decodeStrict(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return errors.New("unexpected data after the JSON value")
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnwrapEnvelope(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+		err  string
+	}{
+		{"plain object", `{"user_name": "rand"}`, `{"user_name": "rand"}`, ""},
+		{"plain array", `[1, 2]`, `[1, 2]`, ""},
+		{"envelope", `{"status": "success", "message": "ok", "data": {"user_name": "rand"}}`, `{"user_name": "rand"}`, ""},
+		{"data only", `{"data": [1]}`, `[1]`, ""},
+		{"numeric status", `{"status": 200, "data": [1]}`, `[1]`, ""},
+		{"model with a data field", `{"status": "completed", "data": "x", "id": 7}`, `{"status": "completed", "data": "x", "id": 7}`, ""},
+		{"error status", `{"status": "error", "message": "quota exceeded", "data": null}`, "", "server reported error: quota exceeded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := unwrapEnvelope([]byte(tt.body))
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("got %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil || string(got) != tt.want {
+				t.Errorf("got %s, %v; want %s", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrictDecoding(t *testing.T) {
+	body := `{"status": "success", "data": [{"user_name": "rand", "auth": 1, "shoe_size": 44}]}`
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	})
+
+	lenient := newTestClient(t, handler)
+	var users []User
+	if err := lenient.do(context.Background(), "GET", "/api/user", nil, &users); err != nil {
+		t.Fatal(err)
+	}
+	if want := []User{{UserName: "rand", AuthLevel: 1}}; !reflect.DeepEqual(users, want) {
+		t.Errorf("got %+v, want %+v", users, want)
+	}
+
+	strict := newTestClient(t, handler, WithStrictDecoding(true))
+	err := strict.do(context.Background(), "GET", "/api/user", nil, &users)
+	if err == nil || !strings.Contains(err.Error(), "shoe_size") {
+		t.Errorf("got %v, want an unknown field error", err)
+	}
+}
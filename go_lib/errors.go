@@ -0,0 +1,178 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+var (
+	// ErrInvalidParams : an argument failed validation before any request was sent
+	ErrInvalidParams = errors.New("invalid parameters")
+	// ErrNotSupported : the server doesn't provide the requested feature,
+	// reported by older servers with 405 Method Not Allowed or 501 Not Implemented
+	ErrNotSupported = errors.New("operation not supported by server")
+	// ErrNotAuthenticated : the server rejected the credentials, or their
+	// absence, e.g. for an expired session (401 or a redirect to a login page)
+	ErrNotAuthenticated = errors.New("not authenticated")
+	// ErrUnauthorized : the same as ErrNotAuthenticated
+	ErrUnauthorized = ErrNotAuthenticated
+	// ErrForbidden : the server refused the operation for the logged in user (403)
+	ErrForbidden = errors.New("forbidden")
+	// ErrNotFound : the requested user, download or other resource doesn't exist (404)
+	ErrNotFound = errors.New("not found")
+	// ErrConflict : the operation clashes with the current state of the
+	// resource, e.g. a user updated by someone else in the meantime (409)
+	ErrConflict = errors.New("conflict")
+	// ErrRateLimited : the server rejected the request for exceeding its
+	// rate limit (429); RateLimitStatus tells when to try again
+	ErrRateLimited = errors.New("rate limited")
+	// ErrServerUnavailable : the server, or a gateway in front of it, is
+	// down or overloaded (502, 503 or 504)
+	ErrServerUnavailable = errors.New("server unavailable")
+	// ErrClosed : the client was closed with Close
+	ErrClosed = errors.New("client closed")
+	// ErrCloseTimeout : Close gave up waiting for the watchers to stop
+	ErrCloseTimeout = errors.New("timed out waiting for watchers to stop")
+	// ErrInvalidPath : the server rejected a destination directory as invalid
+	ErrInvalidPath = errors.New("invalid path")
+	// ErrInsufficientSpace : the server hasn't enough free space for the operation
+	ErrInsufficientSpace = errors.New("insufficient space")
+	// ErrFileMissing : the download exists but the server no longer has its
+	// file, e.g. after it was cleaned up; it also matches ErrNotFound
+	ErrFileMissing = errors.New("file missing")
+)
+
+// errorCodes : Sentinels reported by the server through the code field of
+// an error response
+var errorCodes = map[string]error{
+	"invalid_path":        ErrInvalidPath,
+	"insufficient_space":  ErrInsufficientSpace,
+	"two_factor_required": Err2FARequired,
+	"still_running":       ErrStillRunning,
+	"file_missing":        ErrFileMissing,
+}
+
+// maxErrorBody : Error response bodies are only read up to this many bytes
+const maxErrorBody = 4096
+
+// requestIDHeader : Response header through which the server identifies a
+// request in its logs
+const requestIDHeader = "X-Request-Id"
+
+// APIError : Error returned for a non-2xx response. Code and Message are
+// filled in when the server explains the failure in a JSON body.
+type APIError struct {
+	Method     string
+	Endpoint   string
+	StatusCode int
+	Code       string
+	Message    string
+	// Body is the response body, up to its first 4 KiB
+	Body []byte
+	// RequestID is the one the server logged the request with, from the
+	// X-Request-Id header, to quote when reporting the failure
+	RequestID string
+}
+
+// Error : Function to describe the failed request
+func (e *APIError) Error() string {
+	message := e.Message
+	if message == "" {
+		message = http.StatusText(e.StatusCode)
+	}
+	if e.Code != "" {
+		message += " (" + e.Code + ")"
+	}
+	if e.RequestID != "" {
+		message += ", request " + e.RequestID
+	}
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.Endpoint, e.StatusCode, message)
+}
+
+// Unwrap : Function to get the sentinels matching the error, so that callers
+// can test for e.g. ErrNotFound with errors.Is. The sentinel of the server's
+// error code comes first, followed by the one of the status code.
+func (e *APIError) Unwrap() []error {
+	var errs []error
+	if err, ok := errorCodes[e.Code]; ok {
+		errs = append(errs, err)
+	}
+	if err := statusError(e.StatusCode); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// statusError : Helper function to get the sentinel of an HTTP status, nil
+// for those without one
+func statusError(statusCode int) error {
+	switch statusCode {
+	case http.StatusUnauthorized:
+		return ErrNotAuthenticated
+	case http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return ErrNotSupported
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return ErrServerUnavailable
+	case http.StatusInsufficientStorage:
+		return ErrInsufficientSpace
+	}
+	return nil
+}
+
+// errorForStatus : Helper function to turn a non-2xx response into an
+// *APIError, reading the server's explanation from the body if it has one
+func errorForStatus(method string, endpoint string, response *http.Response) error {
+	apiErr := &APIError{
+		Method:     method,
+		Endpoint:   endpoint,
+		StatusCode: response.StatusCode,
+		RequestID:  response.Header.Get(requestIDHeader),
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(response.Body, maxErrorBody))
+	if err == nil && len(data) > 0 {
+		apiErr.Body = data
+	}
+	var body struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Error   string `json:"error"`
+		// TwoFactorRequired flags a login lacking its TOTP code
+		TwoFactorRequired bool `json:"two_factor_required"`
+	}
+	if json.Unmarshal(data, &body) == nil {
+		apiErr.Code = body.Code
+		if apiErr.Code == "" && body.TwoFactorRequired {
+			apiErr.Code = "two_factor_required"
+		}
+		apiErr.Message = body.Message
+		if apiErr.Message == "" {
+			apiErr.Message = body.Error
+		}
+	}
+	return apiErr
+}
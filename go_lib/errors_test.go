@@ -0,0 +1,97 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestErrorForStatus(t *testing.T) {
+	tests := []struct {
+		status  int
+		body    string
+		want    error
+		message string
+	}{
+		{status: 401, want: ErrNotAuthenticated, message: "GET /api/user: 401 Unauthorized"},
+		{status: 401, body: `{"two_factor_required": true}`, want: Err2FARequired, message: "GET /api/user: 401 Unauthorized (two_factor_required)"},
+		{status: 403, want: ErrForbidden, message: "GET /api/user: 403 Forbidden"},
+		{status: 404, body: `{"error": "no such user"}`, want: ErrNotFound, message: "GET /api/user: 404 no such user"},
+		{status: 404, body: `{"code": "file_missing"}`, want: ErrFileMissing, message: "GET /api/user: 404 Not Found (file_missing)"},
+		{status: 405, want: ErrNotSupported, message: "GET /api/user: 405 Method Not Allowed"},
+		{status: 400, body: `{"code": "invalid_path", "message": "bad dir"}`, want: ErrInvalidPath, message: "GET /api/user: 400 bad dir (invalid_path)"},
+		{status: 409, body: `{"code": "still_running"}`, want: ErrStillRunning, message: "GET /api/user: 409 Conflict (still_running)"},
+		{status: 409, want: ErrConflict, message: "GET /api/user: 409 Conflict"},
+		{status: 429, want: ErrRateLimited, message: "GET /api/user: 429 Too Many Requests"},
+		{status: 503, want: ErrServerUnavailable, message: "GET /api/user: 503 Service Unavailable"},
+		{status: 504, want: ErrServerUnavailable, message: "GET /api/user: 504 Gateway Timeout"},
+		{status: 500, body: "<html>oops</html>", message: "GET /api/user: 500 Internal Server Error"},
+	}
+	for _, tt := range tests {
+		response := &http.Response{StatusCode: tt.status, Body: ioutil.NopCloser(strings.NewReader(tt.body))}
+		err := errorForStatus("GET", "/api/user", response)
+		if err.Error() != tt.message {
+			t.Errorf("%d: message = %q, want %q", tt.status, err.Error(), tt.message)
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%d: got %v, want %v", tt.status, err, tt.want)
+		}
+		if unwrapped := err.(*APIError).Unwrap(); tt.want == nil && len(unwrapped) > 0 {
+			t.Errorf("%d: unwraps to %v, want nothing", tt.status, unwrapped)
+		}
+	}
+}
+
+func TestAPIErrorDetails(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-42")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"message": "database down"}`))
+	}))
+	_, err := b.Downloads.GetDownloadRequest(context.Background(), 7)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got %v, want an APIError", err)
+	}
+	if apiErr.StatusCode != 500 || apiErr.Endpoint != "/api/download/7" || apiErr.RequestID != "req-42" || string(apiErr.Body) != `{"message": "database down"}` {
+		t.Errorf("APIError = %+v", apiErr)
+	}
+	if want := "GET /api/download/7: 500 database down, request req-42"; err.Error() != want {
+		t.Errorf("message = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestAPIErrorBodyLimit(t *testing.T) {
+	body := strings.Repeat("x", 2*maxErrorBody)
+	response := &http.Response{StatusCode: 502, Body: ioutil.NopCloser(strings.NewReader(body))}
+	var apiErr *APIError
+	if !errors.As(errorForStatus("GET", "/api/user", response), &apiErr) || len(apiErr.Body) != maxErrorBody {
+		t.Errorf("kept %d bytes of the body, want %d", len(apiErr.Body), maxErrorBody)
+	}
+}
+
+func TestAPIErrorMatchesCodeAndStatus(t *testing.T) {
+	err := &APIError{StatusCode: http.StatusConflict, Code: "still_running"}
+	if !errors.Is(err, ErrStillRunning) || !errors.Is(err, ErrConflict) {
+		t.Errorf("%v should match both ErrStillRunning and ErrConflict", err)
+	}
+	if !errors.Is(&APIError{StatusCode: http.StatusUnauthorized}, ErrUnauthorized) {
+		t.Error("401 should match ErrUnauthorized")
+	}
+}
@@ -0,0 +1,200 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// exportPageSize : Number of records fetched per request while exporting
+const exportPageSize = 100
+
+// ExportUsersCSV : Function to write every user to w as CSV with a header
+// row. Pages are fetched one at a time, each after the previous one was
+// written, so a slow writer slows the export down instead of records piling
+// up in memory. A write error stops the export and is returned.
+func (s *AdminService) ExportUsersCSV(ctx context.Context, w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"user_name", "email", "auth_level", "verified"})
+	return exportPages(ctx, csvFlush(writer), func(offset int) (int, error) {
+		var users []User
+		if err := s.client.do(ctx, "GET", pageEndpoint("/api/user", exportPageSize, offset), nil, &users); err != nil {
+			return 0, err
+		}
+		for _, user := range users {
+			writer.Write([]string{
+				user.UserName,
+				user.Email,
+				strconv.Itoa(int(user.AuthLevel)),
+				strconv.FormatBool(user.Verified),
+			})
+		}
+		return len(users), nil
+	})
+}
+
+// ExportDownloads : Function to write every download to w as CSV with a
+// header row, paging like ExportUsersCSV
+func (s *DownloadsService) ExportDownloads(ctx context.Context, w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"id", "link", "user_name", "download_name", "status", "size", "added_time", "completed_time"})
+	return exportPages(ctx, csvFlush(writer), func(offset int) (int, error) {
+		var downloads []Download
+		if err := s.client.do(ctx, "GET", pageEndpoint("/api/downloads", exportPageSize, offset), nil, &downloads); err != nil {
+			return 0, err
+		}
+		for _, download := range downloads {
+			writer.Write([]string{
+				strconv.Itoa(download.ID),
+				download.Link,
+				download.UserName,
+				download.Name,
+				string(download.Status),
+				strconv.FormatInt(download.Size, 10),
+				formatTime(download.AddedAt),
+				formatTime(download.CompletedAt),
+			})
+		}
+		return len(downloads), nil
+	})
+}
+
+// Format : Encoding of the records written by Export
+type Format string
+
+// Formats supported by Export
+const (
+	// FormatCSV writes a header row followed by a row per record
+	FormatCSV Format = "csv"
+	// FormatJSONLines writes a JSON object per line
+	FormatJSONLines Format = "jsonl"
+)
+
+// Export : Function to write the download history of the logged in user to
+// w in format, narrowed down by opts, paging like ExportUsersCSV. Limit caps
+// the number of records written, from Offset on.
+func (s *DownloadsService) Export(ctx context.Context, w io.Writer, format Format, opts ListDownloadsOptions) error {
+	if _, err := opts.query(); err != nil {
+		return err
+	}
+	// Limit and Offset apply to the whole export, pages get their own
+	limit, start := opts.Limit, opts.Offset
+	opts.Limit, opts.Offset = 0, 0
+	query, _ := opts.query()
+	endpoint := withQuery("/api/user/downloads", query)
+
+	var write func(DownloadRecord) error
+	var flush func() error
+	switch format {
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"id", "link", "download_name", "status", "size", "added_time", "completed_time", "rating"})
+		write = func(record DownloadRecord) error {
+			return writer.Write([]string{
+				strconv.Itoa(record.ID),
+				record.Link,
+				record.Name,
+				string(record.Status),
+				strconv.FormatInt(record.Size, 10),
+				formatTime(record.AddedAt),
+				formatTime(record.CompletedAt),
+				strconv.Itoa(record.Rating),
+			})
+		}
+		flush = csvFlush(writer)
+	case FormatJSONLines:
+		buffered := bufio.NewWriter(w)
+		encoder := json.NewEncoder(buffered)
+		write = func(record DownloadRecord) error {
+			return encoder.Encode(record)
+		}
+		flush = buffered.Flush
+	default:
+		return invalidParam("format", "has unknown value "+strconv.Quote(string(format)))
+	}
+
+	return exportPages(ctx, flush, func(offset int) (int, error) {
+		size := exportPageSize
+		if limit > 0 && limit-offset < size {
+			if size = limit - offset; size <= 0 {
+				return 0, nil
+			}
+		}
+		var records []DownloadRecord
+		if err := s.client.do(ctx, "GET", pageEndpoint(endpoint, size, start+offset), nil, &records); err != nil {
+			return 0, err
+		}
+		for _, record := range records {
+			if err := write(record); err != nil {
+				return 0, err
+			}
+		}
+		return len(records), nil
+	})
+}
+
+// csvFlush : Helper function returning a flush for exportPages writing out
+// writer and reporting its first error
+func csvFlush(writer *csv.Writer) func() error {
+	return func() error {
+		writer.Flush()
+		return writer.Error()
+	}
+}
+
+// exportPages : Helper function calling fetch for consecutive pages until a
+// short page, calling flush after each one so write errors end the export
+// before the next page is requested
+func exportPages(ctx context.Context, flush func() error, fetch func(offset int) (int, error)) error {
+	if err := flush(); err != nil {
+		return err
+	}
+	for offset := 0; ; offset += exportPageSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := fetch(offset)
+		if err != nil {
+			return err
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		if n < exportPageSize {
+			return nil
+		}
+	}
+}
+
+// pageEndpoint : Helper function adding paging parameters to a listing endpoint
+func pageEndpoint(endpoint string, limit int, offset int) string {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
+	return withQuery(endpoint, query)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,194 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// pagedUsers : Handler serving total users in pages of exportPageSize
+func pagedUsers(t *testing.T, total int, requests *int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		if limit != exportPageSize {
+			t.Errorf("limit = %d, want %d", limit, exportPageSize)
+		}
+		users := []User{}
+		for i := offset; i < total && i < offset+limit; i++ {
+			users = append(users, User{UserName: "user" + strconv.Itoa(i), Email: "user" + strconv.Itoa(i) + "@scorelab.org", AuthLevel: 1})
+		}
+		json.NewEncoder(w).Encode(users)
+	})
+}
+
+func TestExportUsersCSV(t *testing.T) {
+	var requests int32
+	b := newTestClient(t, pagedUsers(t, 2*exportPageSize+5, &requests))
+
+	var buf bytes.Buffer
+	if err := b.Admin.ExportUsersCSV(context.Background(), &buf); err != nil {
+		t.Fatalf("ExportUsersCSV: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2*exportPageSize+6 {
+		t.Fatalf("got %d lines, want %d", len(lines), 2*exportPageSize+6)
+	}
+	if lines[0] != "user_name,email,auth_level,verified" || lines[1] != "user0,user0@scorelab.org,1,false" {
+		t.Errorf("unexpected output start %q", lines[:2])
+	}
+	if requests != 3 {
+		t.Errorf("requests = %d, want 3", requests)
+	}
+}
+
+// failingWriter : Writer failing once more than limit bytes were written
+type failingWriter struct {
+	limit   int
+	written int
+}
+
+var errDiskFull = errors.New("disk full")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.written+len(p) > w.limit {
+		return 0, errDiskFull
+	}
+	w.written += len(p)
+	return len(p), nil
+}
+
+func TestExportUsersCSVStopsOnWriteError(t *testing.T) {
+	var requests int32
+	b := newTestClient(t, pagedUsers(t, 10*exportPageSize, &requests))
+
+	// Room for the header only, so the first page can't be written
+	err := b.Admin.ExportUsersCSV(context.Background(), &failingWriter{limit: 64})
+	if !errors.Is(err, errDiskFull) {
+		t.Fatalf("got %v, want the write error", err)
+	}
+	if requests != 1 {
+		t.Errorf("requests = %d, want 1: export kept fetching after the write failed", requests)
+	}
+}
+
+func TestExportUsersCSVHeaderWriteError(t *testing.T) {
+	var requests int32
+	b := newTestClient(t, pagedUsers(t, 1, &requests))
+	if err := b.Admin.ExportUsersCSV(context.Background(), &failingWriter{}); !errors.Is(err, errDiskFull) {
+		t.Fatalf("got %v, want the write error", err)
+	}
+	if requests != 0 {
+		t.Errorf("requests = %d, want 0", requests)
+	}
+}
+
+func TestExportDownloads(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/downloads" {
+			t.Errorf("path = %s, want /api/downloads", r.URL.Path)
+		}
+		w.Write([]byte(`[{"id": 3, "link": "http://www.scorelab.org/assets/img/score.jpg", "user_name": "rand",
+			"download_name": "score.jpg", "status": "completed", "size": 2048,
+			"added_time": "2020-06-01T10:00:00Z", "completed_time": "2020-06-01T10:05:00Z"}]`))
+	}))
+	var buf bytes.Buffer
+	if err := b.Downloads.ExportDownloads(context.Background(), &buf); err != nil {
+		t.Fatalf("ExportDownloads: %v", err)
+	}
+	want := "id,link,user_name,download_name,status,size,added_time,completed_time\n" +
+		"3,http://www.scorelab.org/assets/img/score.jpg,rand,score.jpg,completed,2048,2020-06-01T10:00:00Z,2020-06-01T10:05:00Z\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+// pagedHistory : Handler serving total history records in the pages asked for
+func pagedHistory(t *testing.T, total int, queries *[]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/user/downloads" {
+			t.Errorf("path = %s, want /api/user/downloads", r.URL.Path)
+		}
+		*queries = append(*queries, r.URL.RawQuery)
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		records := []DownloadRecord{}
+		for i := offset; i < total && i < offset+limit; i++ {
+			records = append(records, DownloadRecord{ID: i + 1, Name: "file" + strconv.Itoa(i+1), Status: StatusCompleted})
+		}
+		json.NewEncoder(w).Encode(records)
+	})
+}
+
+func TestExportHistory(t *testing.T) {
+	var queries []string
+	b := newTestClient(t, pagedHistory(t, 2, &queries))
+
+	var buf bytes.Buffer
+	if err := b.Downloads.Export(context.Background(), &buf, FormatCSV, ListDownloadsOptions{Status: StatusCompleted}); err != nil {
+		t.Fatal(err)
+	}
+	want := "id,link,download_name,status,size,added_time,completed_time,rating\n" +
+		"1,,file1,completed,0,,,0\n2,,file2,completed,0,,,0\n"
+	if buf.String() != want {
+		t.Errorf("CSV: got\n%s\nwant\n%s", buf.String(), want)
+	}
+	if want := "status=completed&limit=100&offset=0"; len(queries) != 1 || queries[0] != want {
+		t.Errorf("queries = %q, want [%q]", queries, want)
+	}
+
+	buf.Reset()
+	if err := b.Downloads.Export(context.Background(), &buf, FormatJSONLines, ListDownloadsOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	var record DownloadRecord
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[1]), &record) != nil || record.ID != 2 {
+		t.Errorf("JSON lines: got %q", buf.String())
+	}
+}
+
+func TestExportHistoryLimit(t *testing.T) {
+	var queries []string
+	b := newTestClient(t, pagedHistory(t, 1000, &queries))
+	var buf bytes.Buffer
+	if err := b.Downloads.Export(context.Background(), &buf, FormatJSONLines, ListDownloadsOptions{Limit: 150, Offset: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(buf.String(), "\n"); n != 150 {
+		t.Errorf("wrote %d records, want 150", n)
+	}
+	if want := []string{"limit=100&offset=10", "limit=50&offset=110"}; strings.Join(queries, " ") != strings.Join(want, " ") {
+		t.Errorf("queries = %q, want %q", queries, want)
+	}
+}
+
+func TestExportHistoryValidation(t *testing.T) {
+	b := newOfflineClient()
+	if err := b.Downloads.Export(context.Background(), &bytes.Buffer{}, "xml", ListDownloadsOptions{}); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("unknown format: got %v", err)
+	}
+	if err := b.Downloads.Export(context.Background(), &bytes.Buffer{}, FormatCSV, ListDownloadsOptions{Limit: -1}); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("negative limit: got %v", err)
+	}
+}
@@ -0,0 +1,392 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// partSuffix : Suffix of the file FetchFile writes to until the transfer
+// completes
+const partSuffix = ".part"
+
+// FileInfo : Description of the file of a completed download, from the
+// headers the server sends with it
+type FileInfo struct {
+	// Name is the file name suggested by the server, empty if it gave none
+	Name string
+	// Size is the length of the file in bytes, -1 if the server didn't say
+	Size        int64
+	ContentType string
+	// ModTime is when the file last changed, zero if the server didn't say
+	ModTime time.Time
+}
+
+// newFileInfo : Helper function to describe the file served in response
+func newFileInfo(response *http.Response) FileInfo {
+	info := FileInfo{
+		Size:        response.ContentLength,
+		ContentType: response.Header.Get("Content-Type"),
+	}
+	if _, params, err := mime.ParseMediaType(response.Header.Get("Content-Disposition")); err == nil {
+		info.Name = filepath.Base(params["filename"])
+		if info.Name == "." || info.Name == string(filepath.Separator) {
+			info.Name = ""
+		}
+	}
+	if modTime, err := http.ParseTime(response.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = modTime
+	}
+	return info
+}
+
+// OpenFile : Function to stream the file of a completed download, e.g. into
+// object storage or a hash, without saving it to disk. The caller must close
+// the returned reader; the transfer only ends with it, ctx or the server, so
+// it isn't cut off by the client timeout. Errors are those of FetchFile.
+func (s *DownloadsService) OpenFile(ctx context.Context, downloadID int) (io.ReadCloser, FileInfo, error) {
+	body, info, _, err := s.client.openFile(ctx, downloadID, 0)
+	return body, info, err
+}
+
+// openFile : Function to stream the file of a download from byte offset
+// on, returning the offset the body actually starts at. Servers answering
+// the range with the whole file, or rejecting it, get the file from the
+// start, at offset 0. The size in the FileInfo is that of the whole file.
+func (b *Client) openFile(ctx context.Context, downloadID int, offset int64) (io.ReadCloser, FileInfo, int64, error) {
+	if downloadID <= 0 {
+		return nil, FileInfo{}, 0, invalidParam("downloadID", "must be positive")
+	}
+	endpoint := downloadEndpoint(downloadID, "file")
+	if offset > 0 {
+		header := http.Header{"Range": {"bytes=" + strconv.FormatInt(offset, 10) + "-"}}
+		response, err := b.stream(ctx, "GET", endpoint, header)
+		var apiErr *APIError
+		switch {
+		case err == nil && response.StatusCode != http.StatusPartialContent:
+			return response.Body, newFileInfo(response), 0, nil
+		case err == nil:
+			start, total, ok := parseContentRange(response.Header.Get("Content-Range"))
+			if ok && start == offset {
+				info := newFileInfo(response)
+				info.Size = total
+				return response.Body, info, offset, nil
+			}
+			response.Body.Close()
+		case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		default:
+			return nil, FileInfo{}, 0, err
+		}
+	}
+	response, err := b.stream(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, FileInfo{}, 0, err
+	}
+	return response.Body, newFileInfo(response), 0, nil
+}
+
+// parseContentRange : Helper function to get the first byte and the total
+// size, -1 if unknown, of a Content-Range header such as bytes 100-199/1000
+func parseContentRange(header string) (start int64, total int64, ok bool) {
+	spec := strings.TrimPrefix(header, "bytes ")
+	byteRange, size, found := strings.Cut(spec, "/")
+	if spec == header || !found {
+		return 0, 0, false
+	}
+	first, _, found := strings.Cut(byteRange, "-")
+	start, err := strconv.ParseInt(first, 10, 64)
+	if !found || err != nil || start < 0 {
+		return 0, 0, false
+	}
+	if size == "*" {
+		return start, -1, true
+	}
+	if total, err = strconv.ParseInt(size, 10, 64); err != nil || total <= start {
+		return 0, 0, false
+	}
+	return start, total, true
+}
+
+// FetchOption : Optional setting of a file transfer made with
+// DownloadsService.FetchFile
+type FetchOption func(*fetchOptions)
+
+// fetchOptions : Settings of a file transfer
+type fetchOptions struct {
+	perm             os.FileMode
+	progress         func(transferred, total int64)
+	progressInterval time.Duration
+	resume           bool
+	// chunkSize and parallelism are set by WithParallelChunks
+	chunkSize   int64
+	parallelism int
+	// err is an invalid setting, returned by FetchFile
+	err error
+}
+
+// defaultProgressInterval : Time between two progress callbacks unless
+// WithProgressInterval says otherwise
+const defaultProgressInterval = 500 * time.Millisecond
+
+// WithFileMode : Fetch option to create the file with the permissions perm,
+// before the umask, instead of 0644
+func WithFileMode(perm os.FileMode) FetchOption {
+	return func(o *fetchOptions) {
+		o.perm = perm.Perm()
+	}
+}
+
+// WithProgress : Fetch option to have fn called with the number of bytes
+// transferred so far and the size of the file, -1 if the server didn't say,
+// at most once per progress interval and once more when the transfer
+// completes. fn is called from the transfer, which waits for it to return.
+func WithProgress(fn func(transferred, total int64)) FetchOption {
+	return func(o *fetchOptions) {
+		o.progress = fn
+	}
+}
+
+// WithProgressInterval : Fetch option to set the time between two progress
+// callbacks, 500ms by default. An interval of 0 reports every write.
+func WithProgressInterval(interval time.Duration) FetchOption {
+	return func(o *fetchOptions) {
+		o.progressInterval = interval
+	}
+}
+
+// WithResume : Fetch option to keep the .part file of a failed transfer and
+// continue from it with a range request next time, instead of starting
+// over. Servers not supporting ranges send the whole file again.
+func WithResume(resume bool) FetchOption {
+	return func(o *fetchOptions) {
+		o.resume = resume
+	}
+}
+
+// WithParallelChunks : Fetch option to get the file as ranges of chunkSize
+// bytes, at least 16 KiB, with up to parallelism requests at a time, which
+// is much faster over high-latency links. Files of at most one chunk, and
+// servers not supporting ranges, are fetched with a single request, as are
+// transfers resumed with WithResume. A failed parallel transfer leaves no
+// .part file, since it may have gaps.
+func WithParallelChunks(chunkSize int64, parallelism int) FetchOption {
+	return func(o *fetchOptions) {
+		switch {
+		case chunkSize < minChunkSize:
+			o.err = invalidParam("chunkSize", "must be at least "+strconv.Itoa(minChunkSize)+" bytes")
+		case parallelism <= 0:
+			o.err = invalidParam("parallelism", "must be positive")
+		default:
+			o.chunkSize, o.parallelism = chunkSize, parallelism
+		}
+	}
+}
+
+// progress : Progress of a transfer, reported to the callback set by
+// WithProgress. It is safe for concurrent use.
+type progress struct {
+	fn       func(transferred, total int64)
+	interval time.Duration
+	total    int64
+
+	mu          sync.Mutex
+	transferred int64
+	reported    time.Time
+}
+
+// newProgress : Function to track the progress of a transfer of total bytes
+// resumed at offset, nil when the options set no callback
+func (o fetchOptions) newProgress(offset int64, total int64) *progress {
+	if o.progress == nil {
+		return nil
+	}
+	return &progress{fn: o.progress, interval: o.progressInterval, total: total, transferred: offset}
+}
+
+// add : Function to count n more bytes transferred, reporting them if the
+// interval has passed since the last report
+func (p *progress) add(n int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.transferred += n
+	if now := time.Now(); now.Sub(p.reported) >= p.interval {
+		p.reported = now
+		p.fn(p.transferred, p.total)
+	}
+}
+
+// done : Function to report the final count of a completed transfer
+func (p *progress) done() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fn(p.transferred, p.total)
+}
+
+// progressWriter : Writer counting what goes through it towards a progress
+type progressWriter struct {
+	w        io.Writer
+	progress *progress
+}
+
+func (pw progressWriter) Write(b []byte) (int, error) {
+	n, err := pw.w.Write(b)
+	pw.progress.add(int64(n))
+	return n, err
+}
+
+// FetchFile : Function to save the file of a completed download to
+// destPath, creating its parent directories. The file is written next to
+// destPath with a .part suffix and only renamed to destPath once complete,
+// so destPath never holds a partial file; see WithResume to continue an
+// interrupted transfer. Unknown downloads give ErrNotFound, downloads whose
+// file the server no longer has ErrFileMissing and those not completed yet
+// ErrStillRunning, all wrapped in an *APIError.
+func (s *DownloadsService) FetchFile(ctx context.Context, downloadID int, destPath string, opts ...FetchOption) error {
+	if downloadID <= 0 {
+		return invalidParam("downloadID", "must be positive")
+	}
+	if strings.TrimSpace(destPath) == "" {
+		return invalidParam("destPath", "must not be empty")
+	}
+	options := fetchOptions{perm: 0644, progressInterval: defaultProgressInterval}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.err != nil {
+		return options.err
+	}
+	partPath := destPath + partSuffix
+	var offset int64
+	if options.resume {
+		if part, err := os.Stat(partPath); err == nil && part.Mode().IsRegular() {
+			offset = part.Size()
+		}
+	}
+	if offset == 0 && options.parallelism > 0 {
+		progress, ok, err := s.client.fetchParallel(ctx, downloadID, partPath, options)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if err := os.Rename(partPath, destPath); err != nil {
+				return err
+			}
+			progress.done()
+			return nil
+		}
+	}
+	body, info, offset, err := s.client.openFile(ctx, downloadID, offset)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return err
+	}
+	progress := options.newProgress(offset, info.Size)
+	if err := writeFile(partPath, options.perm, offset, body, progress); err != nil {
+		if !options.resume {
+			os.Remove(partPath)
+		}
+		return err
+	}
+	if err := os.Rename(partPath, destPath); err != nil {
+		return err
+	}
+	progress.done()
+	return nil
+}
+
+// fetchParallel : Function to write the file of a download to path as the
+// concurrent ranges set by WithParallelChunks. ok is false, with nothing
+// left at path, when the file is too small to split or the server doesn't
+// support ranges.
+func (b *Client) fetchParallel(ctx context.Context, downloadID int, path string, options fetchOptions) (progress *progress, ok bool, err error) {
+	endpoint := downloadEndpoint(downloadID, "file")
+	response, err := b.roundTrip(ctx, b.clientFor(ctx), "HEAD", endpoint, nil, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	response.Body.Close()
+	size := response.ContentLength
+	if size <= options.chunkSize || response.Header.Get("Accept-Ranges") != "bytes" {
+		return nil, false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, false, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, options.perm)
+	if err != nil {
+		return nil, false, err
+	}
+	progress = options.newProgress(0, size)
+	_, err = b.downloadChunks(ctx, endpoint, f, size, options.chunkSize, options.parallelism, progress)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		if errors.Is(err, errRangeIgnored) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return progress, true, nil
+}
+
+// writeFile : Helper function to write r to the file at path from byte
+// offset on, dropping anything after it, and sync the file to disk.
+// progress, when not nil, counts the bytes written.
+func writeFile(path string, perm os.FileMode, offset int64, r io.Reader, progress *progress) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, perm)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := io.Copy(progressWriter{w: f, progress: progress}, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,324 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOpenFile(t *testing.T) {
+	modTime := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/download/5/file" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/x-iso9660-image")
+		w.Header().Set("Content-Disposition", `attachment; filename="../debian 12.iso"`)
+		w.Header().Set("Last-Modified", modTime.Format(http.TimeFormat))
+		w.Header().Set("Content-Length", "5")
+		w.Write([]byte("bassa"))
+	}))
+	body, info, err := b.Downloads.OpenFile(context.Background(), 5)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	defer body.Close()
+	want := FileInfo{Name: "debian 12.iso", Size: 5, ContentType: "application/x-iso9660-image", ModTime: modTime}
+	if info != want {
+		t.Errorf("info = %+v, want %+v", info, want)
+	}
+	if got, err := io.ReadAll(body); err != nil || string(got) != "bassa" {
+		t.Errorf("read %q, %v", got, err)
+	}
+}
+
+func TestOpenFileUnknownSize(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+		w.Write([]byte("bassa"))
+	}))
+	body, info, err := b.Downloads.OpenFile(context.Background(), 5)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	body.Close()
+	if info.Size != -1 || info.Name != "" || !info.ModTime.IsZero() {
+		t.Errorf("info = %+v, want an unknown size and no name", info)
+	}
+	if _, _, err := newOfflineClient().Downloads.OpenFile(context.Background(), -1); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("id -1: got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestFetchFile(t *testing.T) {
+	content := []byte(strings.Repeat("bassa", 1000))
+	var gets int32
+	b := newTestClient(t, fileServer(t, content, true, &gets))
+	dest := filepath.Join(t.TempDir(), "isos", "a.iso")
+	if err := b.Downloads.FetchFile(context.Background(), 5, dest, WithFileMode(0600)); err != nil {
+		t.Fatalf("FetchFile: %v", err)
+	}
+	got, err := os.ReadFile(dest)
+	if err != nil || string(got) != string(content) {
+		t.Fatalf("read %d bytes, %v; want %d", len(got), err, len(content))
+	}
+	if info, _ := os.Stat(dest); info.Mode().Perm()&0077 != 0 {
+		t.Errorf("mode = %v, want no group or other permissions", info.Mode())
+	}
+	if _, err := os.Stat(dest + partSuffix); !os.IsNotExist(err) {
+		t.Errorf("partial file left behind: %v", err)
+	}
+}
+
+func TestFetchFileProgress(t *testing.T) {
+	content := []byte(strings.Repeat("bassa", 20000))
+	var gets int32
+	b := newTestClient(t, fileServer(t, content, true, &gets))
+	var reports [][2]int64
+	progress := WithProgress(func(transferred, total int64) {
+		reports = append(reports, [2]int64{transferred, total})
+	})
+	dest := filepath.Join(t.TempDir(), "a.iso")
+	if err := b.Downloads.FetchFile(context.Background(), 5, dest, progress, WithProgressInterval(0)); err != nil {
+		t.Fatalf("FetchFile: %v", err)
+	}
+	if len(reports) < 2 {
+		t.Fatalf("got %d reports, want several", len(reports))
+	}
+	for i, report := range reports {
+		if report[1] != int64(len(content)) {
+			t.Errorf("report %d total = %d, want %d", i, report[1], len(content))
+		}
+		if i > 0 && report[0] < reports[i-1][0] {
+			t.Errorf("report %d went back from %d to %d", i, reports[i-1][0], report[0])
+		}
+	}
+	if last := reports[len(reports)-1]; last[0] != int64(len(content)) {
+		t.Errorf("last report = %d, want %d", last[0], len(content))
+	}
+
+	reports = nil
+	if err := b.Downloads.FetchFile(context.Background(), 5, dest, progress, WithProgressInterval(time.Hour)); err != nil {
+		t.Fatalf("FetchFile: %v", err)
+	}
+	if len(reports) != 2 || reports[1][0] != int64(len(content)) {
+		t.Errorf("reports = %v, want the first write and the completion", reports)
+	}
+}
+
+func TestFetchFileMissing(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"code": "file_missing", "message": "file was cleaned up"}`))
+	}))
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "a.iso")
+	err := b.Downloads.FetchFile(context.Background(), 5, dest)
+	if !errors.Is(err, ErrFileMissing) || !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrFileMissing", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("left %d files behind", len(entries))
+	}
+}
+
+func TestFetchFileInterrupted(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		w.Write([]byte("only part"))
+	}))
+	dest := filepath.Join(t.TempDir(), "a.iso")
+	if err := os.WriteFile(dest, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Downloads.FetchFile(context.Background(), 5, dest); err == nil {
+		t.Fatal("FetchFile of a truncated response succeeded")
+	}
+	if got, _ := os.ReadFile(dest); string(got) != "old" {
+		t.Errorf("destination = %q, want it untouched", got)
+	}
+}
+
+func TestFetchFileResume(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 1000))
+	tests := []struct {
+		name       string
+		ranges     bool
+		part       []byte
+		rangedGets int32
+		// total is the size reported to the progress callback
+		total int64
+	}{
+		{"resumed", true, content[:4000], 1, int64(len(content))},
+		{"no ranges", false, []byte("stale data"), 1, -1},
+		{"part too long", true, append(append([]byte(nil), content...), "trailing"...), 1, int64(len(content))},
+		{"no part", true, nil, 0, int64(len(content))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gets int32
+			b := newTestClient(t, fileServer(t, content, tt.ranges, &gets))
+			dest := filepath.Join(t.TempDir(), "a.iso")
+			if tt.part != nil {
+				if err := os.WriteFile(dest+partSuffix, tt.part, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var first, total int64 = -1, 0
+			progress := WithProgress(func(transferred, size int64) {
+				if first < 0 {
+					first = transferred
+				}
+				total = size
+			})
+			if err := b.Downloads.FetchFile(context.Background(), 5, dest, WithResume(true), progress); err != nil {
+				t.Fatalf("FetchFile: %v", err)
+			}
+			if got, _ := os.ReadFile(dest); string(got) != string(content) {
+				t.Errorf("got %d bytes, want the %d of the file", len(got), len(content))
+			}
+			if gets != tt.rangedGets {
+				t.Errorf("made %d ranged requests, want %d", gets, tt.rangedGets)
+			}
+			if total != tt.total {
+				t.Errorf("progress total = %d, want %d", total, tt.total)
+			}
+			if tt.name == "resumed" && first <= 4000 {
+				t.Errorf("first progress report = %d, want it past the resumed part", first)
+			}
+		})
+	}
+}
+
+func TestFetchFileResumeKeepsPart(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		w.Write([]byte("only part"))
+	}))
+	dest := filepath.Join(t.TempDir(), "a.iso")
+	if err := b.Downloads.FetchFile(context.Background(), 5, dest, WithResume(true)); err == nil {
+		t.Fatal("FetchFile of a truncated response succeeded")
+	}
+	if got, _ := os.ReadFile(dest + partSuffix); string(got) != "only part" {
+		t.Errorf("part = %q, want what was received", got)
+	}
+}
+
+func TestFetchFileParallel(t *testing.T) {
+	content := make([]byte, 200<<10)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+	var gets, active, maxActive int32
+	serve := fileServer(t, content, true, &gets)
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+		for m := atomic.LoadInt32(&maxActive); n > m && !atomic.CompareAndSwapInt32(&maxActive, m, n); m = atomic.LoadInt32(&maxActive) {
+		}
+		time.Sleep(5 * time.Millisecond)
+		serve(w, r)
+	}))
+	var transferred int64
+	progress := WithProgress(func(n, total int64) {
+		if total != int64(len(content)) {
+			t.Errorf("progress total = %d, want %d", total, len(content))
+		}
+		transferred = n
+	})
+	dest := filepath.Join(t.TempDir(), "a.bin")
+	if err := b.Downloads.FetchFile(context.Background(), 5, dest, WithParallelChunks(minChunkSize, 3), progress); err != nil {
+		t.Fatalf("FetchFile: %v", err)
+	}
+	if got, _ := os.ReadFile(dest); string(got) != string(content) {
+		t.Errorf("reassembled file differs from the original")
+	}
+	if want := int32((len(content) + minChunkSize - 1) / minChunkSize); gets != want {
+		t.Errorf("made %d ranged requests, want %d", gets, want)
+	}
+	if maxActive > 3 {
+		t.Errorf("%d requests ran at once, want at most 3", maxActive)
+	}
+	if transferred != int64(len(content)) {
+		t.Errorf("last progress report = %d, want %d", transferred, len(content))
+	}
+}
+
+func TestFetchFileParallelFallback(t *testing.T) {
+	content := []byte(strings.Repeat("bassa", 10000))
+	for _, ranges := range []bool{false, true} {
+		var gets int32
+		b := newTestClient(t, fileServer(t, content, ranges, &gets))
+		dest := filepath.Join(t.TempDir(), "a.bin")
+		// without ranges the file is fetched whole; with them it fits one chunk
+		if err := b.Downloads.FetchFile(context.Background(), 5, dest, WithParallelChunks(1<<20, 4)); err != nil {
+			t.Fatalf("ranges %v: FetchFile: %v", ranges, err)
+		}
+		if got, _ := os.ReadFile(dest); string(got) != string(content) {
+			t.Errorf("ranges %v: got %d bytes, want %d", ranges, len(got), len(content))
+		}
+		if gets != 0 {
+			t.Errorf("ranges %v: made %d ranged requests, want none", ranges, gets)
+		}
+	}
+}
+
+func TestWithParallelChunksInvalid(t *testing.T) {
+	b := newOfflineClient()
+	for _, opt := range []FetchOption{WithParallelChunks(minChunkSize-1, 2), WithParallelChunks(minChunkSize, 0)} {
+		if err := b.Downloads.FetchFile(context.Background(), 5, "a.bin", opt); !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("got %v, want ErrInvalidParams", err)
+		}
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		header       string
+		start, total int64
+		ok           bool
+	}{
+		{"bytes 100-199/1000", 100, 1000, true},
+		{"bytes 0-9/*", 0, -1, true},
+		{"bytes */1000", 0, 0, false},
+		{"bytes 100-199/50", 0, 0, false},
+		{"100-199/1000", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, tt := range tests {
+		start, total, ok := parseContentRange(tt.header)
+		if start != tt.start || total != tt.total || ok != tt.ok {
+			t.Errorf("%q: got %d, %d, %v; want %d, %d, %v", tt.header, start, total, ok, tt.start, tt.total, tt.ok)
+		}
+	}
+}
+
+func TestFetchFileValidation(t *testing.T) {
+	b := newOfflineClient()
+	if err := b.Downloads.FetchFile(context.Background(), 0, "a.iso"); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("id 0: got %v, want ErrInvalidParams", err)
+	}
+	if err := b.Downloads.FetchFile(context.Background(), 5, " "); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("empty path: got %v, want ErrInvalidParams", err)
+	}
+}
@@ -0,0 +1,247 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Some field names changed between Bassa server releases. Go structs always
+// carry the current (canonical) name in their json tags; the helpers below
+// translate to and from the name used on the wire by a given server version.
+
+// currentFieldsSince : First server version using the canonical field names.
+// Servers reporting an older version get the legacy names.
+const currentFieldsSince = "1.0.0"
+
+// legacyFieldNames : canonical field name -> name used by pre-1.0 servers
+var legacyFieldNames = map[string]string{
+	"auth_level": "auth",
+}
+
+// fieldMapping : canonical field name -> wire field name for one server version.
+// A nil mapping means the wire names are the canonical ones.
+type fieldMapping map[string]string
+
+// fieldMappingFor : Helper function to pick the field mapping for a server
+// version. An unknown (empty) version gets the canonical names.
+func fieldMappingFor(version string) fieldMapping {
+	if version != "" && compareVersions(version, currentFieldsSince) < 0 {
+		return legacyFieldNames
+	}
+	return nil
+}
+
+// fields : Function returning the field mapping for the detected server version
+func (b *Client) fields() fieldMapping {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return fieldMappingFor(b.serverVersion)
+}
+
+// setServerVersion : Function to record the version reported by the server,
+// switching request bodies to its field names
+func (b *Client) setServerVersion(version string) {
+	if version = strings.TrimSpace(version); version == "" {
+		return
+	}
+	b.mu.Lock()
+	b.serverVersion = version
+	b.mu.Unlock()
+}
+
+// marshal : Function to encode v as JSON using the wire names of the mapping
+func (m fieldMapping) marshal(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(m) == 0 {
+		return data, err
+	}
+	return renameFields(data, m)
+}
+
+// decodeFields : Helper function to decode a response into v. Legacy field
+// names are accepted from any server so decoding never depends on detection,
+// but only for the fields v declares: a legacy name in other payloads keeps
+// its meaning.
+func decodeFields(data []byte, v interface{}) error {
+	data, err := canonicalFields(data, v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// canonicalFields : Helper function to rename the legacy field names in
+// data to the canonical ones, for the fields v declares
+func canonicalFields(data []byte, v interface{}) ([]byte, error) {
+	aliases := map[string]string{}
+	declared := jsonFieldNames(reflect.TypeOf(v), map[reflect.Type]bool{})
+	for canonical, legacy := range legacyFieldNames {
+		if declared[canonical] {
+			aliases[legacy] = canonical
+		}
+	}
+	if len(aliases) == 0 {
+		return data, nil
+	}
+	return renameFields(data, aliases)
+}
+
+// jsonFieldNames : Helper function to collect the json names of the struct
+// fields reachable from t, through pointers, slices, arrays and maps
+func jsonFieldNames(t reflect.Type, seen map[reflect.Type]bool) map[string]bool {
+	names := map[string]bool{}
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct || seen[t] {
+		return names
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+			names[name] = true
+		}
+		for name := range jsonFieldNames(field.Type, seen) {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// renameFields : Helper function to rename object keys anywhere in a JSON
+// document. A key is left alone if its new name is already present.
+func renameFields(data []byte, names map[string]string) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(renameValue(doc, names))
+}
+
+func renameValue(v interface{}, names map[string]string) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = renameValue(item, names)
+		}
+		for from, to := range names {
+			item, ok := value[from]
+			if !ok {
+				continue
+			}
+			if _, taken := value[to]; !taken {
+				value[to] = item
+			}
+			delete(value, from)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = renameValue(item, names)
+		}
+	}
+	return v
+}
+
+// redactFields : Helper function to drop object keys anywhere in a JSON document
+func redactFields(data []byte, names map[string]bool) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(redactValue(doc, names))
+}
+
+func redactValue(v interface{}, names map[string]bool) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if names[key] {
+				delete(value, key)
+				continue
+			}
+			value[key] = redactValue(item, names)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = redactValue(item, names)
+		}
+	}
+	return v
+}
+
+// compareVersions : Helper function comparing two dotted version strings,
+// returning -1, 0 or 1. A leading "v" and any pre-release/build suffix are
+// ignored and missing components count as zero, so "1.2" equals "v1.2.0".
+func compareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	for len(pa) < len(pb) {
+		pa = append(pa, 0)
+	}
+	for len(pb) < len(pa) {
+		pb = append(pb, 0)
+	}
+	for i := range pa {
+		if pa[i] < pb[i] {
+			return -1
+		}
+		if pa[i] > pb[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+// validVersion : Helper function to check that a version consists of dotted
+// numbers, optionally with a leading "v" and a pre-release/build suffix
+func validVersion(version string) bool {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	if version == "" {
+		return false
+	}
+	for _, field := range strings.Split(version, ".") {
+		if _, err := strconv.Atoi(field); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func versionParts(version string) []int {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexAny(version, "-+ "); i >= 0 {
+		version = version[:i]
+	}
+	var parts []int
+	for _, field := range strings.Split(version, ".") {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			n = 0
+		}
+		parts = append(parts, n)
+	}
+	return parts
+}
@@ -0,0 +1,145 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDecodeFieldsAcceptsBothNames(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"current", `{"user_name": "rand", "email": "rand@scorelab.org", "auth_level": 0}`},
+		{"legacy", `{"user_name": "rand", "email": "rand@scorelab.org", "auth": 0}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := User{AuthLevel: -1}
+			if err := decodeFields([]byte(tt.body), &user); err != nil {
+				t.Fatalf("decodeFields: %v", err)
+			}
+			want := User{UserName: "rand", Email: "rand@scorelab.org", AuthLevel: 0}
+			if user != want {
+				t.Errorf("got %+v, want %+v", user, want)
+			}
+		})
+	}
+}
+
+func TestDecodeFieldsNested(t *testing.T) {
+	var users []User
+	body := `[{"user_name": "a", "auth": 1}, {"user_name": "b", "auth_level": 0}]`
+	if err := decodeFields([]byte(body), &users); err != nil {
+		t.Fatalf("decodeFields: %v", err)
+	}
+	if len(users) != 2 || users[0].AuthLevel != 1 || users[1].AuthLevel != 0 {
+		t.Errorf("got %+v", users)
+	}
+}
+
+func TestFieldMappingMarshal(t *testing.T) {
+	tests := []struct {
+		version string
+		key     string
+	}{
+		{"", "auth_level"},
+		{"0.9.3", "auth"},
+		{"v0.9", "auth"},
+		{"1.0.0", "auth_level"},
+		{"1.2.0-beta", "auth_level"},
+	}
+	for _, tt := range tests {
+		data, err := fieldMappingFor(tt.version).marshal(User{UserName: "rand", AuthLevel: 1})
+		if err != nil {
+			t.Fatalf("marshal(%q): %v", tt.version, err)
+		}
+		var doc map[string]interface{}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			t.Fatal(err)
+		}
+		_, hasCurrent := doc["auth_level"]
+		_, hasLegacy := doc["auth"]
+		if _, ok := doc[tt.key]; !ok || hasCurrent == hasLegacy {
+			t.Errorf("version %q: got %s, want key %q", tt.version, data, tt.key)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.2", "v1.2.0", 0},
+		{"0.9.9", "1.0.0", -1},
+		{"1.10.0", "1.9.0", 1},
+		{"2.0.0-rc1", "2.0.0", 0},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeFieldsOnlyRenamesDeclaredFields(t *testing.T) {
+	// A map declares no auth_level, so its "auth" key means something else
+	var doc map[string]interface{}
+	if err := decodeFields([]byte(`{"auth": "basic"}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["auth"] != "basic" || doc["auth_level"] != nil {
+		t.Errorf("got %v, want the auth key untouched", doc)
+	}
+	var wrapped struct {
+		Users []User `json:"users"`
+	}
+	if err := decodeFields([]byte(`{"users": [{"user_name": "a", "auth": 1}]}`), &wrapped); err != nil {
+		t.Fatal(err)
+	}
+	if len(wrapped.Users) != 1 || wrapped.Users[0].AuthLevel != 1 {
+		t.Errorf("got %+v, want the nested legacy name decoded", wrapped)
+	}
+}
+
+func TestDetectedVersionSelectsLegacyNames(t *testing.T) {
+	var body map[string]interface{}
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/info":
+			w.Write([]byte(`{"version": "0.9.2", "uptime": 60}`))
+		case "/api/user":
+			json.NewDecoder(r.Body).Decode(&body)
+		}
+	}))
+	if data, _ := b.fields().marshal(User{AuthLevel: 1}); !strings.Contains(string(data), `"auth_level"`) {
+		t.Fatalf("before detection: got %s, want canonical names", data)
+	}
+	if _, _, err := b.GetUptime(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.do(context.Background(), "POST", "/api/user", User{UserName: "rand", AuthLevel: 1}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := body["auth"]; !ok || body["auth_level"] != nil {
+		t.Errorf("old server received %v, want the legacy auth key", body)
+	}
+}
@@ -0,0 +1,136 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// FileNode : File or directory within a download. Directories have no
+// progress of their own; their Size and Progress are totals of Children.
+type FileNode struct {
+	Name string `json:"name"`
+	// Path is relative to the download root, using forward slashes
+	Path     string     `json:"path"`
+	IsDir    bool       `json:"is_dir"`
+	Size     int64      `json:"size"`
+	Progress float64    `json:"progress"`
+	Children []FileNode `json:"children,omitempty"`
+}
+
+// fileEntry : File of a download as listed by servers returning a flat list
+type fileEntry struct {
+	Path     string  `json:"path"`
+	Size     int64   `json:"size"`
+	Progress float64 `json:"progress"`
+}
+
+// GetDownloadFileTree : Function to get the files of a download, e.g. a
+// multi-file torrent, as a tree rooted at an unnamed directory. Servers
+// answering with a flat list of paths have it turned into a tree.
+func (s *DownloadsService) GetDownloadFileTree(ctx context.Context, downloadID int) (FileNode, error) {
+	if downloadID <= 0 {
+		return FileNode{}, invalidParam("downloadID", "must be positive")
+	}
+	var raw json.RawMessage
+	if err := s.client.do(ctx, "GET", downloadEndpoint(downloadID, "files"), nil, &raw); err != nil {
+		return FileNode{}, err
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+		var entries []fileEntry
+		if err := json.Unmarshal(raw, &entries); err != nil {
+			return FileNode{}, err
+		}
+		return buildFileTree(entries), nil
+	}
+	var root FileNode
+	if err := decodeFields(raw, &root); err != nil {
+		return FileNode{}, err
+	}
+	return root, nil
+}
+
+// buildFileTree : Helper function to nest a flat file list into directories.
+// Children are listed directories first, then by name.
+func buildFileTree(entries []fileEntry) FileNode {
+	root := &FileNode{IsDir: true}
+	for _, entry := range entries {
+		var parts []string
+		for _, part := range strings.Split(entry.Path, "/") {
+			if part != "" {
+				parts = append(parts, part)
+			}
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		node := root
+		for i, part := range parts {
+			child := childNamed(node, part)
+			if child == nil {
+				node.Children = append(node.Children, FileNode{
+					Name:  part,
+					Path:  strings.Join(parts[:i+1], "/"),
+					IsDir: i < len(parts)-1,
+				})
+				child = &node.Children[len(node.Children)-1]
+			}
+			node = child
+		}
+		node.Size = entry.Size
+		node.Progress = entry.Progress
+	}
+	summarize(root)
+	return *root
+}
+
+// childNamed : Helper function to find the direct child of a directory
+func childNamed(dir *FileNode, name string) *FileNode {
+	for i := range dir.Children {
+		if dir.Children[i].Name == name {
+			return &dir.Children[i]
+		}
+	}
+	return nil
+}
+
+// summarize : Helper function to sort a directory and fill in its size and
+// its progress weighted by the size of each file
+func summarize(dir *FileNode) {
+	var size int64
+	var done float64
+	for i := range dir.Children {
+		child := &dir.Children[i]
+		if child.IsDir {
+			summarize(child)
+		}
+		size += child.Size
+		done += float64(child.Size) * child.Progress
+	}
+	dir.Size = size
+	dir.Progress = 0
+	if size > 0 {
+		dir.Progress = done / float64(size)
+	}
+	sort.SliceStable(dir.Children, func(i, j int) bool {
+		if dir.Children[i].IsDir != dir.Children[j].IsDir {
+			return dir.Children[i].IsDir
+		}
+		return dir.Children[i].Name < dir.Children[j].Name
+	})
+}
@@ -0,0 +1,85 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestBuildFileTree(t *testing.T) {
+	tree := buildFileTree([]fileEntry{
+		{Path: "show/s01/e02.mkv", Size: 300, Progress: 0},
+		{Path: "show/s01/e01.mkv", Size: 100, Progress: 100},
+		{Path: "show/readme.txt", Size: 0, Progress: 100},
+		{Path: "/cover.jpg", Size: 100, Progress: 50},
+	})
+	want := FileNode{IsDir: true, Size: 500, Progress: 30, Children: []FileNode{
+		{Name: "show", Path: "show", IsDir: true, Size: 400, Progress: 25, Children: []FileNode{
+			{Name: "s01", Path: "show/s01", IsDir: true, Size: 400, Progress: 25, Children: []FileNode{
+				{Name: "e01.mkv", Path: "show/s01/e01.mkv", Size: 100, Progress: 100},
+				{Name: "e02.mkv", Path: "show/s01/e02.mkv", Size: 300},
+			}},
+			{Name: "readme.txt", Path: "show/readme.txt", Progress: 100},
+		}},
+		{Name: "cover.jpg", Path: "cover.jpg", Size: 100, Progress: 50},
+	}}
+	if !reflect.DeepEqual(tree, want) {
+		t.Errorf("got %+v\nwant %+v", tree, want)
+	}
+}
+
+func TestBuildFileTreeEmpty(t *testing.T) {
+	tree := buildFileTree(nil)
+	if !tree.IsDir || tree.Size != 0 || len(tree.Children) != 0 {
+		t.Errorf("got %+v, want an empty root directory", tree)
+	}
+}
+
+func TestGetDownloadFileTree(t *testing.T) {
+	responses := map[string]string{
+		"flat":   `[{"path": "iso/debian.iso", "size": 10, "progress": 100}]`,
+		"nested": `{"is_dir": true, "size": 10, "progress": 100, "children": [{"name": "iso", "path": "iso", "is_dir": true, "size": 10, "progress": 100, "children": [{"name": "debian.iso", "path": "iso/debian.iso", "size": 10, "progress": 100}]}]}`,
+	}
+	for name, response := range responses {
+		t.Run(name, func(t *testing.T) {
+			b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/download/9/files" {
+					t.Errorf("unexpected path %s", r.URL.Path)
+				}
+				w.Write([]byte(response))
+			}))
+			tree, err := b.Downloads.GetDownloadFileTree(context.Background(), 9)
+			if err != nil {
+				t.Fatalf("GetDownloadFileTree: %v", err)
+			}
+			if len(tree.Children) != 1 || len(tree.Children[0].Children) != 1 {
+				t.Fatalf("got %+v", tree)
+			}
+			if file := tree.Children[0].Children[0]; file.Path != "iso/debian.iso" || file.Size != 10 || file.IsDir {
+				t.Errorf("file = %+v", file)
+			}
+		})
+	}
+}
+
+func TestGetDownloadFileTreeValidation(t *testing.T) {
+	b := newOfflineClient()
+	if _, err := b.Downloads.GetDownloadFileTree(context.Background(), 0); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("got %v, want ErrInvalidParams", err)
+	}
+}
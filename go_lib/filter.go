@@ -0,0 +1,342 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DownloadFilter : Builder for the query of a filtered download listing.
+// Each method returns a new filter, so a base filter can be shared:
+//
+//	filter := DownloadFilter{}.Status(StatusCompleted).User("rand")
+//
+// Invalid input is recorded and reported when the filter is used.
+type DownloadFilter struct {
+	statuses []DownloadStatus
+	user     string
+	category string
+	minSize  int64
+	since    time.Time
+	until    time.Time
+	limit    int
+	offset   int
+	err      error
+}
+
+// Status : Function to only match downloads in one of the given states
+func (f DownloadFilter) Status(statuses ...DownloadStatus) DownloadFilter {
+	for _, status := range statuses {
+		if !downloadStatuses[status] {
+			return f.fail(invalidParam("status", "has unknown value "+strconv.Quote(string(status))))
+		}
+	}
+	f.statuses = append(append([]DownloadStatus(nil), f.statuses...), statuses...)
+	return f
+}
+
+// User : Function to only match the downloads of one user
+func (f DownloadFilter) User(userName string) DownloadFilter {
+	if strings.TrimSpace(userName) == "" {
+		return f.fail(invalidParam("user", "must not be empty"))
+	}
+	f.user = userName
+	return f
+}
+
+// Category : Function to only match downloads in one category
+func (f DownloadFilter) Category(category string) DownloadFilter {
+	if strings.TrimSpace(category) == "" {
+		return f.fail(invalidParam("category", "must not be empty"))
+	}
+	f.category = category
+	return f
+}
+
+// MinSize : Function to only match downloads of at least bytes bytes
+func (f DownloadFilter) MinSize(bytes int64) DownloadFilter {
+	if bytes < 0 {
+		return f.fail(invalidParam("minSize", "must not be negative"))
+	}
+	f.minSize = bytes
+	return f
+}
+
+// Since : Function to only match downloads added from t on
+func (f DownloadFilter) Since(t time.Time) DownloadFilter {
+	if t.IsZero() {
+		return f.fail(invalidParam("since", "must be set"))
+	}
+	f.since = t
+	return f
+}
+
+// Until : Function to only match downloads added before t. Combined with
+// Since, t must be after the start of the range.
+func (f DownloadFilter) Until(t time.Time) DownloadFilter {
+	if t.IsZero() {
+		return f.fail(invalidParam("until", "must be set"))
+	}
+	f.until = t
+	return f
+}
+
+// Limit : Function to cap the number of downloads returned
+func (f DownloadFilter) Limit(n int) DownloadFilter {
+	if n <= 0 {
+		return f.fail(invalidParam("limit", "must be positive"))
+	}
+	f.limit = n
+	return f
+}
+
+// Offset : Function to skip the first n matching downloads
+func (f DownloadFilter) Offset(n int) DownloadFilter {
+	if n < 0 {
+		return f.fail(invalidParam("offset", "must not be negative"))
+	}
+	f.offset = n
+	return f
+}
+
+func (f DownloadFilter) fail(err error) DownloadFilter {
+	if f.err == nil {
+		f.err = err
+	}
+	return f
+}
+
+// Query : Function to compile the filter into query parameters
+func (f DownloadFilter) Query() (url.Values, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if !f.since.IsZero() && !f.until.IsZero() && !f.until.After(f.since) {
+		return nil, invalidParam("until", "must be after since")
+	}
+	query := url.Values{}
+	if len(f.statuses) > 0 {
+		statuses := make([]string, len(f.statuses))
+		for i, status := range f.statuses {
+			statuses[i] = string(status)
+		}
+		query.Set("status", strings.Join(statuses, ","))
+	}
+	if f.user != "" {
+		query.Set("user_name", f.user)
+	}
+	if f.category != "" {
+		query.Set("category", f.category)
+	}
+	if f.minSize > 0 {
+		query.Set("min_size", strconv.FormatInt(f.minSize, 10))
+	}
+	setPaging(query, f.since, f.limit, f.offset)
+	if !f.until.IsZero() {
+		query.Set("until", f.until.UTC().Format(time.RFC3339))
+	}
+	return query, nil
+}
+
+// setPaging : Helper function to add the parameters shared by the filtered
+// listings to query, leaving out those at their zero value
+func setPaging(query url.Values, since time.Time, limit int, offset int) {
+	if !since.IsZero() {
+		query.Set("since", since.UTC().Format(time.RFC3339))
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	if offset > 0 {
+		query.Set("offset", strconv.Itoa(offset))
+	}
+}
+
+// withQuery : Helper function adding query to an endpoint, after the query
+// it may already have
+func withQuery(endpoint string, query url.Values) string {
+	if len(query) == 0 {
+		return endpoint
+	}
+	if strings.Contains(endpoint, "?") {
+		return endpoint + "&" + query.Encode()
+	}
+	return endpoint + "?" + query.Encode()
+}
+
+// List : Function to get the downloads matching filter, e.g. the completed
+// downloads of one user added in the last week:
+//
+//	since := time.Now().AddDate(0, 0, -7)
+//	downloads, err := b.Downloads.List(ctx, DownloadFilter{}.Status(StatusCompleted).User("rand").Since(since))
+//
+// The zero filter lists every download.
+func (s *DownloadsService) List(ctx context.Context, filter DownloadFilter) ([]Download, error) {
+	query, err := filter.Query()
+	if err != nil {
+		return nil, err
+	}
+	var downloads []Download
+	if err := s.client.do(ctx, "GET", withQuery("/api/downloads", query), nil, &downloads); err != nil {
+		return nil, err
+	}
+	return downloads, nil
+}
+
+// GetDownloadsFiltered : Function to get the downloads matching filter, see
+// List
+func (s *DownloadsService) GetDownloadsFiltered(ctx context.Context, filter DownloadFilter) ([]Download, error) {
+	return s.List(ctx, filter)
+}
+
+// UserFilter : Builder for the query of a filtered user listing, used like
+// DownloadFilter:
+//
+//	filter := UserFilter{}.AuthLevel(AuthLevelRegular).Verified(false).Limit(50)
+type UserFilter struct {
+	authLevel *AuthLevel
+	verified  *bool
+	since     time.Time
+	limit     int
+	offset    int
+	err       error
+}
+
+// AuthLevel : Function to only match the users with the given auth level
+func (f UserFilter) AuthLevel(level AuthLevel) UserFilter {
+	if !level.valid() {
+		return f.fail(invalidParam("authLevel", "has unknown value "+strconv.Itoa(int(level))))
+	}
+	f.authLevel = &level
+	return f
+}
+
+// Verified : Function to only match the users who did, or didn't, confirm
+// their email address
+func (f UserFilter) Verified(verified bool) UserFilter {
+	f.verified = &verified
+	return f
+}
+
+// Since : Function to only match users who signed up from t on
+func (f UserFilter) Since(t time.Time) UserFilter {
+	if t.IsZero() {
+		return f.fail(invalidParam("since", "must be set"))
+	}
+	f.since = t
+	return f
+}
+
+// Limit : Function to cap the number of users returned
+func (f UserFilter) Limit(n int) UserFilter {
+	if n <= 0 {
+		return f.fail(invalidParam("limit", "must be positive"))
+	}
+	f.limit = n
+	return f
+}
+
+// Offset : Function to skip the first n matching users
+func (f UserFilter) Offset(n int) UserFilter {
+	if n < 0 {
+		return f.fail(invalidParam("offset", "must not be negative"))
+	}
+	f.offset = n
+	return f
+}
+
+func (f UserFilter) fail(err error) UserFilter {
+	if f.err == nil {
+		f.err = err
+	}
+	return f
+}
+
+// Query : Function to compile the filter into query parameters
+func (f UserFilter) Query() (url.Values, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	query := url.Values{}
+	if f.authLevel != nil {
+		query.Set("auth_level", strconv.Itoa(int(*f.authLevel)))
+	}
+	if f.verified != nil {
+		query.Set("verified", strconv.FormatBool(*f.verified))
+	}
+	setPaging(query, f.since, f.limit, f.offset)
+	return query, nil
+}
+
+// GetUsersFiltered : Function to get the users matching filter
+func (s *UsersService) GetUsersFiltered(ctx context.Context, filter UserFilter) ([]User, error) {
+	query, err := filter.Query()
+	if err != nil {
+		return nil, err
+	}
+	var users []User
+	if err := s.client.do(ctx, "GET", withQuery("/api/user", query), nil, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetQueue : Function to get the downloads waiting to start
+func (s *DownloadsService) GetQueue(ctx context.Context) ([]Download, error) {
+	return s.client.getByStatus(ctx, DownloadFilter{}, StatusQueued)
+}
+
+// GetActive : Function to get the downloads currently in progress
+func (s *DownloadsService) GetActive(ctx context.Context) ([]Download, error) {
+	return s.client.getByStatus(ctx, DownloadFilter{}, StatusDownloading)
+}
+
+// GetHistory : Function to get up to limit finished downloads, completed
+// or failed
+func (s *DownloadsService) GetHistory(ctx context.Context, limit int) ([]Download, error) {
+	if limit <= 0 {
+		return nil, invalidParam("limit", "must be positive")
+	}
+	downloads, err := s.client.getByStatus(ctx, DownloadFilter{}.Limit(limit), StatusCompleted, StatusFailed)
+	if err != nil {
+		return nil, err
+	}
+	if len(downloads) > limit {
+		downloads = downloads[:limit]
+	}
+	return downloads, nil
+}
+
+// getByStatus : Function to get the downloads in one of statuses. Servers
+// ignoring the status filter are handled by filtering the result again.
+func (b *Client) getByStatus(ctx context.Context, filter DownloadFilter, statuses ...DownloadStatus) ([]Download, error) {
+	downloads, err := b.Downloads.GetDownloadsFiltered(ctx, filter.Status(statuses...))
+	if err != nil {
+		return nil, err
+	}
+	matched := downloads[:0]
+	for _, download := range downloads {
+		for _, status := range statuses {
+			if download.Status == status {
+				matched = append(matched, download)
+				break
+			}
+		}
+	}
+	return matched, nil
+}
@@ -0,0 +1,253 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDownloadFilterQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter DownloadFilter
+		want   string
+	}{
+		{"empty", DownloadFilter{}, ""},
+		{"status", DownloadFilter{}.Status(StatusCompleted), "status=completed"},
+		{"statuses", DownloadFilter{}.Status(StatusQueued).Status(StatusDownloading), "status=queued%2Cdownloading"},
+		{
+			"combined",
+			DownloadFilter{}.Status(StatusCompleted, StatusFailed).User("rand").Category("videos").MinSize(1 << 20),
+			"category=videos&min_size=1048576&status=completed%2Cfailed&user_name=rand",
+		},
+		{"escaped", DownloadFilter{}.User("a&b=c"), "user_name=a%26b%3Dc"},
+		{"zero min size", DownloadFilter{}.MinSize(0), ""},
+		{"limit", DownloadFilter{}.Status(StatusFailed).Limit(20), "limit=20&status=failed"},
+		{
+			"paged since",
+			DownloadFilter{}.Since(time.Date(2026, 3, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))).Limit(50).Offset(100),
+			"limit=50&offset=100&since=2026-03-01T11%3A00%3A00Z",
+		},
+		{
+			"date range",
+			DownloadFilter{}.Since(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)).Until(time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)),
+			"since=2026-03-01T00%3A00%3A00Z&until=2026-04-01T00%3A00%3A00Z",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := tt.filter.Query()
+			if err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+			if got := query.Encode(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloadFilterValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter DownloadFilter
+	}{
+		{"unknown status", DownloadFilter{}.Status("exploded")},
+		{"empty user", DownloadFilter{}.User(" ")},
+		{"empty category", DownloadFilter{}.Category("")},
+		{"negative size", DownloadFilter{}.User("rand").MinSize(-1)},
+		{"zero limit", DownloadFilter{}.Limit(0)},
+		{"zero since", DownloadFilter{}.Since(time.Time{})},
+		{"negative offset", DownloadFilter{}.Offset(-1)},
+		{"zero until", DownloadFilter{}.Until(time.Time{})},
+		{"until before since", DownloadFilter{}.Since(time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)).Until(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.filter.Query(); !errors.Is(err, ErrInvalidParams) {
+				t.Errorf("got %v, want ErrInvalidParams", err)
+			}
+		})
+	}
+}
+
+func TestDownloadFilterIsImmutable(t *testing.T) {
+	base := DownloadFilter{}.Status(StatusQueued)
+	one := base.Status(StatusCompleted)
+	two := base.Status(StatusFailed)
+	for filter, want := range map[*DownloadFilter]string{&base: "status=queued", &one: "status=queued%2Ccompleted", &two: "status=queued%2Cfailed"} {
+		query, _ := filter.Query()
+		if got := query.Encode(); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
+
+func TestGetDownloadsFiltered(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/downloads" || r.URL.RawQuery != "category=videos&user_name=rand" {
+			t.Errorf("unexpected request %s?%s", r.URL.Path, r.URL.RawQuery)
+		}
+		w.Write([]byte(`[{"id": 4, "user_name": "rand", "category": "videos"}]`))
+	}))
+	downloads, err := b.Downloads.GetDownloadsFiltered(context.Background(), DownloadFilter{}.User("rand").Category("videos"))
+	if err != nil {
+		t.Fatalf("GetDownloadsFiltered: %v", err)
+	}
+	if len(downloads) != 1 || downloads[0].Category != "videos" {
+		t.Errorf("got %+v", downloads)
+	}
+}
+
+func TestList(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/downloads" || r.URL.RawQuery != "since=2026-03-01T00%3A00%3A00Z&status=completed&user_name=rand" {
+			t.Errorf("unexpected request %s?%s", r.URL.Path, r.URL.RawQuery)
+		}
+		w.Write([]byte(`[{"id": 4, "link": "http://example.com/a.iso", "status": "completed", "progress": 100,
+			"size": 2048, "added_time": "2026-03-02T10:00:00Z", "completed_time": "2026-03-02T11:00:00Z"}]`))
+	}))
+	filter := DownloadFilter{}.Status(StatusCompleted).User("rand").Since(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))
+	downloads, err := b.Downloads.List(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(downloads) != 1 {
+		t.Fatalf("got %d downloads, want 1", len(downloads))
+	}
+	got := downloads[0]
+	if got.ID != 4 || got.Status != StatusCompleted || got.Progress != 100 || got.Size != 2048 ||
+		!got.CompletedAt.Equal(time.Date(2026, 3, 2, 11, 0, 0, 0, time.UTC)) {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestGetDownloadsFilteredInvalid(t *testing.T) {
+	b := newOfflineClient()
+	if _, err := b.Downloads.GetDownloadsFiltered(context.Background(), DownloadFilter{}.MinSize(-5)); !errors.Is(err, ErrInvalidParams) {
+		t.Fatalf("got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestUserFilterQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter UserFilter
+		want   string
+	}{
+		{"empty", UserFilter{}, ""},
+		{"admins", UserFilter{}.AuthLevel(AuthLevelAdmin), "auth_level=0"},
+		{"unverified", UserFilter{}.Verified(false), "verified=false"},
+		{
+			"combined",
+			UserFilter{}.AuthLevel(AuthLevelRegular).Verified(true).Since(time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)).Limit(10),
+			"auth_level=1&limit=10&since=2026-01-02T00%3A00%3A00Z&verified=true",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := tt.filter.Query()
+			if err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+			if got := query.Encode(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+	for _, filter := range []UserFilter{UserFilter{}.AuthLevel(7), UserFilter{}.Limit(-1), UserFilter{}.Verified(true).Offset(-2)} {
+		if _, err := filter.Query(); !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("got %v, want ErrInvalidParams", err)
+		}
+	}
+}
+
+func TestGetUsersFiltered(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/user" || r.URL.RawQuery != "limit=2&verified=false" {
+			t.Errorf("unexpected request %s?%s", r.URL.Path, r.URL.RawQuery)
+		}
+		w.Write([]byte(`[{"user_name": "rand"}, {"user_name": "mat"}]`))
+	}))
+	users, err := b.Users.GetUsersFiltered(context.Background(), UserFilter{}.Verified(false).Limit(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 || users[1].UserName != "mat" {
+		t.Errorf("got %+v", users)
+	}
+}
+
+func TestDownloadTabs(t *testing.T) {
+	var queries []string
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/downloads" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		queries = append(queries, r.URL.RawQuery)
+		// A server ignoring the filter: every download comes back
+		w.Write([]byte(`[
+			{"id": 1, "status": "queued"},
+			{"id": 2, "status": "downloading", "progress": 40},
+			{"id": 3, "status": "completed", "size": 1024},
+			{"id": 4, "status": "failed"},
+			{"id": 5, "status": "completed"}
+		]`))
+	}))
+	ctx := context.Background()
+	tests := []struct {
+		name      string
+		get       func() ([]Download, error)
+		wantQuery string
+		wantIDs   []int
+	}{
+		{"queue", func() ([]Download, error) { return b.Downloads.GetQueue(ctx) }, "status=queued", []int{1}},
+		{"active", func() ([]Download, error) { return b.Downloads.GetActive(ctx) }, "status=downloading", []int{2}},
+		{"history", func() ([]Download, error) { return b.Downloads.GetHistory(ctx, 2) }, "limit=2&status=completed%2Cfailed", []int{3, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries = nil
+			downloads, err := tt.get()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(queries) != 1 || queries[0] != tt.wantQuery {
+				t.Errorf("queries = %q, want [%q]", queries, tt.wantQuery)
+			}
+			var ids []int
+			for _, download := range downloads {
+				ids = append(ids, download.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.wantIDs) {
+				t.Errorf("ids = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+	if downloads, _ := b.Downloads.GetActive(ctx); downloads[0].Progress != 40 {
+		t.Errorf("Progress = %v, want 40", downloads[0].Progress)
+	}
+}
+
+func TestGetHistoryValidation(t *testing.T) {
+	b := newOfflineClient()
+	if _, err := b.Downloads.GetHistory(context.Background(), 0); !errors.Is(err, ErrInvalidParams) {
+		t.Fatalf("got %v, want ErrInvalidParams", err)
+	}
+}
@@ -0,0 +1,132 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// errUserExists : The imported user name is already taken
+var errUserExists = errors.New("user exists")
+
+// NewUser : Account to create with ImportUsers, a regular user unless
+// Admin is set
+type NewUser struct {
+	UserName string
+	Password string
+	Email    string
+	Admin    bool
+}
+
+// ImportStatus : What ImportUsers did with one user
+type ImportStatus string
+
+// Outcomes of importing a user
+const (
+	ImportCreated ImportStatus = "created"
+	ImportSkipped ImportStatus = "skipped"
+	ImportFailed  ImportStatus = "failed"
+)
+
+// ImportOutcome : Result of importing one user. Err is set for failures.
+type ImportOutcome struct {
+	UserName string
+	Status   ImportStatus
+	Err      error
+}
+
+// ImportOptions : Settings of ImportUsers
+type ImportOptions struct {
+	// StopOnError ends the import at the first failure instead of moving on
+	StopOnError bool
+	// Progress, when set, is called after each user with the number of
+	// users handled so far
+	Progress func(done int, total int, outcome ImportOutcome)
+}
+
+// ImportResult : Outcomes of ImportUsers, in the order of the input, with
+// a count of each status
+type ImportResult struct {
+	Outcomes []ImportOutcome
+	Created  int
+	Skipped  int
+	Failed   int
+}
+
+// ImportUsers : Function for admins to create many users at once. Users
+// whose name is already taken are skipped, so an import which failed part
+// way can simply be run again with the same list. Invalid users fail on
+// their own without stopping the import, unless opts.StopOnError is set.
+// The error is only set for an empty list, a cancelled ctx or, with
+// StopOnError, the first failure; the result then covers the users handled.
+func (s *AdminService) ImportUsers(ctx context.Context, users []NewUser, opts ImportOptions) (ImportResult, error) {
+	var result ImportResult
+	if len(users) == 0 {
+		return result, invalidParam("users", "must not be empty")
+	}
+	for _, user := range users {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		outcome := ImportOutcome{UserName: user.UserName, Status: ImportCreated}
+		if err := s.client.importUser(ctx, user); err == errUserExists {
+			outcome.Status = ImportSkipped
+		} else if err != nil {
+			outcome.Status = ImportFailed
+			outcome.Err = err
+		}
+		if outcome.Err != nil && ctx.Err() != nil {
+			// The user wasn't handled, only interrupted
+			return result, ctx.Err()
+		}
+
+		result.Outcomes = append(result.Outcomes, outcome)
+		switch outcome.Status {
+		case ImportCreated:
+			result.Created++
+		case ImportSkipped:
+			result.Skipped++
+		case ImportFailed:
+			result.Failed++
+		}
+		if opts.Progress != nil {
+			opts.Progress(len(result.Outcomes), len(users), outcome)
+		}
+		if outcome.Err != nil && opts.StopOnError {
+			return result, fmt.Errorf("importing %s: %w", user.UserName, outcome.Err)
+		}
+	}
+	return result, nil
+}
+
+// importUser : Function to create one user of an import unless it exists
+func (b *Client) importUser(ctx context.Context, user NewUser) error {
+	input := CreateUserInput{UserName: user.UserName, Password: user.Password, Email: user.Email, AuthLevel: AuthLevelRegular}
+	if user.Admin {
+		input.AuthLevel = AuthLevelAdmin
+	}
+	if errs := input.validate(b.rules); len(errs) > 0 {
+		return errs
+	}
+	available, err := b.Users.IsUsernameAvailable(ctx, user.UserName)
+	if err != nil {
+		return err
+	}
+	if !available {
+		return errUserExists
+	}
+	return b.do(ctx, "POST", "/api/user", input, nil)
+}
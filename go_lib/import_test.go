@@ -0,0 +1,184 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// createdUser : User as received by importServer
+type createdUser struct {
+	UserName  string    `json:"user_name"`
+	Email     string    `json:"email"`
+	AuthLevel AuthLevel `json:"auth_level"`
+}
+
+// importServer : Handler keeping created users in memory. Creating a user
+// named in failing fails once with a server error.
+func importServer(t *testing.T, failing ...string) (http.Handler, map[string]createdUser) {
+	var mu sync.Mutex
+	users := map[string]createdUser{}
+	failed := map[string]bool{}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == "HEAD" && strings.HasPrefix(r.URL.Path, "/api/user/"):
+			if _, ok := users[strings.TrimPrefix(r.URL.Path, "/api/user/")]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case r.Method == "POST" && r.URL.Path == "/api/user":
+			user := createdUser{AuthLevel: AuthLevelUnknown}
+			json.NewDecoder(r.Body).Decode(&user)
+			for _, name := range failing {
+				if name == user.UserName && !failed[name] {
+					failed[name] = true
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+			}
+			users[user.UserName] = user
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}), users
+}
+
+func importStatuses(result ImportResult) []ImportStatus {
+	var statuses []ImportStatus
+	for _, outcome := range result.Outcomes {
+		statuses = append(statuses, outcome.Status)
+	}
+	return statuses
+}
+
+func TestImportUsersResumes(t *testing.T) {
+	handler, created := importServer(t, "carol")
+	b := newTestClient(t, handler)
+	users := []NewUser{
+		{UserName: "alice", Password: "password1", Email: "alice@example.com"},
+		{UserName: "bob", Password: "password2", Email: "bob@example.com", Admin: true},
+		{UserName: "carol", Password: "password3", Email: "carol@example.com"},
+		{UserName: "dave", Password: "password4", Email: "dave@"},
+	}
+
+	var progress []int
+	first, err := b.Admin.ImportUsers(context.Background(), users, ImportOptions{
+		Progress: func(done, total int, outcome ImportOutcome) {
+			if total != 4 {
+				t.Errorf("total = %d, want 4", total)
+			}
+			progress = append(progress, done)
+		},
+	})
+	if err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	want := []ImportStatus{ImportCreated, ImportCreated, ImportFailed, ImportFailed}
+	if got := importStatuses(first); !reflect.DeepEqual(got, want) {
+		t.Errorf("first run = %v, want %v", got, want)
+	}
+	if first.Created != 2 || first.Failed != 2 || first.Skipped != 0 {
+		t.Errorf("first run counts = %+v", first)
+	}
+	if !reflect.DeepEqual(progress, []int{1, 2, 3, 4}) {
+		t.Errorf("progress = %v", progress)
+	}
+	if !errors.Is(first.Outcomes[3].Err, ErrInvalidParams) {
+		t.Errorf("dave: got %v, want ErrInvalidParams", first.Outcomes[3].Err)
+	}
+
+	users[3].Email = "dave@example.com"
+	second, err := b.Admin.ImportUsers(context.Background(), users, ImportOptions{})
+	if err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	want = []ImportStatus{ImportSkipped, ImportSkipped, ImportCreated, ImportCreated}
+	if got := importStatuses(second); !reflect.DeepEqual(got, want) {
+		t.Errorf("second run = %v, want %v", got, want)
+	}
+	if len(created) != 4 {
+		t.Fatalf("created = %+v", created)
+	}
+	for name, user := range created {
+		want := AuthLevelRegular
+		if name == "bob" {
+			want = AuthLevelAdmin
+		}
+		if user.AuthLevel != want {
+			t.Errorf("%s: auth level = %d, want %d", name, user.AuthLevel, want)
+		}
+	}
+}
+
+func TestImportUsersStopOnError(t *testing.T) {
+	handler, created := importServer(t, "alice")
+	b := newTestClient(t, handler)
+	users := []NewUser{
+		{UserName: "alice", Password: "password1", Email: "alice@example.com"},
+		{UserName: "bob", Password: "password2", Email: "bob@example.com"},
+	}
+	result, err := b.Admin.ImportUsers(context.Background(), users, ImportOptions{StopOnError: true})
+	if err == nil || len(result.Outcomes) != 1 || len(created) != 0 {
+		t.Errorf("got %+v, %v; want a stop after alice", result, err)
+	}
+	if _, err := b.Admin.ImportUsers(context.Background(), nil, ImportOptions{}); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("empty list: got %v, want ErrInvalidParams", err)
+	}
+}
+
+// cancelAfterTransport : Transport cancelling a context once the response
+// to the first POST was fully received
+type cancelAfterTransport struct {
+	cancel context.CancelFunc
+}
+
+func (c cancelAfterTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	response, err := http.DefaultTransport.RoundTrip(r)
+	if err != nil || r.Method != "POST" {
+		return response, err
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	c.cancel()
+	return response, err
+}
+
+func TestImportUsersCancelledAfterCreating(t *testing.T) {
+	handler, created := importServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := newTestClient(t, handler, WithHTTPClient(&http.Client{Transport: cancelAfterTransport{cancel}}))
+	users := []NewUser{
+		{UserName: "alice", Password: "password1", Email: "alice@example.com"},
+		{UserName: "bob", Password: "password2", Email: "bob@example.com"},
+	}
+	result, err := b.Admin.ImportUsers(ctx, users, ImportOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	if len(created) != 1 || len(result.Outcomes) != 1 || result.Outcomes[0].Status != ImportCreated || result.Created != 1 {
+		t.Errorf("got %+v with %d users created, want alice reported as created", result, len(created))
+	}
+}
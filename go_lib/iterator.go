@@ -0,0 +1,192 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// pager : Offset bookkeeping shared by the iterators
+type pager struct {
+	endpoint string
+	// size is the number of records per page, exportPageSize if 0
+	size   int
+	offset int
+	done   bool
+}
+
+// fetch : Function to get the next page of the listing into page, a pointer
+// to a slice whose length is reported by length. A failed page is fetched
+// again by the next call.
+func (p *pager) fetch(ctx context.Context, b *Client, page interface{}, length func() int) error {
+	size := p.size
+	if size <= 0 {
+		size = exportPageSize
+	}
+	if err := b.do(ctx, "GET", pageEndpoint(p.endpoint, size, p.offset), nil, page); err != nil {
+		return err
+	}
+	n := length()
+	p.offset += n
+	if n < size {
+		p.done = true
+	}
+	return nil
+}
+
+// DownloadIterator : Iterator over every download on the server, fetching
+// pages as needed. Create one with DownloadsService.Iterator.
+type DownloadIterator struct {
+	b     *Client
+	pager pager
+	page  []Download
+}
+
+// Iterator : Function to iterate over every download:
+//
+//	it := b.Downloads.Iterator()
+//	for {
+//		download, ok, err := it.Next(ctx)
+//		if err != nil || !ok {
+//			break
+//		}
+//		...
+//	}
+func (s *DownloadsService) Iterator() *DownloadIterator {
+	return &DownloadIterator{b: s.client, pager: pager{endpoint: "/api/downloads"}}
+}
+
+// Next : Function to get the next download. ok is false once every download
+// was returned. After an error Next may be called again to retry the page.
+func (it *DownloadIterator) Next(ctx context.Context) (download Download, ok bool, err error) {
+	for len(it.page) == 0 {
+		if it.pager.done {
+			return Download{}, false, nil
+		}
+		it.page = nil
+		if err := it.pager.fetch(ctx, it.b, &it.page, func() int { return len(it.page) }); err != nil {
+			return Download{}, false, err
+		}
+	}
+	download, it.page = it.page[0], it.page[1:]
+	return download, true, nil
+}
+
+// UserIterator : Iterator over every user account, fetching pages as
+// needed. Create one with UsersService.Iterator.
+type UserIterator struct {
+	b     *Client
+	pager pager
+	page  []User
+}
+
+// Iterator : Function to iterate over every user, used like
+// DownloadsService.Iterator
+func (s *UsersService) Iterator() *UserIterator {
+	return &UserIterator{b: s.client, pager: pager{endpoint: "/api/user"}}
+}
+
+// Next : Function to get the next user, see DownloadIterator.Next
+func (it *UserIterator) Next(ctx context.Context) (user User, ok bool, err error) {
+	for len(it.page) == 0 {
+		if it.pager.done {
+			return User{}, false, nil
+		}
+		it.page = nil
+		if err := it.pager.fetch(ctx, it.b, &it.page, func() int { return len(it.page) }); err != nil {
+			return User{}, false, err
+		}
+	}
+	user, it.page = it.page[0], it.page[1:]
+	return user, true, nil
+}
+
+// ListOptions : Page of a listing to get. The zero value gets the whole
+// listing in one response.
+type ListOptions struct {
+	// Limit is the maximum number of records, 0 for no limit
+	Limit  int
+	Offset int
+}
+
+// endpoint : Function to add the options to a listing endpoint
+func (o ListOptions) endpoint(endpoint string) (string, error) {
+	if o.Limit < 0 {
+		return "", invalidParam("limit", "must not be negative")
+	}
+	if o.Offset < 0 {
+		return "", invalidParam("offset", "must not be negative")
+	}
+	query := url.Values{}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Offset > 0 {
+		query.Set("offset", strconv.Itoa(o.Offset))
+	}
+	return withQuery(endpoint, query), nil
+}
+
+// Pager : Walks a listing page by page, for handling large listings in
+// batches without holding all of them in memory
+type Pager[T any] struct {
+	b     *Client
+	pager pager
+	// err, when set, is returned by Next instead of fetching pages
+	err error
+}
+
+// newPager : Helper function to create a pager over endpoint, with pages of
+// pageSize records, 100 if pageSize isn't positive
+func newPager[T any](b *Client, endpoint string, pageSize int) *Pager[T] {
+	return &Pager[T]{b: b, pager: pager{endpoint: endpoint, size: pageSize}}
+}
+
+// Next : Function to get the next page. ok is false once the listing is
+// exhausted, the last page possibly being shorter than the others. After an
+// error Next may be called again to retry the page.
+func (p *Pager[T]) Next(ctx context.Context) (page []T, ok bool, err error) {
+	if p.err != nil {
+		return nil, false, p.err
+	}
+	if p.pager.done {
+		return nil, false, nil
+	}
+	if err := p.pager.fetch(ctx, p.b, &page, func() int { return len(page) }); err != nil {
+		return nil, false, err
+	}
+	if len(page) == 0 {
+		return nil, false, nil
+	}
+	return page, true, nil
+}
+
+// Pages : Function to walk the user accounts in pages of pageSize
+func (s *UsersService) Pages(pageSize int) *Pager[User] {
+	return newPager[User](s.client, "/api/user", pageSize)
+}
+
+// SignupRequestPages : Function to walk the signup requests in pages of
+// pageSize
+func (s *AdminService) SignupRequestPages(pageSize int) *Pager[SignupRequest] {
+	return newPager[SignupRequest](s.client, "/api/user/requests", pageSize)
+}
+
+// HistoryPages : Function to walk the download history of the logged in
+// user in pages of pageSize
+func (s *DownloadsService) HistoryPages(pageSize int) *Pager[DownloadRecord] {
+	return newPager[DownloadRecord](s.client, "/api/user/downloads", pageSize)
+}
@@ -0,0 +1,160 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDownloadIterator(t *testing.T) {
+	total := 2*exportPageSize + 3
+	var requests int32
+	failed := false
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		// The second page fails once
+		if offset == exportPageSize && !failed {
+			failed = true
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		downloads := []Download{}
+		for i := offset; i < total && i < offset+exportPageSize; i++ {
+			downloads = append(downloads, Download{ID: i + 1})
+		}
+		json.NewEncoder(w).Encode(downloads)
+	}))
+
+	it := b.Downloads.Iterator()
+	seen := map[int]bool{}
+	errs := 0
+	for {
+		download, ok, err := it.Next(context.Background())
+		if err != nil {
+			if errs++; errs > 1 {
+				t.Fatalf("Next: %v", err)
+			}
+			continue
+		}
+		if !ok {
+			break
+		}
+		if seen[download.ID] {
+			t.Errorf("download %d returned twice", download.ID)
+		}
+		seen[download.ID] = true
+	}
+	if len(seen) != total || errs != 1 {
+		t.Errorf("got %d downloads and %d errors, want %d and 1", len(seen), errs, total)
+	}
+	if _, ok, err := it.Next(context.Background()); ok || err != nil {
+		t.Errorf("Next after the end = %v, %v", ok, err)
+	}
+	if requests != 4 {
+		t.Errorf("requests = %d, want 3 pages and a retry", requests)
+	}
+}
+
+func TestUserIterator(t *testing.T) {
+	for _, total := range []int{0, exportPageSize, exportPageSize + 1} {
+		var requests int32
+		b := newTestClient(t, pagedUsers(t, total, &requests))
+		it := b.Users.Iterator()
+		var names []string
+		for {
+			user, ok, err := it.Next(context.Background())
+			if err != nil {
+				t.Fatalf("Next: %v", err)
+			}
+			if !ok {
+				break
+			}
+			names = append(names, user.UserName)
+		}
+		if len(names) != total {
+			t.Errorf("%d users: got %d", total, len(names))
+		}
+		for i, name := range names {
+			if name != "user"+strconv.Itoa(i) {
+				t.Errorf("%d users: user %d = %s", total, i, name)
+				break
+			}
+		}
+	}
+}
+
+func TestPager(t *testing.T) {
+	var requests int32
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.URL.Path != "/api/user/requests" || r.URL.Query().Get("limit") != "2" {
+			t.Errorf("unexpected request %s?%s", r.URL.Path, r.URL.RawQuery)
+		}
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		var page []SignupRequest
+		for i := offset; i < 5 && i < offset+2; i++ {
+			page = append(page, SignupRequest{UserName: "user" + strconv.Itoa(i)})
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	pager := b.Admin.SignupRequestPages(2)
+	var sizes []int
+	for {
+		page, ok, err := pager.Next(context.Background())
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		if !ok {
+			break
+		}
+		sizes = append(sizes, len(page))
+	}
+	if !reflect.DeepEqual(sizes, []int{2, 2, 1}) {
+		t.Errorf("page sizes = %v, want [2 2 1]", sizes)
+	}
+	if _, ok, _ := pager.Next(context.Background()); ok {
+		t.Error("Next after the last page returned a page")
+	}
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("%d requests, want 3", n)
+	}
+}
+
+func TestListOptions(t *testing.T) {
+	var queries []string
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		w.Write([]byte("[]"))
+	}))
+	ctx := context.Background()
+	for _, opts := range []ListOptions{{}, {Limit: 10}, {Limit: 10, Offset: 20}} {
+		if _, err := b.Users.GetUserRequest(ctx, opts); err != nil {
+			t.Fatalf("%+v: %v", opts, err)
+		}
+	}
+	if want := []string{"", "limit=10", "limit=10&offset=20"}; !reflect.DeepEqual(queries, want) {
+		t.Errorf("queries = %q, want %q", queries, want)
+	}
+	if _, err := b.Admin.GetUserSignupRequests(ctx, ListOptions{Offset: -1}); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("negative offset: got %v, want ErrInvalidParams", err)
+	}
+}
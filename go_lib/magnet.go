@@ -0,0 +1,114 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// magnetPreviewTimeout : How long PreviewMagnet waits for the server to
+// resolve metadata when ctx has no deadline of its own
+const magnetPreviewTimeout = 60 * time.Second
+
+// ErrMetadataTimeout : the metadata of a magnet link couldn't be resolved
+// in time, e.g. for lack of peers in the DHT
+var ErrMetadataTimeout = errors.New("magnet metadata not resolved in time")
+
+// MagnetFile : File listed in the metadata of a magnet link
+type MagnetFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// MagnetPreview : Metadata of a magnet link resolved by the server
+type MagnetPreview struct {
+	Name  string       `json:"name"`
+	Size  int64        `json:"size"`
+	Files []MagnetFile `json:"files"`
+}
+
+// PreviewMagnet : Function to have the server resolve the metadata of a
+// magnet link, to show its name, size and files before adding it. Nothing is
+// queued. Resolution through the DHT can take long, so the client timeout
+// doesn't apply; the call is bounded by ctx, or by 60 seconds when ctx has
+// no deadline. Running out of time returns ErrMetadataTimeout.
+func (s *DownloadsService) PreviewMagnet(ctx context.Context, link string) (MagnetPreview, error) {
+	if err := validateMagnet(link); err != nil {
+		return MagnetPreview{}, err
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, magnetPreviewTimeout)
+		defer cancel()
+	}
+	body := map[string]string{"link": link}
+	response, err := s.client.roundTrip(ctx, s.client.unboundedDoer(), "POST", "/api/magnet/preview", body, nil)
+	var apiErr *APIError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return MagnetPreview{}, fmt.Errorf("%w: %w", ErrMetadataTimeout, err)
+	case errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusGatewayTimeout || apiErr.StatusCode == http.StatusRequestTimeout):
+		return MagnetPreview{}, fmt.Errorf("%w: %w", ErrMetadataTimeout, err)
+	case err != nil:
+		return MagnetPreview{}, err
+	}
+	defer response.Body.Close()
+	respBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return MagnetPreview{}, fmt.Errorf("%w: %w", ErrMetadataTimeout, ctx.Err())
+		}
+		return MagnetPreview{}, err
+	}
+	var preview MagnetPreview
+	if err := s.client.decode(respBody, &preview); err != nil {
+		return MagnetPreview{}, err
+	}
+	return preview, nil
+}
+
+// validateMagnet : Helper function to check that link is a magnet link
+// naming a BitTorrent info hash, in hex or base32
+func validateMagnet(link string) error {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || u.Scheme != "magnet" {
+		return invalidParam("link", "is not a magnet link")
+	}
+	for _, xt := range u.Query()["xt"] {
+		if !strings.HasPrefix(xt, "urn:btih:") {
+			continue
+		}
+		hash := strings.TrimPrefix(xt, "urn:btih:")
+		if len(hash) == 40 {
+			if _, err := hex.DecodeString(hash); err == nil {
+				return nil
+			}
+		}
+		if len(hash) == 32 {
+			if _, err := base32.StdEncoding.DecodeString(strings.ToUpper(hash)); err == nil {
+				return nil
+			}
+		}
+	}
+	return invalidParam("link", "has no valid urn:btih info hash")
+}
@@ -0,0 +1,99 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const testMagnet = "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&dn=debian"
+
+func TestPreviewMagnet(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.Method != "POST" || r.URL.Path != "/api/magnet/preview" || body["link"] != testMagnet {
+			t.Errorf("unexpected request %s %s %v", r.Method, r.URL.Path, body)
+		}
+		w.Write([]byte(`{"name": "debian", "size": 3000, "files": [
+			{"path": "debian/netinst.iso", "size": 2900},
+			{"path": "debian/SHA256SUMS", "size": 100}
+		]}`))
+	}))
+	preview, err := b.Downloads.PreviewMagnet(context.Background(), testMagnet)
+	if err != nil {
+		t.Fatalf("PreviewMagnet: %v", err)
+	}
+	want := MagnetPreview{Name: "debian", Size: 3000, Files: []MagnetFile{
+		{Path: "debian/netinst.iso", Size: 2900},
+		{Path: "debian/SHA256SUMS", Size: 100},
+	}}
+	if !reflect.DeepEqual(preview, want) {
+		t.Errorf("got %+v, want %+v", preview, want)
+	}
+}
+
+func TestPreviewMagnetTimeout(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGatewayTimeout)
+	}))
+	if _, err := b.Downloads.PreviewMagnet(context.Background(), testMagnet); !errors.Is(err, ErrMetadataTimeout) {
+		t.Errorf("504: got %v, want ErrMetadataTimeout", err)
+	}
+
+	slow := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices the client leaving once the body is read
+		io.Copy(ioutil.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := slow.Downloads.PreviewMagnet(ctx, testMagnet)
+	if !errors.Is(err, ErrMetadataTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("deadline: got %v, want ErrMetadataTimeout and DeadlineExceeded", err)
+	}
+}
+
+func TestValidateMagnet(t *testing.T) {
+	tests := []struct {
+		link  string
+		valid bool
+	}{
+		{testMagnet, true},
+		{"magnet:?dn=x&xt=urn:btih:MFRGGZDFMZTWQ2LKNNWG23TPOBYXE43U", true},
+		{"magnet:?xt=urn:btih:mfrggzdfmztwq2lknnwg23tpobyxe43u", true},
+		{"", false},
+		{"http://example.com/file.torrent", false},
+		{"magnet:?dn=no-hash", false},
+		{"magnet:?xt=urn:btih:1234", false},
+		{"magnet:?xt=urn:sha1:c12fe1c06bba254a9dc9f519b335aa7c1367a88a", false},
+	}
+	for _, tt := range tests {
+		err := validateMagnet(tt.link)
+		if (err == nil) != tt.valid {
+			t.Errorf("%q: err = %v, want valid %v", tt.link, err, tt.valid)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("%q: got %v, want ErrInvalidParams", tt.link, err)
+		}
+	}
+}
@@ -0,0 +1,361 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Option : Setting applied to a client by NewClient
+type Option func(*Client)
+
+// failOption : Helper function for an Option given an invalid setting. The
+// first such error is kept and returned by every request of the client.
+func failOption(err error) Option {
+	return func(b *Client) {
+		if b.configErr == nil {
+			b.configErr = err
+		}
+	}
+}
+
+// WithTimeout : Option to give up on a request, retries included, after
+// timeout instead of 5 seconds
+func WithTimeout(timeout time.Duration) Option {
+	if timeout <= 0 {
+		return failOption(invalidParam("timeout", "must be positive"))
+	}
+	return func(b *Client) {
+		b.timeout = int(timeout / time.Millisecond)
+		if client, ok := b.doer.(*http.Client); ok {
+			client.Timeout = timeout
+		}
+		b.httpClient = newHTTPClient(b.doer, b.retryCount)
+	}
+}
+
+// WithRetryCount : Option to retry a request n times instead of once when
+// the server can't be reached or answers with a 5xx status
+func WithRetryCount(n int) Option {
+	if n < 0 {
+		return failOption(invalidParam("retryCount", "must not be negative"))
+	}
+	return func(b *Client) {
+		b.retryCount = n
+		b.httpClient = newHTTPClient(b.doer, n)
+	}
+}
+
+// WithHTTPClient : Option to send requests through a copy of client, e.g. to
+// use its transport or proxy settings. The timeout of client, when set,
+// replaces the one of the client being created. Redirects to a login page
+// are still detected unless client has a CheckRedirect of its own.
+func WithHTTPClient(client *http.Client) Option {
+	if client == nil {
+		return failOption(invalidParam("httpClient", "must not be nil"))
+	}
+	return func(b *Client) {
+		copied := *client
+		if copied.Timeout == 0 {
+			copied.Timeout = time.Duration(b.timeout) * time.Millisecond
+		}
+		if copied.CheckRedirect == nil {
+			copied.CheckRedirect = b.checkRedirect
+		}
+		b.timeout = int(copied.Timeout / time.Millisecond)
+		b.doer = &copied
+		b.httpClient = newHTTPClient(b.doer, b.retryCount)
+	}
+}
+
+// WithToken : Option to start from the session token of an earlier Login,
+// instead of logging in again
+func WithToken(token string) Option {
+	if strings.TrimSpace(token) == "" {
+		return failOption(invalidParam("token", "must not be empty"))
+	}
+	return func(b *Client) {
+		b.token = token
+	}
+}
+
+// WithOutputWriter : Option to write the JSON responses of the methods
+// returning them, such as StartDownload, to w, one per line. Nothing is
+// written anywhere by default.
+func WithOutputWriter(w io.Writer) Option {
+	if w == nil {
+		return failOption(invalidParam("output", "must not be nil"))
+	}
+	return func(b *Client) {
+		b.output = w
+	}
+}
+
+// WithPrettyPrint : Option to indent and color the responses written to the
+// writer set by WithOutputWriter, for reading them in a terminal
+func WithPrettyPrint(pretty bool) Option {
+	return func(b *Client) {
+		b.prettyPrint = pretty
+	}
+}
+
+// defaultBulkConcurrency : Users processed at once by the bulk operations
+// unless WithBulkConcurrency says otherwise
+const defaultBulkConcurrency = 4
+
+// WithBulkConcurrency : Option to set how many users bulk operations such
+// as BlockUsers process at once, e.g. 1 to send their requests one by one
+func WithBulkConcurrency(n int) Option {
+	if n <= 0 {
+		return failOption(invalidParam("bulkConcurrency", "must be positive"))
+	}
+	return func(b *Client) {
+		b.bulkConcurrency = n
+	}
+}
+
+// WithStrictDecoding : Option to fail on response fields the models don't
+// declare, e.g. in tests against a server to catch schema changes early.
+// By default they are ignored, so that newer servers adding fields keep
+// working.
+func WithStrictDecoding(strict bool) Option {
+	return func(b *Client) {
+		b.strictDecoding = strict
+	}
+}
+
+// WithValidationRules : Option to check user names and passwords against
+// rules before creating or updating accounts, e.g. to mirror the server's
+// password policy and report violations without a round trip
+func WithValidationRules(rules ValidationRules) Option {
+	if rules.Password.MinLength < 0 {
+		return failOption(invalidParam("minLength", "must not be negative"))
+	}
+	return func(b *Client) {
+		b.rules = rules
+	}
+}
+
+// compressionThreshold : Request bodies up to this many bytes are sent as is
+// even with compression enabled, as gzip doesn't pay off for them
+const compressionThreshold = 1024
+
+// WithRequestCompression : Option to gzip JSON request bodies larger than
+// 1 KiB and mark them with Content-Encoding: gzip. Only enable it for
+// servers known to accept compressed requests.
+func WithRequestCompression() Option {
+	return func(b *Client) {
+		b.compressRequests = true
+	}
+}
+
+// apiKeyHeader : Header carrying the key set by WithAPIKey
+const apiKeyHeader = "X-Api-Key"
+
+// WithAPIKey : Option to authenticate every request with a long-lived API
+// key sent in the X-Api-Key header, for service accounts which can't Login.
+// An empty key makes NewClient fail with a *ValidationError.
+func WithAPIKey(key string) Option {
+	if strings.TrimSpace(key) == "" {
+		return failOption(invalidParam("apiKey", "must not be empty"))
+	}
+	return func(b *Client) {
+		b.apiKey = key
+	}
+}
+
+// WithServerKey : Option to set the key of the server sent by
+// DownloadsService.Start and Kill, for servers not using the default one.
+// An empty key makes NewClient fail with a *ValidationError.
+func WithServerKey(key string) Option {
+	if strings.TrimSpace(key) == "" {
+		return failOption(invalidParam("serverKey", "must not be empty"))
+	}
+	return func(b *Client) {
+		b.serverKey = key
+	}
+}
+
+// WithResponseRedaction : Option to blank the given JSON fields, e.g. "email",
+// in every decoded response, wherever they appear, so that callers such as
+// analytics jobs never see them. Names are the canonical json tags of the
+// returned structs; the legacy names of older servers are covered too.
+func WithResponseRedaction(fields ...string) Option {
+	redacted := map[string]bool{}
+	for _, field := range fields {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		redacted[field] = true
+		if legacy, ok := legacyFieldNames[field]; ok {
+			redacted[legacy] = true
+		}
+	}
+	return func(b *Client) {
+		if len(redacted) > 0 {
+			b.redactedFields = redacted
+		}
+	}
+}
+
+// WithBasePath : Option for servers mounted under a sub-path by a reverse
+// proxy, e.g. WithBasePath("/bassa") sends Login to /bassa/api/login.
+// Leading, trailing and repeated slashes are normalized.
+func WithBasePath(prefix string) Option {
+	var parts []string
+	for _, part := range strings.Split(prefix, "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	basePath := ""
+	if len(parts) > 0 {
+		basePath = "/" + strings.Join(parts, "/")
+	}
+	return func(b *Client) {
+		b.basePath = basePath
+	}
+}
+
+// apiVersionPattern : Valid API version segments, e.g. v2 or 1.1
+var apiVersionPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// WithAPIVersion : Option for servers serving a versioned API, e.g.
+// WithAPIVersion("v2") sends Login to /api/v2/login. It combines with
+// WithBasePath; an empty version keeps the unversioned routes.
+func WithAPIVersion(version string) Option {
+	version = strings.Trim(strings.TrimSpace(version), "/")
+	if version != "" && !apiVersionPattern.MatchString(version) {
+		return failOption(invalidParam("apiVersion", "must be a single path segment"))
+	}
+	return func(b *Client) {
+		b.apiVersion = version
+	}
+}
+
+// callRetriesKey : Context key of the retry count set by WithCallRetries
+type callRetriesKey struct{}
+
+// WithCallRetries : Function to override the client's retry count for the
+// calls made with the returned context, e.g. 0 for an expensive call that
+// must not be repeated. Negative counts are treated as 0.
+func WithCallRetries(ctx context.Context, n int) context.Context {
+	if n < 0 {
+		n = 0
+	}
+	return context.WithValue(ctx, callRetriesKey{}, n)
+}
+
+// RequestOption : Setting for the calls made with a context returned by
+// WithRequestOptions
+type RequestOption func(*requestOptions)
+
+// requestOptions : Per-call settings carried by a context
+type requestOptions struct {
+	header  http.Header
+	query   url.Values
+	timeout time.Duration
+}
+
+// requestOptionsKey : Context key of the settings set by WithRequestOptions
+type requestOptionsKey struct{}
+
+// WithRequestOptions : Function to apply opts to the calls made with the
+// returned context, without rebuilding the client:
+//
+//	ctx = bassa.WithRequestOptions(ctx, bassa.WithHeader("X-Trace-Id", id))
+//
+// Options add to those already set on ctx.
+func WithRequestOptions(ctx context.Context, opts ...RequestOption) context.Context {
+	current := requestOptionsFrom(ctx)
+	next := requestOptions{header: current.header.Clone(), query: url.Values{}, timeout: current.timeout}
+	for key, values := range current.query {
+		next.query[key] = append([]string(nil), values...)
+	}
+	if next.header == nil {
+		next.header = http.Header{}
+	}
+	for _, opt := range opts {
+		opt(&next)
+	}
+	return context.WithValue(ctx, requestOptionsKey{}, next)
+}
+
+// requestOptionsFrom : Helper function returning the settings set on ctx
+// with WithRequestOptions
+func requestOptionsFrom(ctx context.Context) requestOptions {
+	opts, _ := ctx.Value(requestOptionsKey{}).(requestOptions)
+	return opts
+}
+
+// WithHeader : RequestOption to add a header to the request, e.g. a tracing
+// ID. The session token and the signature can't be overridden.
+func WithHeader(name string, value string) RequestOption {
+	return func(o *requestOptions) {
+		o.header.Add(name, value)
+	}
+}
+
+// WithQuery : RequestOption to add a query parameter to the request
+func WithQuery(key string, value string) RequestOption {
+	return func(o *requestOptions) {
+		o.query.Add(key, value)
+	}
+}
+
+// WithRequestTimeout : RequestOption to give up on the request, retries
+// included, after timeout instead of the client's timeout. It may be longer
+// than the client's; zero or negative timeouts are ignored.
+func WithRequestTimeout(timeout time.Duration) RequestOption {
+	return func(o *requestOptions) {
+		if timeout > 0 {
+			o.timeout = timeout
+		}
+	}
+}
+
+// cancelOnClose : Response body releasing the context of a call with its
+// own timeout once the body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close : Function to close the body and release the call's context
+func (c cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// gzipBody : Helper function to compress a request body
+func gzipBody(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
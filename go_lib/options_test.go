@@ -0,0 +1,417 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithRequestCompression(t *testing.T) {
+	var encoding string
+	var received map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		var body io.Reader = r.Body
+		if encoding == "gzip" {
+			reader, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Fatalf("gzip.NewReader: %v", err)
+			}
+			body = reader
+		}
+		data, _ := ioutil.ReadAll(body)
+		received = nil
+		if err := json.Unmarshal(data, &received); err != nil {
+			t.Errorf("body is not JSON: %v", err)
+		}
+		w.Write([]byte(`{"status": "success"}`))
+	}))
+	defer server.Close()
+
+	b := &Client{}
+	b.Init(server.URL, 1000, 0, WithRequestCompression())
+
+	// The encoded body of a maximum length note exceeds compressionThreshold
+	note := strings.Repeat("n", MaxNoteLength)
+	if err := b.Downloads.SetDownloadNote(context.Background(), 7, note); err != nil {
+		t.Fatal(err)
+	}
+	if encoding != "gzip" {
+		t.Errorf("large body: Content-Encoding = %q, want gzip", encoding)
+	}
+	if received["note"] != note {
+		t.Errorf("large body: note not received intact")
+	}
+
+	if err := b.Downloads.SetDownloadNote(context.Background(), 7, "short"); err != nil {
+		t.Fatal(err)
+	}
+	if encoding != "" {
+		t.Errorf("small body: Content-Encoding = %q, want none", encoding)
+	}
+	if received["note"] != "short" {
+		t.Errorf("small body: note = %q, want short", received["note"])
+	}
+}
+
+func TestRequestCompressionIsOptIn(t *testing.T) {
+	var encoding string
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		w.Write([]byte(`{"status": "success"}`))
+	}))
+	if err := b.Downloads.SetDownloadNote(context.Background(), 7, strings.Repeat("n", MaxNoteLength)); err != nil {
+		t.Fatal(err)
+	}
+	if encoding != "" {
+		t.Errorf("Content-Encoding = %q without WithRequestCompression", encoding)
+	}
+}
+
+func TestWithAPIKey(t *testing.T) {
+	var keys []string
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("X-Api-Key"))
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `["starting"]`)
+	}), WithAPIKey("svc-key"))
+
+	if !b.IsAuthenticated() {
+		t.Error("IsAuthenticated = false with an API key set")
+	}
+	// No Login beforehand: the key alone must authenticate the calls
+	if err := b.Admin.PauseAll(context.Background()); err != nil {
+		t.Fatalf("PauseAll: %v", err)
+	}
+	if _, err := b.Downloads.GetDownloadLogs(context.Background(), 4); err != nil {
+		t.Fatalf("GetDownloadLogs: %v", err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("got %d requests, want 2", len(keys))
+	}
+	for i, key := range keys {
+		if key != "svc-key" {
+			t.Errorf("request %d: X-Api-Key = %q, want svc-key", i, key)
+		}
+	}
+}
+
+func TestWithAPIKeyEmpty(t *testing.T) {
+	for _, key := range []string{"", "  "} {
+		var calls int32
+		b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+		}), WithAPIKey(key))
+		var verr *ValidationError
+		if err := b.Admin.PauseAll(context.Background()); !errors.As(err, &verr) || verr.Field != "apiKey" {
+			t.Errorf("WithAPIKey(%q): got %v, want apiKey ValidationError", key, err)
+		}
+		if n := atomic.LoadInt32(&calls); n != 0 {
+			t.Errorf("WithAPIKey(%q): sent %d requests, want none", key, n)
+		}
+		if _, err := NewWithFailover("http://localhost:5000", nil, WithAPIKey(key)); !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("NewWithFailover with WithAPIKey(%q) = %v, want ErrInvalidParams", key, err)
+		}
+	}
+}
+
+func TestIsAuthenticatedWithoutCredentials(t *testing.T) {
+	b := &Client{}
+	b.Init("http://localhost:5000", 1000, 0)
+	if b.IsAuthenticated() {
+		t.Error("IsAuthenticated = true before Login")
+	}
+}
+
+func TestWithBasePath(t *testing.T) {
+	var paths []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("token", "t0k3n")
+	})
+	for _, prefix := range []string{"/bassa", "bassa/", "//bassa//"} {
+		paths = nil
+		server := httptest.NewServer(handler)
+		b := &Client{}
+		b.Init(server.URL+"/", 1000, 0, WithBasePath(prefix))
+		if err := b.Auth.Login(context.Background(), "rand", "secret"); err != nil {
+			t.Fatalf("Login: %v", err)
+		}
+		if err := b.Admin.PauseAll(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Warmup(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		server.Close()
+		want := []string{"/bassa/api/login", "/bassa/api/downloads/pause", "/bassa/"}
+		if strings.Join(paths, " ") != strings.Join(want, " ") {
+			t.Errorf("%q: paths = %q, want %q", prefix, paths, want)
+		}
+	}
+}
+
+func TestWithBasePathEmpty(t *testing.T) {
+	for _, prefix := range []string{"", "/", "//"} {
+		b := &Client{}
+		b.Init("http://localhost:5000", 1000, 0, WithBasePath(prefix))
+		if got := b.endpointURL("/api/login"); got != "http://localhost:5000/api/login" {
+			t.Errorf("%q: got %s", prefix, got)
+		}
+	}
+}
+
+func TestWithAPIVersion(t *testing.T) {
+	tests := []struct {
+		version  string
+		endpoint string
+		want     string
+	}{
+		{"v2", "/api/login", "http://localhost:5000/bassa/api/v2/login"},
+		{"/v2/", "/api/user?limit=5", "http://localhost:5000/bassa/api/v2/user?limit=5"},
+		{"v2", "/", "http://localhost:5000/bassa/"},
+		{"v2", "/apis", "http://localhost:5000/bassa/apis"},
+		{"", "/api/login", "http://localhost:5000/bassa/api/login"},
+	}
+	for _, tt := range tests {
+		b, err := NewClient("http://localhost:5000", WithBasePath("/bassa"), WithAPIVersion(tt.version))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := b.endpointURL(tt.endpoint); got != tt.want {
+			t.Errorf("%q %s: got %s, want %s", tt.version, tt.endpoint, got, tt.want)
+		}
+	}
+	if _, err := NewClient("http://localhost:5000", WithAPIVersion("v2/beta")); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("two segments: got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestWithCallRetries(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	b := &Client{}
+	b.Init(server.URL, 1000, 2)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want int32
+	}{
+		{"no retries", WithCallRetries(context.Background(), 0), 1},
+		{"client default", context.Background(), 3},
+		{"more retries", WithCallRetries(context.Background(), 3), 4},
+		{"negative", WithCallRetries(context.Background(), -1), 1},
+	}
+	for _, tt := range tests {
+		atomic.StoreInt32(&attempts, 0)
+		if err := b.Admin.PauseAll(tt.ctx); err == nil {
+			t.Errorf("%s: PauseAll succeeded against a failing server", tt.name)
+		}
+		if got := atomic.LoadInt32(&attempts); got != tt.want {
+			t.Errorf("%s: %d attempts, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWithResponseRedaction(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"user_name": "rand", "email": "rand@scorelab.org", "auth": 0},
+			{"user_name": "mat", "email": "mat@scorelab.org", "auth_level": 1, "verified": true}]`))
+	}), WithResponseRedaction("email", " auth_level ", ""))
+
+	var users []User
+	if err := b.do(context.Background(), "GET", "/api/user", nil, &users); err != nil {
+		t.Fatal(err)
+	}
+	want := []User{
+		{UserName: "rand"},
+		{UserName: "mat", Verified: true},
+	}
+	if len(users) != 2 || users[0] != want[0] || users[1] != want[1] {
+		t.Errorf("users = %+v, want %+v", users, want)
+	}
+
+	var buf strings.Builder
+	if err := b.Admin.ExportUsersCSV(context.Background(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "\nrand,,0,false\n") || strings.Contains(buf.String(), "@") {
+		t.Errorf("export leaked redacted fields:\n%s", buf.String())
+	}
+}
+
+func TestNewClientOptions(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	var dials int32
+	custom := &http.Client{Transport: countingTransport(&dials)}
+	b, err := NewClient(server.URL, WithHTTPClient(custom), WithTimeout(2*time.Second), WithRetryCount(0), WithToken("stored-token"))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if !b.IsAuthenticated() {
+		t.Error("WithToken didn't authenticate the client")
+	}
+	if err := b.Admin.PauseAll(context.Background()); err == nil {
+		t.Fatal("PauseAll against a 503 returned nil")
+	}
+	if got.Get("token") != "stored-token" {
+		t.Errorf("token header = %q, want stored-token", got.Get("token"))
+	}
+	if n := atomic.LoadInt32(&dials); n != 1 {
+		t.Errorf("dials = %d, want 1 through the custom client without retries", n)
+	}
+	if custom.Timeout != 0 || custom.CheckRedirect != nil {
+		t.Error("WithHTTPClient modified the caller's client")
+	}
+	if client := b.doer.(*http.Client); client.Timeout != 2*time.Second {
+		t.Errorf("timeout = %v, want 2s", client.Timeout)
+	}
+}
+
+func TestNewClientInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		apiURL string
+		opts   []Option
+		field  string
+	}{
+		{name: "relative URL", apiURL: "localhost:5000", field: "endpoint"},
+		{name: "empty URL", apiURL: "", field: "endpoint"},
+		{name: "timeout", apiURL: "http://localhost:5000", opts: []Option{WithTimeout(0)}, field: "timeout"},
+		{name: "retry count", apiURL: "http://localhost:5000", opts: []Option{WithRetryCount(-1)}, field: "retryCount"},
+		{name: "http client", apiURL: "http://localhost:5000", opts: []Option{WithHTTPClient(nil)}, field: "httpClient"},
+		{name: "token", apiURL: "http://localhost:5000", opts: []Option{WithToken(" ")}, field: "token"},
+		{name: "server key", apiURL: "http://localhost:5000", opts: []Option{WithServerKey(" ")}, field: "serverKey"},
+	}
+	for _, tt := range tests {
+		b, err := NewClient(tt.apiURL, tt.opts...)
+		var verr *ValidationError
+		if b != nil || !errors.As(err, &verr) || verr.Field != tt.field {
+			t.Errorf("%s: got %v, %v; want a %s ValidationError", tt.name, b, err, tt.field)
+		}
+	}
+}
+
+func TestWithOutputWriter(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":  "started"}`))
+	})
+	var out bytes.Buffer
+	b := newTestClient(t, handler, WithOutputWriter(&out))
+	got, err := b.Admin.StartDownload(context.Background(), "")
+	if err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+	if want := `{"status":"started"}`; got != want || out.String() != want+"\n" {
+		t.Errorf("returned %q and wrote %q, want %q", got, out.String(), want)
+	}
+
+	out.Reset()
+	b = newTestClient(t, handler, WithOutputWriter(&out), WithPrettyPrint(true))
+	if _, err := b.Admin.StartDownload(context.Background(), ""); err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+	if !strings.Contains(out.String(), "\n  ") || !strings.Contains(out.String(), "started") {
+		t.Errorf("pretty output = %q", out.String())
+	}
+}
+
+func TestNoOutputByDefault(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Key") != defaultServerKey {
+			t.Errorf("key = %q, want the default", r.Header.Get("Key"))
+		}
+		w.Write([]byte(`{"status": "killed"}`))
+	}))
+	if got, err := b.Admin.KillDownload(context.Background(), ""); err != nil || got != `{"status":"killed"}` {
+		t.Errorf("KillDownload = %q, %v", got, err)
+	}
+	if b.output != nil {
+		t.Error("output writer set by default")
+	}
+}
+
+func TestWithRequestOptions(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Trace-Id"); got != "trace-1" {
+			t.Errorf("X-Trace-Id = %q", got)
+		}
+		if got := r.Header.Get("token"); got != "" {
+			t.Errorf("token header overridden with %q", got)
+		}
+		if got := r.URL.Query(); got.Get("limit") != "5" || got.Get("verbose") != "1" {
+			t.Errorf("query = %v", got)
+		}
+		w.Write([]byte(`[]`))
+	}))
+	ctx := WithRequestOptions(context.Background(), WithHeader("X-Trace-Id", "trace-1"), WithHeader("token", "forged"))
+	ctx = WithRequestOptions(ctx, WithQuery("verbose", "1"))
+	if _, err := b.Users.GetUserRequest(ctx, ListOptions{Limit: 5}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithRequestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		w.Write([]byte(`{}`))
+	}), WithTimeout(50*time.Millisecond), WithRetryCount(0))
+
+	// Outlasting the client's timeout
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		close(release)
+	}()
+	ctx := WithRequestOptions(context.Background(), WithRequestTimeout(5*time.Second))
+	if err := b.do(ctx, "GET", "/api/slow", nil, nil); err != nil {
+		t.Errorf("long request timeout: %v", err)
+	}
+
+	// Shorter than the client's
+	b = newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}), WithRetryCount(0))
+	ctx = WithRequestOptions(context.Background(), WithRequestTimeout(20*time.Millisecond))
+	start := time.Now()
+	if err := b.do(ctx, "GET", "/api/slow", nil, nil); err == nil || time.Since(start) > 2*time.Second {
+		t.Errorf("short request timeout: got %v after %v", err, time.Since(start))
+	}
+}
@@ -0,0 +1,62 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// resetEpochThreshold : X-RateLimit-Reset values from this one up are Unix
+// timestamps, smaller ones the seconds left until the reset
+const resetEpochThreshold = 1000000000
+
+// rateLimitState : Latest rate limit reported by the server
+type rateLimitState struct {
+	remaining int
+	reset     time.Time
+	known     bool
+}
+
+// RateLimitStatus : Function to get the rate limit reported with the latest
+// response carrying X-RateLimit-Remaining, so callers can slow down before
+// the server answers 429. reset is zero if the server didn't say when the
+// limit resets; ok is false until the server reported a limit.
+func (b *Client) RateLimitStatus() (remaining int, reset time.Time, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rateLimit.remaining, b.rateLimit.reset, b.rateLimit.known
+}
+
+// recordRateLimit : Function to keep the rate limit headers of a response.
+// Responses without a valid X-RateLimit-Remaining leave the last values.
+func (b *Client) recordRateLimit(header http.Header) {
+	remaining, err := strconv.Atoi(strings.TrimSpace(header.Get("X-RateLimit-Remaining")))
+	if err != nil || remaining < 0 {
+		return
+	}
+	state := rateLimitState{remaining: remaining, known: true}
+	if seconds, err := strconv.ParseInt(strings.TrimSpace(header.Get("X-RateLimit-Reset")), 10, 64); err == nil && seconds >= 0 {
+		if seconds >= resetEpochThreshold {
+			state.reset = time.Unix(seconds, 0)
+		} else {
+			state.reset = timeNow().Add(time.Duration(seconds) * time.Second)
+		}
+	}
+	b.mu.Lock()
+	b.rateLimit = state
+	b.mu.Unlock()
+}
@@ -0,0 +1,64 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimitStatus(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	fixClock(t, now)
+	var remaining, reset string
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if remaining != "" {
+			w.Header().Set("X-RateLimit-Remaining", remaining)
+		}
+		if reset != "" {
+			w.Header().Set("X-RateLimit-Reset", reset)
+		}
+		if remaining == "0" {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	if _, _, ok := b.RateLimitStatus(); ok {
+		t.Error("ok before any response")
+	}
+
+	tests := []struct {
+		remaining, reset string
+		wantRemaining    int
+		wantReset        time.Time
+	}{
+		{remaining: "42", reset: "30", wantRemaining: 42, wantReset: now.Add(30 * time.Second)},
+		{remaining: "41", reset: "1591016400", wantRemaining: 41, wantReset: time.Unix(1591016400, 0)},
+		{remaining: "40", wantRemaining: 40},
+		// Missing or invalid headers keep the last values
+		{wantRemaining: 40},
+		{remaining: "many", reset: "10", wantRemaining: 40},
+		// A 429 carries the headers too
+		{remaining: "0", reset: "5", wantRemaining: 0, wantReset: now.Add(5 * time.Second)},
+	}
+	for _, tt := range tests {
+		remaining, reset = tt.remaining, tt.reset
+		b.Admin.PauseAll(context.Background())
+		gotRemaining, gotReset, ok := b.RateLimitStatus()
+		if !ok || gotRemaining != tt.wantRemaining || !gotReset.Equal(tt.wantReset) {
+			t.Errorf("%q/%q: got %d, %v, %v; want %d, %v", tt.remaining, tt.reset, gotRemaining, gotReset, ok, tt.wantRemaining, tt.wantReset)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// maxRedirects : Number of API redirects followed before a request fails,
+// as for a default http.Client
+const maxRedirects = 10
+
+// WithAuthRedirectPattern : Option to tell redirects to a login page, as sent
+// by SSO proxies for an expired session, by their target URL matching
+// pattern, e.g. `^https://sso\.example\.com/`. Without it, redirects leaving
+// the configured servers or their API paths count as login redirects. These
+// aren't followed; the request fails with ErrNotAuthenticated instead. An
+// invalid pattern makes NewWithFailover and every request fail with a
+// *ValidationError.
+func WithAuthRedirectPattern(pattern string) Option {
+	re, err := regexp.Compile(pattern)
+	if err != nil || pattern == "" {
+		return failOption(invalidParam("pattern", "is not a valid regular expression"))
+	}
+	return func(b *Client) {
+		b.authRedirectPattern = re
+	}
+}
+
+// checkRedirect : Function deciding whether the client follows a redirect.
+// Login redirects stop with the redirect response, for roundTrip to report.
+func (b *Client) checkRedirect(request *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return errors.New("stopped after 10 redirects")
+	}
+	if b.authRedirect(request.URL) {
+		return http.ErrUseLastResponse
+	}
+	return nil
+}
+
+// authRedirect : Function telling whether a redirect target is a login page
+// rather than an API endpoint
+func (b *Client) authRedirect(target *url.URL) bool {
+	if b.authRedirectPattern != nil {
+		return b.authRedirectPattern.MatchString(target.String())
+	}
+	if !strings.HasPrefix(target.Path, b.basePath+"/api/") {
+		return true
+	}
+	for _, endpoint := range b.endpointOrder() {
+		if u, err := url.Parse(endpoint); err == nil && strings.EqualFold(u.Host, target.Host) {
+			return false
+		}
+	}
+	return true
+}
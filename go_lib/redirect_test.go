@@ -0,0 +1,84 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestAuthRedirect(t *testing.T) {
+	var ssoHits int32
+	sso := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&ssoHits, 1)
+		w.Write([]byte(`<html>login</html>`))
+	}))
+	defer sso.Close()
+
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/downloads/pause":
+			http.Redirect(w, r, sso.URL+"/login?next=/api/downloads/pause", http.StatusFound)
+		case "/api/downloads/resume":
+			http.Redirect(w, r, "/sso/login", http.StatusFound)
+		case "/api/old/storage":
+			// API redirects are followed
+			http.Redirect(w, r, "/api/user/storage", http.StatusMovedPermanently)
+		case "/api/user/storage":
+			w.Write([]byte(`[]`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+
+	if err := b.Admin.PauseAll(context.Background()); !errors.Is(err, ErrNotAuthenticated) {
+		t.Errorf("external login page: got %v, want ErrNotAuthenticated", err)
+	}
+	if err := b.Admin.ResumeAll(context.Background()); !errors.Is(err, ErrNotAuthenticated) {
+		t.Errorf("login page on the server: got %v, want ErrNotAuthenticated", err)
+	}
+	if n := atomic.LoadInt32(&ssoHits); n != 0 {
+		t.Errorf("the login page was requested %d times", n)
+	}
+	if err := b.do(context.Background(), "GET", "/api/old/storage", nil, nil); err != nil {
+		t.Errorf("API redirect: %v", err)
+	}
+}
+
+func TestWithAuthRedirectPattern(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/downloads/pause":
+			http.Redirect(w, r, "/auth/login", http.StatusFound)
+		case "/api/downloads/resume":
+			http.Redirect(w, r, "/static/resumed", http.StatusFound)
+		}
+	}), WithAuthRedirectPattern(`/auth/login$`))
+
+	if err := b.Admin.PauseAll(context.Background()); !errors.Is(err, ErrNotAuthenticated) {
+		t.Errorf("matching redirect: got %v, want ErrNotAuthenticated", err)
+	}
+	if err := b.Admin.ResumeAll(context.Background()); err != nil {
+		t.Errorf("other redirect: got %v, want it followed", err)
+	}
+
+	b = newTestClient(t, http.NotFoundHandler(), WithAuthRedirectPattern(`(`))
+	if err := b.Admin.PauseAll(context.Background()); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("invalid pattern: got %v, want ErrInvalidParams", err)
+	}
+}
@@ -0,0 +1,110 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// SearchResults : Users and downloads matching a search
+type SearchResults struct {
+	Users     []User     `json:"users"`
+	Downloads []Download `json:"downloads"`
+}
+
+// Search : Function to find the users and downloads matching query, e.g. for
+// a unified search box. Servers without a unified search endpoint get
+// SearchUsers and SearchDownloads sent concurrently instead.
+func (b *Client) Search(ctx context.Context, query string) (SearchResults, error) {
+	query, err := searchQuery(query)
+	if err != nil {
+		return SearchResults{}, err
+	}
+	var results SearchResults
+	err = b.do(ctx, "GET", searchEndpoint("/api/search", query), nil, &results)
+	if err == nil {
+		return results, nil
+	}
+	if !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrNotSupported) {
+		return SearchResults{}, err
+	}
+
+	var wg sync.WaitGroup
+	var usersErr, downloadsErr error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		results.Users, usersErr = b.Users.SearchUsers(ctx, query, ListOptions{})
+	}()
+	go func() {
+		defer wg.Done()
+		results.Downloads, downloadsErr = b.Downloads.SearchDownloads(ctx, query)
+	}()
+	wg.Wait()
+	if usersErr != nil {
+		return SearchResults{}, usersErr
+	}
+	if downloadsErr != nil {
+		return SearchResults{}, downloadsErr
+	}
+	return results, nil
+}
+
+// SearchUsers : Function to find the users whose name or email match query,
+// the page of the matches to get being set by opts
+func (s *UsersService) SearchUsers(ctx context.Context, query string, opts ListOptions) ([]User, error) {
+	query, err := searchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, err := opts.endpoint(searchEndpoint("/api/user/search", query))
+	if err != nil {
+		return nil, err
+	}
+	var users []User
+	if err := s.client.do(ctx, "GET", endpoint, nil, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// SearchDownloads : Function to find the downloads whose name or link match query
+func (s *DownloadsService) SearchDownloads(ctx context.Context, query string) ([]Download, error) {
+	query, err := searchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	var downloads []Download
+	if err := s.client.do(ctx, "GET", searchEndpoint("/api/downloads/search", query), nil, &downloads); err != nil {
+		return nil, err
+	}
+	return downloads, nil
+}
+
+// searchQuery : Helper function to trim a search query, rejecting an empty one
+func searchQuery(query string) (string, error) {
+	if query = strings.TrimSpace(query); query == "" {
+		return "", invalidParam("query", "must not be empty")
+	}
+	return query, nil
+}
+
+// searchEndpoint : Helper function adding the query to a search endpoint
+func searchEndpoint(endpoint string, query string) string {
+	return withQuery(endpoint, url.Values{"q": {query}})
+}
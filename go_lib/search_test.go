@@ -0,0 +1,128 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSearchNative(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/search" || r.URL.Query().Get("q") != "deb ian" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"users": [{"user_name": "debian-fan"}], "downloads": [{"id": 3, "download_name": "debian.iso"}]}`))
+	}))
+	results, err := b.Search(context.Background(), " deb ian ")
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results.Users) != 1 || results.Users[0].UserName != "debian-fan" || len(results.Downloads) != 1 || results.Downloads[0].ID != 3 {
+		t.Errorf("got %+v", results)
+	}
+}
+
+func TestSearchFanOut(t *testing.T) {
+	var mu sync.Mutex
+	var active, maxActive int
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("q") != "debian" {
+			t.Errorf("%s: q = %q", r.URL.Path, r.URL.Query().Get("q"))
+		}
+		if r.URL.Path == "/api/search" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		mu.Lock()
+		if active++; active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/api/user/search":
+			w.Write([]byte(`[{"user_name": "debian-fan"}]`))
+		case "/api/downloads/search":
+			w.Write([]byte(`[{"id": 3}, {"id": 4}]`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	results, err := b.Search(context.Background(), "debian")
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results.Users) != 1 || len(results.Downloads) != 2 {
+		t.Errorf("got %+v", results)
+	}
+	if maxActive != 2 {
+		t.Errorf("searches in flight at once = %d, want 2", maxActive)
+	}
+}
+
+func TestSearchFanOutError(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/search":
+			w.WriteHeader(http.StatusNotImplemented)
+		case "/api/user/search":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+	if _, err := b.Search(context.Background(), "debian"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("got %v, want ErrForbidden", err)
+	}
+}
+
+func TestSearchValidation(t *testing.T) {
+	b := newOfflineClient()
+	if _, err := b.Search(context.Background(), "  "); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("Search = %v, want ErrInvalidParams", err)
+	}
+	if _, err := b.Users.SearchUsers(context.Background(), "", ListOptions{}); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("SearchUsers = %v, want ErrInvalidParams", err)
+	}
+	if _, err := b.Downloads.SearchDownloads(context.Background(), ""); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("SearchDownloads = %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestSearchUsersPaged(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/user/search" || r.URL.RawQuery != "q=ra&limit=10&offset=20" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`[{"user_name": "rand", "auth_level": 1}]`))
+	}))
+	users, err := b.Users.SearchUsers(context.Background(), " ra ", ListOptions{Limit: 10, Offset: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].UserName != "rand" || users[0].AuthLevel != AuthLevelRegular {
+		t.Errorf("got %+v", users)
+	}
+	if _, err := b.Users.SearchUsers(context.Background(), "ra", ListOptions{Limit: -1}); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("negative limit: got %v", err)
+	}
+}
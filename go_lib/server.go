@@ -0,0 +1,286 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeNow is swapped out by tests needing a fixed clock
+var timeNow = time.Now
+
+// serverInfo : Payload of the server's info endpoint. Fields are kept raw as
+// their format varies between server releases.
+type serverInfo struct {
+	Version   string          `json:"version"`
+	Features  []string        `json:"features"`
+	Uptime    json.RawMessage `json:"uptime"`
+	StartTime json.RawMessage `json:"start_time"`
+}
+
+// Features advertised by servers, gating the methods relying on them
+const (
+	FeatureWebhooks    = "webhooks"
+	FeatureTags        = "tags"
+	FeatureDownloadsV2 = "downloads_v2"
+)
+
+// ServerInfo : Release and enabled features of the server
+type ServerInfo struct {
+	Version string
+	// Features is nil when the server doesn't advertise its features, e.g.
+	// releases predating feature negotiation
+	Features []string
+}
+
+// HasFeature : Function to tell whether the server advertises feature.
+// Servers not advertising their features are assumed to have them all.
+func (i ServerInfo) HasFeature(feature string) bool {
+	if i.Features == nil {
+		return true
+	}
+	for _, f := range i.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// ServerInfo : Function to get the server release and the features it
+// enables. Methods needing a feature the server doesn't advertise return
+// ErrNotSupported without sending their request.
+func (b *Client) ServerInfo(ctx context.Context) (ServerInfo, error) {
+	info, err := b.getServerInfo(ctx)
+	if err != nil {
+		return ServerInfo{}, err
+	}
+	return ServerInfo{Version: info.Version, Features: info.Features}, nil
+}
+
+// getServerInfo : Function to fetch the server's info endpoint, recording
+// the version it reports so that requests use its field names, and the
+// features it advertises
+func (b *Client) getServerInfo(ctx context.Context) (serverInfo, error) {
+	var info serverInfo
+	err := b.do(ctx, "GET", "/api/info", nil, &info)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		// The server answered without an info endpoint: nothing to detect
+		b.setFeatures(nil)
+	}
+	if err != nil {
+		return serverInfo{}, err
+	}
+	if info.Features != nil {
+		info.Features = append([]string{}, info.Features...)
+	}
+	b.setServerVersion(info.Version)
+	b.setFeatures(info.Features)
+	return info, nil
+}
+
+// setFeatures : Function to record the features advertised by the server,
+// nil when it doesn't advertise them
+func (b *Client) setFeatures(features []string) {
+	b.mu.Lock()
+	b.features = ServerInfo{Features: features}
+	b.featuresDetected = true
+	b.mu.Unlock()
+}
+
+// requireFeature : Function to check that the server advertises feature
+// before calling an endpoint relying on it, fetching the info endpoint on
+// first use. When the features can't be detected the call goes ahead and
+// the server has the last word.
+func (b *Client) requireFeature(ctx context.Context, feature string) error {
+	b.mu.Lock()
+	features, detected := b.features, b.featuresDetected
+	b.mu.Unlock()
+	if !detected {
+		info, err := b.getServerInfo(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return nil
+		}
+		features = ServerInfo{Features: info.Features}
+	}
+	if !features.HasFeature(feature) {
+		return fmt.Errorf("feature %s: %w", feature, ErrNotSupported)
+	}
+	return nil
+}
+
+// GetUptime : Function to get how long the server has been running and when
+// it started. Servers report either the uptime in seconds or the start time;
+// the other value is derived from the local clock. Servers whose info
+// endpoint carries neither return ErrNotSupported.
+func (b *Client) GetUptime(ctx context.Context) (time.Duration, time.Time, error) {
+	info, err := b.getServerInfo(ctx)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	now := timeNow()
+	if len(info.StartTime) > 0 && string(info.StartTime) != "null" {
+		start, err := parseTimestamp(info.StartTime)
+		if err != nil {
+			return 0, time.Time{}, fmt.Errorf("start_time: %v", err)
+		}
+		return now.Sub(start), start, nil
+	}
+	if len(info.Uptime) > 0 && string(info.Uptime) != "null" {
+		uptime, err := parseSeconds(info.Uptime)
+		if err != nil {
+			return 0, time.Time{}, fmt.Errorf("uptime: %v", err)
+		}
+		return uptime, now.Add(-uptime), nil
+	}
+	return 0, time.Time{}, fmt.Errorf("uptime: %w", ErrNotSupported)
+}
+
+// parseSeconds : Helper function to read a duration sent as a number of
+// seconds, either as a JSON number or a string. Go duration strings such as
+// "3h20m" are accepted too.
+func parseSeconds(raw json.RawMessage) (time.Duration, error) {
+	text := strings.Trim(strings.TrimSpace(string(raw)), `"`)
+	if seconds, err := strconv.ParseFloat(text, 64); err == nil {
+		if seconds < 0 {
+			return 0, fmt.Errorf("negative duration %s", text)
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	duration, err := time.ParseDuration(text)
+	if err != nil {
+		return 0, fmt.Errorf("unrecognised duration %s", raw)
+	}
+	if duration < 0 {
+		return 0, fmt.Errorf("negative duration %s", text)
+	}
+	return duration, nil
+}
+
+// parseTimestamp : Helper function to read a point in time sent either as an
+// RFC 3339 string or as Unix seconds
+func parseTimestamp(raw json.RawMessage) (time.Time, error) {
+	text := strings.TrimSpace(string(raw))
+	if strings.HasPrefix(text, `"`) {
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return time.Time{}, err
+		}
+		if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return t, nil
+		}
+		text = value
+	}
+	seconds, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unrecognised timestamp %s", raw)
+	}
+	whole := int64(seconds)
+	return time.Unix(whole, int64((seconds-float64(whole))*float64(time.Second))).UTC(), nil
+}
+
+// ServerTime : Function to get the server's clock from the Date header of a
+// request to it. The header has a resolution of one second.
+func (b *Client) ServerTime(ctx context.Context) (time.Time, error) {
+	serverTime, _, err := b.serverTime(ctx)
+	return serverTime, err
+}
+
+// ClockSkew : Function to get how far the server's clock is ahead of the
+// local one, negative when it is behind. Half the round trip is allowed
+// for the response to arrive, so the result is accurate to about a second.
+func (b *Client) ClockSkew(ctx context.Context) (time.Duration, error) {
+	serverTime, localTime, err := b.serverTime(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return serverTime.Sub(localTime), nil
+}
+
+// serverTime : Function returning the server's time along with the local
+// time at the middle of the round trip
+func (b *Client) serverTime(ctx context.Context) (time.Time, time.Time, error) {
+	start := timeNow()
+	response, err := b.probe(ctx)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end := timeNow()
+	serverTime, err := parseDateHeader(response.Header)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return serverTime, start.Add(end.Sub(start) / 2), nil
+}
+
+// parseDateHeader : Helper function to read the Date header of a response
+func parseDateHeader(header http.Header) (time.Time, error) {
+	date := header.Get("Date")
+	if date == "" {
+		return time.Time{}, errors.New("response carries no Date header")
+	}
+	serverTime, err := http.ParseTime(date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Date header: %v", err)
+	}
+	return serverTime, nil
+}
+
+// clientAPIVersion : Version of the server API this client implements
+const clientAPIVersion = "1.0.0"
+
+// GetAPIVersions : Function to get the oldest and newest API versions the
+// server supports, to warn when the client is out of that range. Servers
+// predating version negotiation return ErrNotFound or ErrNotSupported.
+func (b *Client) GetAPIVersions(ctx context.Context) (min, max string, err error) {
+	var versions struct {
+		Min string `json:"min"`
+		Max string `json:"max"`
+	}
+	if err := b.do(ctx, "GET", "/api/versions", nil, &versions); err != nil {
+		return "", "", err
+	}
+	min, max = strings.TrimSpace(versions.Min), strings.TrimSpace(versions.Max)
+	if !validVersion(min) || !validVersion(max) {
+		return "", "", fmt.Errorf("invalid API version range %q to %q", versions.Min, versions.Max)
+	}
+	if compareVersions(min, max) > 0 {
+		return "", "", fmt.Errorf("API version range %s to %s is inverted", min, max)
+	}
+	return min, max, nil
+}
+
+// apiCompatibility : Helper function to describe how the client's API
+// version fits in the server's range: -1 when the client is older, 1 when
+// newer and 0 when supported
+func apiCompatibility(min, max string) int {
+	if compareVersions(clientAPIVersion, min) < 0 {
+		return -1
+	}
+	if compareVersions(clientAPIVersion, max) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,247 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// fixClock : Helper function pinning timeNow for the duration of a test
+func fixClock(t *testing.T, now time.Time) {
+	t.Helper()
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = time.Now })
+}
+
+func TestGetUptime(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	fixClock(t, now)
+
+	tests := []struct {
+		name   string
+		body   string
+		uptime time.Duration
+	}{
+		{"seconds", `{"uptime": 7200}`, 2 * time.Hour},
+		{"fractional seconds", `{"uptime": 90.5}`, 90*time.Second + 500*time.Millisecond},
+		{"seconds as string", `{"uptime": "3600"}`, time.Hour},
+		{"start time", `{"start_time": "2020-06-01T09:00:00Z"}`, 3 * time.Hour},
+		{"start time with offset", `{"start_time": "2020-06-01T10:30:00+01:00"}`, 2*time.Hour + 30*time.Minute},
+		{"unix start time", `{"start_time": 1591002000}`, 3 * time.Hour},
+		{"start time wins", `{"uptime": 1, "start_time": "2020-06-01T11:00:00Z"}`, time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/info" {
+					t.Errorf("path = %s, want /api/info", r.URL.Path)
+				}
+				w.Write([]byte(tt.body))
+			}))
+			uptime, start, err := b.GetUptime(context.Background())
+			if err != nil {
+				t.Fatalf("GetUptime: %v", err)
+			}
+			if uptime != tt.uptime {
+				t.Errorf("uptime = %v, want %v", uptime, tt.uptime)
+			}
+			if want := now.Add(-tt.uptime); !start.Equal(want) {
+				t.Errorf("start = %v, want %v", start, want)
+			}
+		})
+	}
+}
+
+func TestGetUptimeNotSupported(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"version": "0.9.0"}`))
+	}))
+	if _, _, err := b.GetUptime(context.Background()); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("got %v, want ErrNotSupported", err)
+	}
+}
+
+func TestGetUptimeMalformed(t *testing.T) {
+	for _, body := range []string{`{"uptime": "a while"}`, `{"uptime": -5}`, `{"uptime": "-1h"}`, `{"uptime": "-90s"}`} {
+		b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}))
+		if uptime, _, err := b.GetUptime(context.Background()); err == nil || errors.Is(err, ErrNotSupported) {
+			t.Errorf("%s: got %v, %v; want a parse error", body, uptime, err)
+		}
+	}
+}
+
+func TestParseDateHeader(t *testing.T) {
+	want := time.Date(2020, 6, 1, 12, 0, 5, 0, time.UTC)
+	tests := []struct {
+		date    string
+		wantErr bool
+	}{
+		{date: "Mon, 01 Jun 2020 12:00:05 GMT"},
+		{date: "Monday, 01-Jun-20 12:00:05 GMT"},
+		{date: "Mon Jun  1 12:00:05 2020"},
+		{date: "", wantErr: true},
+		{date: "yesterday", wantErr: true},
+	}
+	for _, tt := range tests {
+		header := http.Header{}
+		if tt.date != "" {
+			header.Set("Date", tt.date)
+		}
+		got, err := parseDateHeader(header)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: err = %v, wantErr %v", tt.date, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !got.Equal(want) {
+			t.Errorf("%q: got %v, want %v", tt.date, got, want)
+		}
+	}
+}
+
+func TestServerTimeAndClockSkew(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "HEAD" {
+			t.Errorf("method = %s, want HEAD", r.Method)
+		}
+		w.Header().Set("Date", "Mon, 01 Jun 2020 12:00:05 GMT")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	fixClock(t, time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC))
+
+	serverTime, err := b.ServerTime(context.Background())
+	if err != nil {
+		t.Fatalf("ServerTime: %v", err)
+	}
+	if want := time.Date(2020, 6, 1, 12, 0, 5, 0, time.UTC); !serverTime.Equal(want) {
+		t.Errorf("ServerTime = %v, want %v", serverTime, want)
+	}
+	skew, err := b.ClockSkew(context.Background())
+	if err != nil {
+		t.Fatalf("ClockSkew: %v", err)
+	}
+	if skew != 5*time.Second {
+		t.Errorf("ClockSkew = %v, want 5s", skew)
+	}
+}
+
+func TestGetAPIVersions(t *testing.T) {
+	tests := []struct {
+		response string
+		min, max string
+		wantErr  bool
+	}{
+		{response: `{"min": "1.0", "max": "2.3.1"}`, min: "1.0", max: "2.3.1"},
+		{response: `{"min": " v1.0.0 ", "max": "2.0.0-rc.1+build.5"}`, min: "v1.0.0", max: "2.0.0-rc.1+build.5"},
+		{response: `{"min": "", "max": "2.0"}`, wantErr: true},
+		{response: `{"min": "1.x", "max": "2.0"}`, wantErr: true},
+		{response: `{"min": "3.0", "max": "2.0"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/versions" {
+				t.Errorf("unexpected path %s", r.URL.Path)
+			}
+			w.Write([]byte(tt.response))
+		}))
+		min, max, err := b.GetAPIVersions(context.Background())
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.response, err, tt.wantErr)
+			continue
+		}
+		if min != tt.min || max != tt.max {
+			t.Errorf("%s: got %q to %q, want %q to %q", tt.response, min, max, tt.min, tt.max)
+		}
+	}
+}
+
+func TestAPICompatibility(t *testing.T) {
+	tests := []struct {
+		min, max string
+		want     int
+	}{
+		{"1.0", "1.0", 0},
+		{"0.9", "1.5", 0},
+		{"1.1", "2.0", -1},
+		{"0.1", "0.9.9", 1},
+	}
+	for _, tt := range tests {
+		if got := apiCompatibility(tt.min, tt.max); got != tt.want {
+			t.Errorf("%s to %s: got %d, want %d", tt.min, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestServerInfo(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"version": "1.3.0", "features": ["webhooks", "downloads_v2"]}`))
+	}))
+	info, err := b.ServerInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != "1.3.0" || !info.HasFeature(FeatureWebhooks) || !info.HasFeature(FeatureDownloadsV2) || info.HasFeature(FeatureTags) {
+		t.Errorf("got %+v", info)
+	}
+	if !(ServerInfo{Version: "0.9.0"}).HasFeature(FeatureTags) {
+		t.Error("servers not advertising features should be assumed to have them")
+	}
+}
+
+func TestRequireFeature(t *testing.T) {
+	var infoRequests, tagRequests int
+	handler := func(info string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/api/info":
+				infoRequests++
+				if info == "" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.Write([]byte(info))
+			default:
+				tagRequests++
+			}
+		}
+	}
+	tests := []struct {
+		name    string
+		info    string
+		wantErr error
+		sent    int
+	}{
+		{"feature advertised", `{"version": "1.3.0", "features": ["tags"]}`, nil, 2},
+		{"feature missing", `{"version": "1.3.0", "features": ["webhooks"]}`, ErrNotSupported, 0},
+		{"features not advertised", `{"version": "1.0.0"}`, nil, 2},
+		{"no info endpoint", "", nil, 2},
+	}
+	for _, tt := range tests {
+		infoRequests, tagRequests = 0, 0
+		b := newTestClient(t, handler(tt.info))
+		for i := 0; i < 2; i++ {
+			if err := b.Downloads.TagDownload(context.Background(), 7, []string{"linux"}); !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: got %v, want %v", tt.name, err, tt.wantErr)
+			}
+		}
+		if infoRequests != 1 || tagRequests != tt.sent {
+			t.Errorf("%s: %d info and %d tag requests, want 1 and %d", tt.name, infoRequests, tagRequests, tt.sent)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+// service : Group of related methods of a Client, sharing its
+// configuration, session and transport
+type service struct {
+	client *Client
+}
+
+// AuthService : Methods to log in to the server, reached as Client.Auth
+type AuthService service
+
+// UsersService : Methods acting on user accounts, reached as Client.Users
+type UsersService service
+
+// DownloadsService : Methods acting on downloads and their files, reached
+// as Client.Downloads
+type DownloadsService service
+
+// AdminService : Methods for the admins of the server, such as approving
+// and blocking users or controlling the download worker, reached as
+// Client.Admin
+type AdminService service
+
+// initServices : Function to point the services of the client back at it
+func (b *Client) initServices() {
+	b.common.client = b
+	b.Auth = (*AuthService)(&b.common)
+	b.Users = (*UsersService)(&b.common)
+	b.Downloads = (*DownloadsService)(&b.common)
+	b.Admin = (*AdminService)(&b.common)
+}
@@ -0,0 +1,26 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import "testing"
+
+func TestServicesShareClient(t *testing.T) {
+	b, err := NewClient("http://localhost:5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Auth.client != b || b.Users.client != b || b.Downloads.client != b || b.Admin.client != b {
+		t.Error("services don't point back at their client")
+	}
+}
@@ -0,0 +1,155 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RecommendedSettings : Client settings advertised by the server so that
+// clients go easy on it. Zero values mean the server has no recommendation.
+type RecommendedSettings struct {
+	// PollInterval is the shortest interval watchers should poll at
+	PollInterval time.Duration
+	// RequestsPerSecond is the rate the client should keep its requests under
+	RequestsPerSecond float64
+}
+
+// GetRecommendedSettings : Function to get the client settings recommended
+// by the server. The poll interval is sent in seconds. Servers without
+// recommendations return ErrNotSupported.
+func (b *Client) GetRecommendedSettings(ctx context.Context) (RecommendedSettings, error) {
+	var body struct {
+		PollInterval      json.RawMessage `json:"poll_interval"`
+		RequestsPerSecond float64         `json:"requests_per_second"`
+	}
+	if err := b.do(ctx, "GET", "/api/settings/recommended", nil, &body); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return RecommendedSettings{}, fmt.Errorf("recommended settings: %w", ErrNotSupported)
+		}
+		return RecommendedSettings{}, err
+	}
+	var settings RecommendedSettings
+	if len(body.PollInterval) > 0 && string(body.PollInterval) != "null" {
+		interval, err := parseSeconds(body.PollInterval)
+		if err != nil {
+			return RecommendedSettings{}, fmt.Errorf("poll_interval: %v", err)
+		}
+		settings.PollInterval = interval
+	}
+	if body.RequestsPerSecond < 0 {
+		return RecommendedSettings{}, fmt.Errorf("requests_per_second: negative rate %v", body.RequestsPerSecond)
+	}
+	settings.RequestsPerSecond = body.RequestsPerSecond
+	return settings, nil
+}
+
+// ApplyRecommended : Function to configure the client with the settings
+// recommended by the server: requests are spaced out to stay under its rate
+// and watchers never poll faster than its interval. Settings the server
+// doesn't recommend are left as they are.
+func (b *Client) ApplyRecommended(ctx context.Context) error {
+	settings, err := b.GetRecommendedSettings(ctx)
+	if err != nil {
+		return err
+	}
+	if settings.RequestsPerSecond > 0 {
+		b.limiter.setRate(settings.RequestsPerSecond)
+	}
+	if settings.PollInterval > 0 {
+		b.mu.Lock()
+		b.minPollInterval = settings.PollInterval
+		b.mu.Unlock()
+	}
+	return nil
+}
+
+// pollInterval : Function to get the interval a watcher polls at, interval
+// raised to the minimum set by ApplyRecommended
+func (b *Client) pollInterval(interval time.Duration) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if interval < b.minPollInterval {
+		return b.minPollInterval
+	}
+	return interval
+}
+
+// WithRateLimit : Option to space requests out so that the client sends at
+// most requestsPerSecond of them, waiting before a request rather than
+// failing it. 0 means no limit.
+func WithRateLimit(requestsPerSecond float64) Option {
+	if requestsPerSecond < 0 {
+		return failOption(invalidParam("requestsPerSecond", "must not be negative"))
+	}
+	return func(b *Client) {
+		b.limiter.setRate(requestsPerSecond)
+	}
+}
+
+// rateLimiter : Limiter handing out evenly spaced slots for requests. The
+// zero value doesn't limit.
+type rateLimiter struct {
+	mu sync.Mutex
+	// interval is the spacing between requests, 0 for no limit
+	interval time.Duration
+	// next is the earliest time the next request may go out
+	next time.Time
+}
+
+// setRate : Function to change the number of requests allowed per second,
+// 0 or less lifting the limit
+func (l *rateLimiter) setRate(perSecond float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if perSecond <= 0 {
+		l.interval = 0
+		return
+	}
+	l.interval = time.Duration(float64(time.Second) / perSecond)
+}
+
+// wait : Function to block until the request may be sent or ctx ends
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	if l.interval == 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	now := timeNow()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := slot.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,107 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestGetRecommendedSettings(t *testing.T) {
+	tests := []struct {
+		body    string
+		want    RecommendedSettings
+		wantErr bool
+	}{
+		{body: `{"poll_interval": 5, "requests_per_second": 20}`, want: RecommendedSettings{PollInterval: 5 * time.Second, RequestsPerSecond: 20}},
+		{body: `{"poll_interval": "1.5"}`, want: RecommendedSettings{PollInterval: 1500 * time.Millisecond}},
+		{body: `{}`},
+		{body: `{"poll_interval": "soon"}`, wantErr: true},
+		{body: `{"requests_per_second": -1}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/settings/recommended" {
+				t.Errorf("path = %s, want /api/settings/recommended", r.URL.Path)
+			}
+			w.Write([]byte(tt.body))
+		}))
+		settings, err := b.GetRecommendedSettings(context.Background())
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.body, err, tt.wantErr)
+			continue
+		}
+		if settings != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.body, settings, tt.want)
+		}
+	}
+}
+
+func TestGetRecommendedSettingsNotSupported(t *testing.T) {
+	b := newTestClient(t, http.NotFoundHandler())
+	if _, err := b.GetRecommendedSettings(context.Background()); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("got %v, want ErrNotSupported", err)
+	}
+	if err := b.ApplyRecommended(context.Background()); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("ApplyRecommended = %v, want ErrNotSupported", err)
+	}
+}
+
+func TestApplyRecommended(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"poll_interval": 5, "requests_per_second": 20}`))
+	}))
+	if err := b.ApplyRecommended(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if b.limiter.interval != 50*time.Millisecond {
+		t.Errorf("request spacing = %v, want 50ms", b.limiter.interval)
+	}
+	if got := b.pollInterval(time.Second); got != 5*time.Second {
+		t.Errorf("1s poll interval = %v, want raised to 5s", got)
+	}
+	if got := b.pollInterval(time.Minute); got != time.Minute {
+		t.Errorf("1m poll interval = %v, want kept", got)
+	}
+}
+
+func TestWithRateLimit(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), WithRateLimit(50))
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := b.Admin.PauseAll(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first request goes out right away, the others 20ms apart
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("5 requests took %v, want at least 80ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b.limiter.setRate(0.1)
+	b.limiter.wait(context.Background())
+	if err := b.Admin.PauseAll(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("waiting with a cancelled ctx: got %v, want context.Canceled", err)
+	}
+
+	b = newTestClient(t, http.NotFoundHandler(), WithRateLimit(-1))
+	if err := b.Admin.PauseAll(context.Background()); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("negative rate: got %v, want ErrInvalidParams", err)
+	}
+}
@@ -0,0 +1,106 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestWithRequestSigning(t *testing.T) {
+	secret := []byte("shared-secret")
+	var signatures []string
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature := r.Header.Get("X-Signature")
+		signatures = append(signatures, signature)
+
+		// Verify the way a server would, from the documented canonical form
+		body, _ := ioutil.ReadAll(r.Body)
+		bodyHash := sha256.Sum256(body)
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(r.Method + "\n" + r.URL.RequestURI() + "\n" + hex.EncodeToString(bodyHash[:])))
+		if want := hex.EncodeToString(mac.Sum(nil)); signature != want {
+			t.Errorf("%s %s: signature = %q, want %q", r.Method, r.URL, signature, want)
+		}
+		w.Header().Set("token", "session")
+	}), WithRequestSigning(secret, "X-Signature"))
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := b.Downloads.RenameDownload(ctx, 3, "debian.iso"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Downloads.RenameDownload(ctx, 3, "ubuntu.iso"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Admin.PauseAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Logins and the legacy methods are signed as well
+	if err := b.Auth.Login(context.Background(), "rand", "secret"); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if _, err := b.Auth.LoginWith2FA(ctx, "rand", "secret", "123456"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Users.AddRegularUserRequest(context.Background(), "rand", "secretpass", "rand@example.com"); err != nil {
+		t.Fatalf("AddRegularUserRequest: %v", err)
+	}
+	if len(signatures) != 7 || signatures[0] == "" {
+		t.Fatalf("signatures = %q", signatures)
+	}
+	if signatures[0] != signatures[1] {
+		t.Error("identical requests were signed differently")
+	}
+	if signatures[1] == signatures[2] || signatures[2] == signatures[3] {
+		t.Error("different requests share a signature")
+	}
+}
+
+func TestWithRequestSigningDefaults(t *testing.T) {
+	var signature string
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get("X-Bassa-Signature")
+	}), WithRequestSigning([]byte("k"), ""))
+	if err := b.Admin.PauseAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if signature == "" {
+		t.Error("no X-Bassa-Signature header")
+	}
+	b = newTestClient(t, http.NotFoundHandler(), WithRequestSigning(nil, "X-Signature"))
+	if err := b.Admin.PauseAll(context.Background()); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("empty secret: got %v, want ErrInvalidParams", err)
+	}
+	if err := b.Users.AddRegularUserRequest(context.Background(), "rand", "secretpass", "rand@example.com"); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("empty secret, legacy call: got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestSignRequestKnownValue(t *testing.T) {
+	request, _ := http.NewRequest("GET", "http://localhost/api/downloads?limit=1", nil)
+	got := signRequest([]byte("key"), "GET", request.URL, nil)
+	// printf 'GET\n/api/downloads?limit=1\ne3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855' | openssl dgst -sha256 -hmac key
+	const want = "700d00342e3a47dd0a0b17c1e6c6ef606de3506d1b3a017808bbe6b7161907cd"
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,69 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import "context"
+
+// Statistics : Aggregates over a download listing
+type Statistics struct {
+	Total    int
+	ByStatus map[DownloadStatus]int
+	// Active counts the downloads currently transferring
+	Active int
+	// TotalBytes is the combined size of every download
+	TotalBytes int64
+	// RemainingBytes is what the queued and downloading ones still have to
+	// fetch; failed downloads don't count as they won't progress on their own
+	RemainingBytes int64
+}
+
+// ComputeStatistics : Function to aggregate a download listing the caller
+// already fetched, for servers without a statistics endpoint. It makes no
+// request of its own.
+func ComputeStatistics(downloads []Download) Statistics {
+	stats := Statistics{Total: len(downloads), ByStatus: map[DownloadStatus]int{}}
+	for _, download := range downloads {
+		stats.ByStatus[download.Status]++
+		stats.TotalBytes += download.Size
+		switch download.Status {
+		case StatusDownloading:
+			stats.Active++
+			fallthrough
+		case StatusQueued:
+			if left := download.Size - download.Downloaded; left > 0 {
+				stats.RemainingBytes += left
+			}
+		}
+	}
+	return stats
+}
+
+// UsageData : Function to fetch every download and the ranking of the
+// heaviest users, the input of analytics.AnalyzeUsage. The downloads are
+// decoded as they arrive, but all of them are kept in memory.
+func (s *AdminService) UsageData(ctx context.Context) ([]Download, []HeavyUser, error) {
+	var downloads []Download
+	err := s.client.Downloads.EachDownload(ctx, DownloadFilter{}, func(download Download) error {
+		downloads = append(downloads, download)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	heavy, err := s.GetToptenHeaviestUsers(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return downloads, heavy, nil
+}
@@ -0,0 +1,95 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestComputeStatistics(t *testing.T) {
+	tests := []struct {
+		name      string
+		downloads []Download
+		want      Statistics
+	}{
+		{
+			name: "empty",
+			want: Statistics{ByStatus: map[DownloadStatus]int{}},
+		},
+		{
+			name: "mixed",
+			downloads: []Download{
+				{Status: StatusQueued, Size: 500},
+				{Status: StatusDownloading, Size: 1000, Downloaded: 400},
+				{Status: StatusDownloading, Size: 200, Downloaded: 250},
+				{Status: StatusCompleted, Size: 300, Downloaded: 300},
+				{Status: StatusFailed, Size: 800, Downloaded: 100},
+			},
+			want: Statistics{
+				Total: 5,
+				ByStatus: map[DownloadStatus]int{
+					StatusQueued: 1, StatusDownloading: 2, StatusCompleted: 1, StatusFailed: 1,
+				},
+				Active:         2,
+				TotalBytes:     2800,
+				RemainingBytes: 1100,
+			},
+		},
+		{
+			name: "all complete",
+			downloads: []Download{
+				{Status: StatusCompleted, Size: 10, Downloaded: 10},
+				{Status: StatusCompleted, Size: 20, Downloaded: 20},
+			},
+			want: Statistics{
+				Total:      2,
+				ByStatus:   map[DownloadStatus]int{StatusCompleted: 2},
+				TotalBytes: 30,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ComputeStatistics(tt.downloads); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsageData(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/downloads":
+			w.Write([]byte(`[{"id": 1, "user_name": "rand", "download_name": "a.zip", "status": "completed", "size": 10}]`))
+		case "/api/user/heavy":
+			w.Write([]byte(`[{"user_name": "rand", "size": 10}]`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+	downloads, heavy, err := b.Admin.UsageData(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(downloads) != 1 || downloads[0].Name != "a.zip" {
+		t.Errorf("downloads = %+v", downloads)
+	}
+	if want := []HeavyUser{{UserName: "rand", Size: 10}}; !reflect.DeepEqual(heavy, want) {
+		t.Errorf("heavy users = %+v, want %+v", heavy, want)
+	}
+}
@@ -0,0 +1,160 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// EachDownload : Function to call fn with every download matching filter,
+// decoding the response as it arrives so that memory stays flat on large
+// listings. An error from fn stops the listing and is returned.
+func (s *DownloadsService) EachDownload(ctx context.Context, filter DownloadFilter, fn func(Download) error) error {
+	query, err := filter.Query()
+	if err != nil {
+		return err
+	}
+	return eachItem(ctx, s.client, withQuery("/api/downloads", query), fn)
+}
+
+// EachUser : Function to call fn with every user matching filter, decoding
+// the response as it arrives like EachDownload
+func (s *UsersService) EachUser(ctx context.Context, filter UserFilter, fn func(User) error) error {
+	query, err := filter.Query()
+	if err != nil {
+		return err
+	}
+	return eachItem(ctx, s.client, withQuery("/api/user", query), fn)
+}
+
+// eachItem : Function to GET a list endpoint and decode its items one at a
+// time, calling fn with each. Only one item is held in memory at once.
+func eachItem[T any](ctx context.Context, b *Client, endpoint string, fn func(T) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	response, err := b.openListing(ctx, cancel, endpoint)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	decoder := json.NewDecoder(response.Body)
+	items, err := openList(decoder)
+	if err != nil {
+		return fmt.Errorf("decoding response of GET %s: %w", endpoint, err)
+	}
+	if !items {
+		return nil
+	}
+	for decoder.More() {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("decoding response of GET %s: %w", endpoint, err)
+		}
+		var item T
+		if err := b.decodeValue(raw, &item); err != nil {
+			return fmt.Errorf("decoding response of GET %s: %w", endpoint, err)
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	// A truncated response lacks the closing bracket
+	if _, err := decoder.Token(); err != nil {
+		return fmt.Errorf("decoding response of GET %s: %w", endpoint, err)
+	}
+	return nil
+}
+
+// openListing : Function to GET a listing to be read as it arrives. The
+// client timeout only bounds the wait for the response, calling cancel
+// when it runs out, so that large listings aren't cut off in transfer; a
+// timeout set with WithRequestTimeout still bounds the whole call.
+func (b *Client) openListing(ctx context.Context, cancel context.CancelFunc, endpoint string) (*http.Response, error) {
+	client, ok := b.doer.(*http.Client)
+	if !ok || client.Timeout == 0 || requestOptionsFrom(ctx).timeout > 0 {
+		return b.roundTrip(ctx, b.clientFor(ctx), "GET", endpoint, nil, nil)
+	}
+	timer := time.AfterFunc(client.Timeout, cancel)
+	defer timer.Stop()
+	return b.roundTrip(ctx, newHTTPClient(b.unboundedDoer(), b.retriesFor(ctx)), "GET", endpoint, nil, nil)
+}
+
+// openList : Helper function to advance decoder to the first item of a list
+// response, either a bare array or an array in the data of an envelope,
+// reporting whether there are items to read. Empty and null responses have
+// none.
+func openList(decoder *json.Decoder) (bool, error) {
+	token, err := decoder.Token()
+	if err == io.EOF || (err == nil && token == nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	switch token {
+	case json.Delim('['):
+		return true, nil
+	case json.Delim('{'):
+	default:
+		return false, fmt.Errorf("expected a list, got %v", token)
+	}
+
+	var env envelope
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return false, err
+		}
+		switch key {
+		case "data":
+			if err := envelopeError(env); err != nil {
+				return false, err
+			}
+			token, err := decoder.Token()
+			if err != nil {
+				return false, err
+			}
+			if token == nil {
+				return false, nil
+			}
+			if token != json.Delim('[') {
+				return false, fmt.Errorf("expected a list in the envelope data, got %v", token)
+			}
+			return true, nil
+		case "status":
+			err = decoder.Decode(&env.Status)
+		case "message":
+			err = decoder.Decode(&env.Message)
+		default:
+			return false, fmt.Errorf("expected a list, got an object with key %v", key)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	if err := envelopeError(env); err != nil {
+		return false, err
+	}
+	return false, errors.New("expected a list, got an object without data")
+}
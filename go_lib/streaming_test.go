@@ -0,0 +1,121 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEachDownload(t *testing.T) {
+	const total = 2000
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.RawQuery != "status=completed" {
+			t.Errorf("query = %q", r.URL.RawQuery)
+		}
+		encoder := json.NewEncoder(w)
+		w.Write([]byte("["))
+		for i := 1; i <= total; i++ {
+			if i > 1 {
+				w.Write([]byte(","))
+			}
+			encoder.Encode(Download{ID: i, Status: StatusCompleted})
+		}
+		w.Write([]byte("]"))
+	}))
+	count := 0
+	err := b.Downloads.EachDownload(context.Background(), DownloadFilter{}.Status(StatusCompleted), func(download Download) error {
+		count++
+		if download.ID != count {
+			return fmt.Errorf("got download %d, want %d", download.ID, count)
+		}
+		return nil
+	})
+	if err != nil || count != total {
+		t.Errorf("got %d downloads, %v; want %d", count, err, total)
+	}
+}
+
+func TestEachUser(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+		err  string
+	}{
+		{"array", `[{"user_name": "rand"}, {"user_name": "mat", "auth": 1}]`, []string{"rand", "mat"}, ""},
+		{"envelope", `{"status": "success", "message": "ok", "data": [{"user_name": "rand"}]}`, []string{"rand"}, ""},
+		{"empty", ``, nil, ""},
+		{"null data", `{"data": null}`, nil, ""},
+		{"error envelope", `{"status": "error", "message": "db down", "data": []}`, nil, "server reported error: db down"},
+		{"object", `{"user_name": "rand"}`, nil, "expected a list"},
+		{"truncated", `[{"user_name": "rand"}, {"user_na`, []string{"rand"}, "unexpected EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.body))
+			}))
+			var names []string
+			err := b.Users.EachUser(context.Background(), UserFilter{}, func(user User) error {
+				names = append(names, user.UserName)
+				return nil
+			})
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("got %v, want %q", err, tt.err)
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("got %q, want %q", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestEachUserStops(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"user_name": "rand"}, {"user_name": "mat"}, {"user_name": "kmehant"}]`))
+	}))
+	stop := errors.New("stop")
+	var names []string
+	err := b.Users.EachUser(context.Background(), UserFilter{}, func(user User) error {
+		if names = append(names, user.UserName); len(names) == 2 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || len(names) != 2 {
+		t.Errorf("got %q, %v; want two users and the callback error", names, err)
+	}
+	if err := b.Users.EachUser(context.Background(), UserFilter{}.Limit(0), func(User) error { return nil }); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("invalid filter: got %v", err)
+	}
+}
+
+func TestEachUserTimeout(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}), WithTimeout(50*time.Millisecond), WithRetryCount(0))
+	start := time.Now()
+	err := b.Users.EachUser(context.Background(), UserFilter{}, func(User) error { return nil })
+	if err == nil || time.Since(start) > 2*time.Second {
+		t.Errorf("got %v after %v, want the client timeout to apply", err, time.Since(start))
+	}
+}
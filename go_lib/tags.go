@@ -0,0 +1,114 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// tagConcurrency : Number of downloads TagDownloadsMatching tags at once
+const tagConcurrency = 4
+
+// TagDownload : Function to add tags to a download, keeping the ones it
+// already has
+func (s *DownloadsService) TagDownload(ctx context.Context, downloadID int, tags []string) error {
+	if downloadID <= 0 {
+		return invalidParam("downloadID", "must be positive")
+	}
+	tags, err := cleanTags(tags)
+	if err != nil {
+		return err
+	}
+	if err := s.client.requireFeature(ctx, FeatureTags); err != nil {
+		return err
+	}
+	return s.client.tagDownload(ctx, downloadID, tags)
+}
+
+func (b *Client) tagDownload(ctx context.Context, downloadID int, tags []string) error {
+	body := map[string][]string{"tags": tags}
+	return b.do(ctx, "POST", downloadEndpoint(downloadID, "tags"), body, nil)
+}
+
+// TagDownloadsMatching : Function to add tags to every download matching
+// filter, returning how many were tagged. Up to 4 downloads are tagged at
+// once; a failure doesn't stop the others, and the failures are returned
+// joined, each naming its download.
+func (s *DownloadsService) TagDownloadsMatching(ctx context.Context, filter DownloadFilter, tags []string) (int, error) {
+	tags, err := cleanTags(tags)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := filter.Query(); err != nil {
+		return 0, err
+	}
+	if err := s.client.requireFeature(ctx, FeatureTags); err != nil {
+		return 0, err
+	}
+	downloads, err := s.client.Downloads.GetDownloadsFiltered(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	tagged := 0
+	slots := make(chan struct{}, tagConcurrency)
+	for _, download := range downloads {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(downloadID int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			err := s.client.tagDownload(ctx, downloadID, tags)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("download %d: %w", downloadID, err))
+			} else {
+				tagged++
+			}
+		}(download.ID)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return tagged, ctx.Err()
+	}
+	return tagged, errors.Join(errs...)
+}
+
+// cleanTags : Helper function to trim tags, rejecting an empty list or tag
+func cleanTags(tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, invalidParam("tags", "must not be empty")
+	}
+	cleaned := make([]string, len(tags))
+	for i, tag := range tags {
+		if cleaned[i] = strings.TrimSpace(tag); cleaned[i] == "" {
+			return nil, invalidParam("tags", "must not contain an empty tag")
+		}
+	}
+	return cleaned, nil
+}
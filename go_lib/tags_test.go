@@ -0,0 +1,109 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTagDownloadsMatching(t *testing.T) {
+	var mu sync.Mutex
+	tagged := map[string][]string{}
+	active, maxActive := 0, 0
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/info" {
+			w.Write([]byte(`{"version": "1.2.0", "features": ["tags"]}`))
+			return
+		}
+		if r.Method == "GET" && r.URL.Path == "/api/downloads" {
+			if status := r.URL.Query().Get("status"); status != "completed" {
+				t.Errorf("status filter = %q, want completed", status)
+			}
+			w.Write([]byte(`[{"id": 1}, {"id": 2}, {"id": 3}, {"id": 4}, {"id": 5}, {"id": 6}, {"id": 7}]`))
+			return
+		}
+		if r.Method != "POST" || !strings.HasSuffix(r.URL.Path, "/tags") {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			return
+		}
+		mu.Lock()
+		if active++; active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+
+		if r.URL.Path == "/api/download/3/tags" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var body struct {
+			Tags []string `json:"tags"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		tagged[r.URL.Path] = body.Tags
+		mu.Unlock()
+	}))
+
+	n, err := b.Downloads.TagDownloadsMatching(context.Background(), DownloadFilter{}.Status(StatusCompleted), []string{" archive ", "2020"})
+	if n != 6 {
+		t.Errorf("tagged %d downloads, want 6", n)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError || !strings.Contains(err.Error(), "download 3") {
+		t.Errorf("got %v, want the failure of download 3", err)
+	}
+	var paths []string
+	for path, tags := range tagged {
+		paths = append(paths, path)
+		if !reflect.DeepEqual(tags, []string{"archive", "2020"}) {
+			t.Errorf("%s: tags = %q", path, tags)
+		}
+	}
+	sort.Strings(paths)
+	if len(paths) != 6 || paths[2] != "/api/download/4/tags" {
+		t.Errorf("tagged %q", paths)
+	}
+	if maxActive > tagConcurrency {
+		t.Errorf("%d downloads tagged at once, want at most %d", maxActive, tagConcurrency)
+	}
+}
+
+func TestTagDownloadsValidation(t *testing.T) {
+	b := newOfflineClient()
+	for _, tags := range [][]string{nil, {"ok", " "}} {
+		if _, err := b.Downloads.TagDownloadsMatching(context.Background(), DownloadFilter{}, tags); !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("%q: got %v, want ErrInvalidParams", tags, err)
+		}
+	}
+	if err := b.Downloads.TagDownload(context.Background(), 0, []string{"x"}); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("TagDownload(0) = %v, want ErrInvalidParams", err)
+	}
+	if _, err := b.Downloads.TagDownloadsMatching(context.Background(), DownloadFilter{}.Status("bogus"), []string{"x"}); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("invalid filter: got %v, want ErrInvalidParams", err)
+	}
+}
@@ -0,0 +1,80 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Tracer : Source of the spans recorded by WithTracer. It is kept minimal so
+// that the client doesn't depend on OpenTelemetry; an adapter over a
+// trace.Tracer and the global propagator takes a few lines:
+//
+//	func (t otelTracer) Start(ctx context.Context, name string) (context.Context, bassa.Span) {
+//		ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+//		return ctx, otelSpan{span}
+//	}
+//
+//	func (t otelTracer) Inject(ctx context.Context, header http.Header) {
+//		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+//	}
+type Tracer interface {
+	// Start begins a span, returning a context carrying it
+	Start(ctx context.Context, name string) (context.Context, Span)
+	// Inject adds the trace context of ctx to outgoing request headers
+	Inject(ctx context.Context, header http.Header)
+}
+
+// Span : Span started by a Tracer
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// WithTracer : Option to record a span for every request, logins and streams
+// included, named after the method and carrying http.method, bassa.endpoint
+// and http.status_code attributes, and to propagate the trace context to the
+// server. A span ends once the response headers arrive, so it doesn't cover
+// reading a stream or a download.
+func WithTracer(tracer Tracer) Option {
+	return func(b *Client) {
+		b.tracer = tracer
+	}
+}
+
+// startSpan : Helper function to begin the span of a call. The endpoint
+// attribute leaves out the query to keep its cardinality low.
+func startSpan(ctx context.Context, tracer Tracer, method string, endpoint string) (context.Context, Span) {
+	path := strings.SplitN(endpoint, "?", 2)[0]
+	ctx, span := tracer.Start(ctx, "bassa "+method)
+	span.SetAttribute("http.method", method)
+	span.SetAttribute("bassa.endpoint", path)
+	return ctx, span
+}
+
+// endSpan : Helper function to finish the span of a call with its outcome
+func endSpan(span Span, err error) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		span.SetAttribute("http.status_code", apiErr.StatusCode)
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
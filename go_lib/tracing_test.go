@@ -0,0 +1,128 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// recordedSpan : Span kept by recordingTracer
+type recordedSpan struct {
+	name       string
+	id         int
+	attributes map[string]interface{}
+	err        error
+	ended      bool
+}
+
+func (s *recordedSpan) SetAttribute(key string, value interface{}) { s.attributes[key] = value }
+func (s *recordedSpan) RecordError(err error)                      { s.err = err }
+func (s *recordedSpan) End()                                       { s.ended = true }
+
+// recordingTracer : Tracer keeping every span, propagating span ids in a
+// Trace-Id header
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+type recordedSpanKey struct{}
+
+func (r *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	span := &recordedSpan{name: name, id: len(r.spans) + 1, attributes: map[string]interface{}{}}
+	r.spans = append(r.spans, span)
+	return context.WithValue(ctx, recordedSpanKey{}, span), span
+}
+
+func (r *recordingTracer) Inject(ctx context.Context, header http.Header) {
+	if span, ok := ctx.Value(recordedSpanKey{}).(*recordedSpan); ok {
+		header.Set("Trace-Id", strconv.Itoa(span.id))
+	}
+}
+
+func TestWithTracer(t *testing.T) {
+	var traceIDs []string
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceIDs = append(traceIDs, r.Header.Get("Trace-Id"))
+		if r.URL.Path == "/api/download/2/logs" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`[]`))
+	}), WithTracer(&recordingTracer{}))
+	tracer := b.tracer.(*recordingTracer)
+
+	b.Admin.PauseAll(context.Background())
+	b.Downloads.GetDownloadsFiltered(context.Background(), DownloadFilter{}.Status(StatusQueued))
+	if _, err := b.Downloads.GetDownloadLogs(context.Background(), 2); err == nil {
+		t.Fatal("GetDownloadLogs succeeded against a 404")
+	}
+
+	if len(tracer.spans) != 3 {
+		t.Fatalf("got %d spans, want one per request", len(tracer.spans))
+	}
+	want := []struct {
+		name     string
+		endpoint string
+		status   int
+	}{
+		{"bassa POST", "/api/downloads/pause", 200},
+		{"bassa GET", "/api/downloads", 200},
+		{"bassa GET", "/api/download/2/logs", 404},
+	}
+	for i, span := range tracer.spans {
+		if span.name != want[i].name || span.attributes["bassa.endpoint"] != want[i].endpoint ||
+			span.attributes["http.status_code"] != want[i].status || !span.ended {
+			t.Errorf("span %d = %+v, want %+v", i, span, want[i])
+		}
+		if traceIDs[i] != strconv.Itoa(span.id) {
+			t.Errorf("request %d: Trace-Id = %q, want %d", i, traceIDs[i], span.id)
+		}
+	}
+	if tracer.spans[2].err == nil || tracer.spans[0].err != nil {
+		t.Error("errors not recorded on the failed span only")
+	}
+}
+
+func TestWithTracerLoginAndStream(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("token", "t")
+	}), WithTracer(&recordingTracer{}))
+	tracer := b.tracer.(*recordingTracer)
+
+	if err := b.Auth.Login(context.Background(), "rand", "secret"); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	response, err := b.stream(context.Background(), "GET", "/api/activity", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if len(tracer.spans) != 2 {
+		t.Fatalf("got %d spans, want one per request", len(tracer.spans))
+	}
+	for i, endpoint := range []string{"/api/login", "/api/activity"} {
+		span := tracer.spans[i]
+		if span.attributes["bassa.endpoint"] != endpoint || span.attributes["http.status_code"] != 200 || !span.ended {
+			t.Errorf("span %d = %+v, want an ended span for %s", i, span, endpoint)
+		}
+	}
+}
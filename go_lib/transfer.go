@@ -0,0 +1,163 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// minChunkSize : Parallel downloads never split a file into chunks smaller
+// than this, as the extra requests would cost more than they gain
+const minChunkSize = 16 << 10
+
+// errRangeIgnored : A ranged request was answered with the whole file
+var errRangeIgnored = errors.New("server ignored the range request")
+
+// offsetWriter : Writer writing sequentially into a WriterAt from an offset
+type offsetWriter struct {
+	w      io.WriterAt
+	offset int64
+}
+
+func (o *offsetWriter) Write(p []byte) (int, error) {
+	n, err := o.w.WriteAt(p, o.offset)
+	o.offset += int64(n)
+	return n, err
+}
+
+// DownloadFileParallel : Function to fetch the file of a completed download
+// into w with up to chunks concurrent ranged requests, which is faster over
+// high-latency links. Chunks are at least 16 KiB, so small files take fewer
+// requests, and servers not supporting ranges get a single request.
+// It returns the number of bytes written; on error w may be partly written.
+func (s *DownloadsService) DownloadFileParallel(ctx context.Context, downloadID int, w io.WriterAt, chunks int) (int64, error) {
+	if downloadID <= 0 {
+		return 0, invalidParam("downloadID", "must be positive")
+	}
+	if w == nil {
+		return 0, invalidParam("w", "must not be nil")
+	}
+	if chunks <= 0 {
+		return 0, invalidParam("chunks", "must be positive")
+	}
+	endpoint := downloadEndpoint(downloadID, "file")
+	response, err := s.client.roundTrip(ctx, s.client.clientFor(ctx), "HEAD", endpoint, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	response.Body.Close()
+	size := response.ContentLength
+	if maxChunks := (size + minChunkSize - 1) / minChunkSize; int64(chunks) > maxChunks {
+		chunks = int(maxChunks)
+	}
+	if chunks <= 1 || response.Header.Get("Accept-Ranges") != "bytes" {
+		return s.client.downloadRange(ctx, endpoint, w, 0, -1)
+	}
+
+	chunkSize := (size + int64(chunks) - 1) / int64(chunks)
+	written, err := s.client.downloadChunks(ctx, endpoint, w, size, chunkSize, chunks, nil)
+	if errors.Is(err, errRangeIgnored) {
+		return s.client.downloadRange(ctx, endpoint, w, 0, -1)
+	}
+	return written, err
+}
+
+// downloadChunks : Function to fetch size bytes as ranges of chunkSize
+// bytes, at most parallelism at a time. The first failure cancels the other
+// ranges. progress, when not nil, counts the bytes written.
+func (b *Client) downloadChunks(ctx context.Context, endpoint string, w io.WriterAt, size int64, chunkSize int64, parallelism int, progress *progress) (int64, error) {
+	chunkCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	slots := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var written int64
+	var firstErr error
+	for start := int64(0); start < size && chunkCtx.Err() == nil; start += chunkSize {
+		end := start + chunkSize - 1
+		if end >= size {
+			end = size - 1
+		}
+		select {
+		case slots <- struct{}{}:
+		case <-chunkCtx.Done():
+			continue
+		}
+		wg.Add(1)
+		go func(start, end int64) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			n, err := b.downloadRange(chunkCtx, endpoint, progressWriterAt{w: w, progress: progress}, start, end)
+			mu.Lock()
+			defer mu.Unlock()
+			written += n
+			if err != nil && firstErr == nil {
+				firstErr = err
+				cancel()
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return written, ctx.Err()
+	}
+	return written, firstErr
+}
+
+// progressWriterAt : WriterAt counting what goes through it towards a
+// progress, see progressWriter
+type progressWriterAt struct {
+	w        io.WriterAt
+	progress *progress
+}
+
+func (pw progressWriterAt) WriteAt(b []byte, off int64) (int, error) {
+	n, err := pw.w.WriteAt(b, off)
+	pw.progress.add(int64(n))
+	return n, err
+}
+
+// downloadRange : Function to fetch bytes start to end, both inclusive, into
+// w at their offset. end -1 fetches the whole file.
+func (b *Client) downloadRange(ctx context.Context, endpoint string, w io.WriterAt, start, end int64) (int64, error) {
+	var header http.Header
+	if end >= 0 {
+		header = http.Header{"Range": {"bytes=" + strconv.FormatInt(start, 10) + "-" + strconv.FormatInt(end, 10)}}
+	}
+	response, err := b.stream(ctx, "GET", endpoint, header)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	if end < 0 {
+		return io.Copy(&offsetWriter{w: w, offset: start}, response.Body)
+	}
+	if response.StatusCode != http.StatusPartialContent {
+		return 0, errRangeIgnored
+	}
+	want := end - start + 1
+	n, err := io.Copy(&offsetWriter{w: w, offset: start}, io.LimitReader(response.Body, want))
+	if err == nil && n < want {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
@@ -0,0 +1,143 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memWriterAt : WriterAt growing an in-memory buffer as needed
+type memWriterAt struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+func (m *memWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if end := int(off) + len(p); end > len(m.data) {
+		m.data = append(m.data, make([]byte, end-len(m.data))...)
+	}
+	return copy(m.data[off:], p), nil
+}
+
+// fileServer : Handler serving content as the file of download 5, counting
+// the ranged GETs it receives
+func fileServer(t *testing.T, content []byte, ranges bool, rangedGets *int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/download/5/file" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Method == "GET" && r.Header.Get("Range") != "" {
+			atomic.AddInt32(rangedGets, 1)
+		}
+		if !ranges {
+			r.Header.Del("Range")
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write(content)
+			return
+		}
+		http.ServeContent(w, r, "file.bin", time.Time{}, bytes.NewReader(content))
+	}
+}
+
+func TestDownloadFileParallel(t *testing.T) {
+	content := make([]byte, 100003)
+	rand.New(rand.NewSource(1)).Read(content)
+	for _, chunks := range []int{1, 4, 7, 200000} {
+		var rangedGets int32
+		b := newTestClient(t, fileServer(t, content, true, &rangedGets))
+		out := &memWriterAt{}
+		n, err := b.Downloads.DownloadFileParallel(context.Background(), 5, out, chunks)
+		if err != nil {
+			t.Fatalf("%d chunks: %v", chunks, err)
+		}
+		if n != int64(len(content)) || !bytes.Equal(out.data, content) {
+			t.Errorf("%d chunks: wrote %d bytes, content differs", chunks, n)
+		}
+		// 100003 bytes make at most 7 chunks of 16 KiB
+		wantGets := int32(chunks)
+		if chunks == 1 {
+			wantGets = 0
+		} else if chunks > 7 {
+			wantGets = 7
+		}
+		if rangedGets != wantGets {
+			t.Errorf("%d chunks: %d ranged GETs, want %d", chunks, rangedGets, wantGets)
+		}
+	}
+}
+
+func TestDownloadFileParallelWithoutRanges(t *testing.T) {
+	content := []byte(strings.Repeat("bassa", 10000))
+	var rangedGets int32
+	b := newTestClient(t, fileServer(t, content, false, &rangedGets))
+	out := &memWriterAt{}
+	n, err := b.Downloads.DownloadFileParallel(context.Background(), 5, out, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(content)) || !bytes.Equal(out.data, content) {
+		t.Errorf("wrote %d bytes, content differs", n)
+	}
+	if rangedGets != 0 {
+		t.Errorf("%d ranged GETs to a server without range support", rangedGets)
+	}
+}
+
+func TestDownloadFileParallelRangeIgnored(t *testing.T) {
+	// Advertises ranges but answers every GET with the whole file
+	content := []byte(strings.Repeat("0123456789", 5000))
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("Content-Length", "50000")
+		if r.Method == "GET" {
+			w.Write(content)
+		}
+	}))
+	out := &memWriterAt{}
+	n, err := b.Downloads.DownloadFileParallel(context.Background(), 5, out, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(content)) || !bytes.Equal(out.data, content) {
+		t.Errorf("wrote %d bytes, content differs", n)
+	}
+}
+
+func TestDownloadFileParallelErrors(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	if _, err := b.Downloads.DownloadFileParallel(context.Background(), 5, &memWriterAt{}, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+	for _, chunks := range []int{0, -1} {
+		if _, err := b.Downloads.DownloadFileParallel(context.Background(), 5, &memWriterAt{}, chunks); !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("%d chunks: got %v, want ErrInvalidParams", chunks, err)
+		}
+	}
+	if _, err := b.Downloads.DownloadFileParallel(context.Background(), 5, nil, 2); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("nil writer: got %v, want ErrInvalidParams", err)
+	}
+}
@@ -0,0 +1,536 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// User : Bassa user account
+type User struct {
+	UserName  string    `json:"user_name"`
+	Email     string    `json:"email"`
+	AuthLevel AuthLevel `json:"auth_level"`
+	// Verified reports whether the user confirmed their email address
+	Verified bool `json:"verified"`
+}
+
+// CreateUserInput : Account created by AddUserRequest
+type CreateUserInput struct {
+	UserName  string    `json:"user_name"`
+	Password  string    `json:"password"`
+	Email     string    `json:"email"`
+	AuthLevel AuthLevel `json:"auth_level"`
+}
+
+// validate : Function to check the fields of the account against rules
+func (in CreateUserInput) validate(rules ValidationRules) ValidationErrors {
+	errs := rules.validateUser("userName", in.UserName, in.Password, in.Email)
+	if !in.AuthLevel.valid() {
+		errs = append(errs, &ValidationError{Field: "authLevel", Reason: "has unknown value " + strconv.Itoa(int(in.AuthLevel))})
+	}
+	return errs
+}
+
+// UpdateUserInput : New fields of an account for UpdateUserRequest, all of
+// them required
+type UpdateUserInput struct {
+	// UserName is the new name, the same as the current one to keep it
+	UserName  string    `json:"user_name"`
+	Password  string    `json:"password"`
+	Email     string    `json:"email"`
+	AuthLevel AuthLevel `json:"auth_level"`
+}
+
+// validate : Function to check the fields of the account against rules
+func (in UpdateUserInput) validate(rules ValidationRules) ValidationErrors {
+	errs := rules.validateUser("newUserName", in.UserName, in.Password, in.Email)
+	if !in.AuthLevel.valid() {
+		errs = append(errs, &ValidationError{Field: "authLevel", Reason: "has unknown value " + strconv.Itoa(int(in.AuthLevel))})
+	}
+	return errs
+}
+
+// validateFields : Function to check the fields of an account changed by
+// UpdateUserFields, where an empty password keeps the current one
+func (in UpdateUserInput) validateFields(rules ValidationRules) ValidationErrors {
+	errs := in.validate(rules)
+	if in.Password != "" {
+		return errs
+	}
+	var kept ValidationErrors
+	for _, err := range errs {
+		if err.Field != "password" {
+			kept = append(kept, err)
+		}
+	}
+	return kept
+}
+
+// SignupRequest : Account waiting for an admin to approve it
+type SignupRequest struct {
+	UserName string `json:"user_name"`
+	Email    string `json:"email"`
+}
+
+// BlockedUser : Account blocked by an admin
+type BlockedUser struct {
+	UserName string `json:"user_name"`
+	Email    string `json:"email"`
+	// Reason is the one given to BlockUsers, if any
+	Reason string `json:"reason"`
+}
+
+// HeavyUser : User ranked by the disk space taken by their downloads
+type HeavyUser struct {
+	UserName string `json:"user_name"`
+	// Size is the total size of the user's downloads in bytes
+	Size int64 `json:"size"`
+}
+
+// AuthLevel : Permissions of a user account, as numbered by the server
+type AuthLevel int
+
+// Auth levels of user accounts. AuthLevelUnknown is never sent by the
+// server; it marks a login whose response didn't report the level.
+const (
+	AuthLevelUnknown AuthLevel = -1
+	AuthLevelAdmin   AuthLevel = 0
+	AuthLevelRegular AuthLevel = 1
+)
+
+// String : Function to name the auth level, e.g. for listings
+func (l AuthLevel) String() string {
+	switch l {
+	case AuthLevelAdmin:
+		return "admin"
+	case AuthLevelRegular:
+		return "regular"
+	case AuthLevelUnknown:
+		return "unknown"
+	}
+	return "AuthLevel(" + strconv.Itoa(int(l)) + ")"
+}
+
+// valid : Function telling whether the level can be given to an account
+func (l AuthLevel) valid() bool {
+	return l == AuthLevelAdmin || l == AuthLevelRegular
+}
+
+// IsAdmin : Function telling whether the user has admin rights
+func (u User) IsAdmin() bool {
+	return u.AuthLevel == AuthLevelAdmin
+}
+
+// userEndpoint : Helper function to build the endpoint of a single user
+func userEndpoint(userName string, action string) string {
+	endpoint := "/api/user/" + url.PathEscape(userName)
+	if action != "" {
+		endpoint += "/" + action
+	}
+	return endpoint
+}
+
+// UserStorage : Disk space taken on the server by one user's downloads
+type UserStorage struct {
+	UserName  string `json:"user_name"`
+	BytesUsed int64  `json:"bytes_used"`
+}
+
+// GetStorageByUser : Function to get the on-disk footprint of every user,
+// largest first. Unlike GetToptenHeaviestUsers it is not limited to ten users.
+func (s *AdminService) GetStorageByUser(ctx context.Context) ([]UserStorage, error) {
+	var usage []UserStorage
+	if err := s.client.do(ctx, "GET", "/api/user/storage", nil, &usage); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(usage, func(i, j int) bool {
+		return usage[i].BytesUsed > usage[j].BytesUsed
+	})
+	return usage, nil
+}
+
+// ApproveUsers : Function to approve several signup requests at once,
+// reporting like BlockUsers
+func (s *AdminService) ApproveUsers(ctx context.Context, userNames []string) (map[string]error, error) {
+	return s.client.eachUser(ctx, userNames, func(userName string) error {
+		return s.client.do(ctx, "POST", "/api/user/approve/"+url.PathEscape(userName), nil, nil)
+	})
+}
+
+// BlockUsers : Function to block several users, e.g. when dealing with abuse.
+// Up to 4 users are processed at once, see WithBulkConcurrency. Every user
+// is attempted even if others fail; the returned map holds the outcome per
+// user, nil meaning blocked. The error is only set when nothing could be
+// attempted, including for a list naming a user twice, or ctx ended before
+// all users were processed.
+func (s *AdminService) BlockUsers(ctx context.Context, userNames []string, reason string) (map[string]error, error) {
+	body := map[string]string{"reason": reason}
+	return s.client.eachUser(ctx, userNames, func(userName string) error {
+		return s.client.do(ctx, "POST", "/api/user/blocked/"+url.PathEscape(userName), body, nil)
+	})
+}
+
+// UnblockUsers : Function to unblock several users, reporting like BlockUsers
+func (s *AdminService) UnblockUsers(ctx context.Context, userNames []string) (map[string]error, error) {
+	return s.client.eachUser(ctx, userNames, func(userName string) error {
+		return s.client.do(ctx, "DELETE", "/api/user/blocked/"+url.PathEscape(userName), nil, nil)
+	})
+}
+
+// RemoveUsers : Function to delete several accounts at once, reporting like
+// BlockUsers
+func (s *UsersService) RemoveUsers(ctx context.Context, userNames []string) (map[string]error, error) {
+	return s.client.eachUser(ctx, userNames, func(userName string) error {
+		return s.client.do(ctx, "DELETE", userEndpoint(userName, ""), nil, nil)
+	})
+}
+
+// eachUser : Helper function running fn for every user of a bulk operation,
+// as many at once as set by WithBulkConcurrency. Users not started when ctx
+// ends are left out of the results.
+func (b *Client) eachUser(ctx context.Context, userNames []string, fn func(userName string) error) (map[string]error, error) {
+	if len(userNames) == 0 {
+		return nil, invalidParam("userNames", "must not be empty")
+	}
+	// Results are keyed by name, so a repeated name would hide an outcome
+	seen := make(map[string]bool, len(userNames))
+	for _, userName := range userNames {
+		if seen[userName] {
+			return nil, invalidParam("userNames", "has duplicate "+strconv.Quote(userName))
+		}
+		seen[userName] = true
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	results := make(map[string]error, len(userNames))
+	slots := make(chan struct{}, b.bulkLimit())
+	for _, userName := range userNames {
+		if strings.TrimSpace(userName) == "" {
+			results[userName] = invalidParam("userName", "must not be empty")
+			continue
+		}
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(userName string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			err := fn(userName)
+			mu.Lock()
+			results[userName] = err
+			mu.Unlock()
+		}(userName)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return results, err
+	}
+	return results, nil
+}
+
+// bulkLimit : Function returning how many users a bulk operation processes
+// at once
+func (b *Client) bulkLimit() int {
+	if b.bulkConcurrency > 0 {
+		return b.bulkConcurrency
+	}
+	return defaultBulkConcurrency
+}
+
+// ResendVerification : Function to have the server send the verification
+// email of a user again
+func (s *UsersService) ResendVerification(ctx context.Context, userName string) error {
+	if strings.TrimSpace(userName) == "" {
+		return invalidParam("userName", "must not be empty")
+	}
+	return s.client.do(ctx, "POST", userEndpoint(userName, "verification"), nil, nil)
+}
+
+// SetEmailVerified : Function for admins to mark the email of a user as
+// verified, or unverified again
+func (s *AdminService) SetEmailVerified(ctx context.Context, userName string, verified bool) error {
+	if strings.TrimSpace(userName) == "" {
+		return invalidParam("userName", "must not be empty")
+	}
+	body := map[string]bool{"verified": verified}
+	return s.client.do(ctx, "PUT", userEndpoint(userName, "verified"), body, nil)
+}
+
+// GetUser : Function to get the account of userName. An unknown user gives
+// an error matching ErrNotFound.
+func (s *UsersService) GetUser(ctx context.Context, userName string) (User, error) {
+	if strings.TrimSpace(userName) == "" {
+		return User{}, invalidParam("userName", "must not be empty")
+	}
+	var user User
+	if err := s.client.do(ctx, "GET", userEndpoint(userName, ""), nil, &user); err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+// IsUsernameAvailable : Function to check whether a user name is still free,
+// e.g. while a signup form is being filled in. A taken name gives false and
+// a nil error; only failed checks return an error.
+func (s *UsersService) IsUsernameAvailable(ctx context.Context, userName string) (bool, error) {
+	if strings.TrimSpace(userName) == "" {
+		return false, invalidParam("userName", "must not be empty")
+	}
+	err := s.client.do(ctx, "HEAD", userEndpoint(userName, ""), nil, nil)
+	switch {
+	case err == nil:
+		return false, nil
+	case errors.Is(err, ErrNotFound):
+		return true, nil
+	default:
+		return false, err
+	}
+}
+
+// UserDownloadStats : Outcome counts of the downloads of one user
+type UserDownloadStats struct {
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+	Cancelled int `json:"cancelled"`
+	// SuccessRatio is Completed over all three counts, 0 while there are none.
+	// It is computed by the client.
+	SuccessRatio float64 `json:"-"`
+}
+
+// GetUserDownloadStats : Function to get how the downloads of a user ended,
+// for spotting abuse or broken links. Servers without download statistics
+// return ErrNotSupported.
+func (s *UsersService) GetUserDownloadStats(ctx context.Context, userName string) (UserDownloadStats, error) {
+	if strings.TrimSpace(userName) == "" {
+		return UserDownloadStats{}, invalidParam("userName", "must not be empty")
+	}
+	var stats UserDownloadStats
+	if err := s.client.do(ctx, "GET", userEndpoint(userName, "stats"), nil, &stats); err != nil {
+		return UserDownloadStats{}, err
+	}
+	if total := stats.Completed + stats.Failed + stats.Cancelled; total > 0 {
+		stats.SuccessRatio = float64(stats.Completed) / float64(total)
+	}
+	return stats, nil
+}
+
+// accountEndpoint : Function to route an operation on userName to the
+// self-service endpoint when it targets the logged in user, or an empty
+// name, and to the admin endpoint otherwise. Users known not to be admins
+// get ErrForbidden without a request being sent; when the level is unknown
+// the server decides.
+func (b *Client) accountEndpoint(userName string, action string) (string, error) {
+	account := b.currentAccount()
+	if userName == "" || (account != nil && userName == account.UserName) {
+		return "/api/account/" + action, nil
+	}
+	if account != nil && account.AuthLevel != AuthLevelAdmin && account.AuthLevel != AuthLevelUnknown {
+		return "", fmt.Errorf("%s of another user needs an admin login: %w", action, ErrForbidden)
+	}
+	return userEndpoint(userName, action), nil
+}
+
+// ChangePassword : Function to set the password of userName. The logged in
+// user, or an empty userName, changes their own password; changing another
+// user's needs an admin login.
+func (s *UsersService) ChangePassword(ctx context.Context, userName string, newPassword string) error {
+	if errs := s.client.rules.validatePassword("newPassword", newPassword); len(errs) > 0 {
+		return errs
+	}
+	endpoint, err := s.client.accountEndpoint(userName, "password")
+	if err != nil {
+		return err
+	}
+	body := map[string]string{"password": newPassword}
+	return s.client.do(ctx, "PUT", endpoint, body, nil)
+}
+
+// Quota : Storage allowance of a user. A LimitBytes of 0 means unlimited.
+type Quota struct {
+	LimitBytes int64 `json:"limit_bytes"`
+	UsedBytes  int64 `json:"used_bytes"`
+}
+
+// GetQuota : Function to get the storage quota of userName, routed like
+// ChangePassword: the logged in user's own quota, or another user's for admins
+func (s *UsersService) GetQuota(ctx context.Context, userName string) (Quota, error) {
+	endpoint, err := s.client.accountEndpoint(userName, "quota")
+	if err != nil {
+		return Quota{}, err
+	}
+	var quota Quota
+	if err := s.client.do(ctx, "GET", endpoint, nil, &quota); err != nil {
+		return Quota{}, err
+	}
+	return quota, nil
+}
+
+// SetQuota : Function to set the storage allowance of userName, 0 lifting
+// the limit. Users already above the new limit keep their downloads but
+// can't add more.
+func (s *AdminService) SetQuota(ctx context.Context, userName string, limitBytes int64) error {
+	var errs ValidationErrors
+	if strings.TrimSpace(userName) == "" {
+		errs = append(errs, &ValidationError{Field: "userName", Reason: "must not be empty"})
+	}
+	if limitBytes < 0 {
+		errs = append(errs, &ValidationError{Field: "limitBytes", Reason: "must not be negative"})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	body := map[string]int64{"limit_bytes": limitBytes}
+	return s.client.do(ctx, "PUT", userEndpoint(userName, "quota"), body, nil)
+}
+
+// UserPolicy : Limits set on a user by an admin. 0 means no limit.
+type UserPolicy struct {
+	MaxConcurrentDownloads int   `json:"max_concurrent_downloads"`
+	QuotaBytes             int64 `json:"quota_bytes"`
+	// BandwidthLimit caps the user's downloads in bytes per second
+	BandwidthLimit int64 `json:"bandwidth_limit"`
+}
+
+// validate : Function to check that no limit is negative
+func (p UserPolicy) validate() error {
+	var errs ValidationErrors
+	if p.MaxConcurrentDownloads < 0 {
+		errs = append(errs, &ValidationError{Field: "maxConcurrentDownloads", Reason: "must not be negative"})
+	}
+	if p.QuotaBytes < 0 {
+		errs = append(errs, &ValidationError{Field: "quotaBytes", Reason: "must not be negative"})
+	}
+	if p.BandwidthLimit < 0 {
+		errs = append(errs, &ValidationError{Field: "bandwidthLimit", Reason: "must not be negative"})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// GetUserPolicy : Function for admins to get the limits of a user. Other
+// users get ErrForbidden.
+func (s *AdminService) GetUserPolicy(ctx context.Context, userName string) (UserPolicy, error) {
+	if strings.TrimSpace(userName) == "" {
+		return UserPolicy{}, invalidParam("userName", "must not be empty")
+	}
+	var policy UserPolicy
+	if err := s.client.do(ctx, "GET", userEndpoint(userName, "policy"), nil, &policy); err != nil {
+		return UserPolicy{}, err
+	}
+	return policy, nil
+}
+
+// SetUserPolicy : Function for admins to set the limits of a user, replacing
+// the previous ones. Other users get ErrForbidden.
+func (s *AdminService) SetUserPolicy(ctx context.Context, userName string, policy UserPolicy) error {
+	if strings.TrimSpace(userName) == "" {
+		return invalidParam("userName", "must not be empty")
+	}
+	if err := policy.validate(); err != nil {
+		return err
+	}
+	return s.client.do(ctx, "PUT", userEndpoint(userName, "policy"), policy, nil)
+}
+
+// updateUserAttempts : How many times UpdateUserFields tries its
+// read-modify-write before giving up on a conflict
+const updateUserAttempts = 3
+
+// UpdateUserFields : Function to change some fields of a user without
+// blanking the others. It fetches the user, lets apply change the account
+// built from it, validates the result and PUTs it back, with If-Match set
+// to the fetched ETag when the server sends one. The account starts with an
+// empty password, which is left out of the PUT so the server keeps the
+// current one unless apply sets a new one. When someone else updated the
+// user in between (409 or 412), it starts over with a fresh copy, calling
+// apply again, up to 3 times.
+func (s *UsersService) UpdateUserFields(ctx context.Context, userName string, apply func(*UpdateUserInput)) error {
+	if strings.TrimSpace(userName) == "" {
+		return invalidParam("userName", "must not be empty")
+	}
+	if apply == nil {
+		return invalidParam("apply", "must not be nil")
+	}
+	endpoint := userEndpoint(userName, "")
+	var err error
+	for attempt := 0; attempt < updateUserAttempts; attempt++ {
+		var user User
+		var etag string
+		if user, etag, err = s.client.getUser(ctx, endpoint); err != nil {
+			return err
+		}
+		input := UpdateUserInput{UserName: user.UserName, Email: user.Email, AuthLevel: user.AuthLevel}
+		apply(&input)
+		if errs := input.validateFields(s.client.rules); len(errs) > 0 {
+			return errs
+		}
+		body := struct {
+			UserName  string    `json:"user_name"`
+			Password  string    `json:"password,omitempty"`
+			Email     string    `json:"email"`
+			AuthLevel AuthLevel `json:"auth_level"`
+		}{input.UserName, input.Password, input.Email, input.AuthLevel}
+		var header http.Header
+		if etag != "" {
+			header = http.Header{"If-Match": {etag}}
+		}
+		var response *http.Response
+		response, err = s.client.roundTrip(ctx, s.client.clientFor(ctx), "PUT", endpoint, body, header)
+		if err == nil {
+			response.Body.Close()
+			return nil
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || (apiErr.StatusCode != http.StatusConflict && apiErr.StatusCode != http.StatusPreconditionFailed) {
+			return err
+		}
+	}
+	return fmt.Errorf("updating user %q: %w", userName, err)
+}
+
+// getUser : Helper function to fetch a user along with the ETag of the
+// response, if any
+func (b *Client) getUser(ctx context.Context, endpoint string) (User, string, error) {
+	response, err := b.roundTrip(ctx, b.clientFor(ctx), "GET", endpoint, nil, nil)
+	if err != nil {
+		return User{}, "", err
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return User{}, "", err
+	}
+	var user User
+	if err := b.decode(body, &user); err != nil {
+		return User{}, "", err
+	}
+	return user, response.Header.Get("ETag"), nil
+}
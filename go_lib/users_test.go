@@ -0,0 +1,713 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetStorageByUser(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/api/user/storage" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`[
+			{"user_name": "rand", "bytes_used": 1024},
+			{"user_name": "Mehant", "bytes_used": 1073741824},
+			{"user_name": "blockeduser", "bytes_used": 0}
+		]`))
+	}))
+
+	usage, err := b.Admin.GetStorageByUser(context.Background())
+	if err != nil {
+		t.Fatalf("GetStorageByUser: %v", err)
+	}
+	want := []UserStorage{
+		{UserName: "Mehant", BytesUsed: 1073741824},
+		{UserName: "rand", BytesUsed: 1024},
+		{UserName: "blockeduser", BytesUsed: 0},
+	}
+	if !reflect.DeepEqual(usage, want) {
+		t.Errorf("got %+v, want %+v", usage, want)
+	}
+}
+
+func TestGetStorageByUserServerError(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	if _, err := b.Admin.GetStorageByUser(context.Background()); err == nil {
+		t.Fatal("expected an error for a 500 response")
+	}
+}
+
+func TestBlockUsersPartialFailure(t *testing.T) {
+	var mu sync.Mutex
+	var blocked []string
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || !strings.HasPrefix(r.URL.Path, "/api/user/blocked/") {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["reason"] != "spam" {
+			t.Errorf("reason = %q, want spam", body["reason"])
+		}
+		userName := strings.TrimPrefix(r.URL.Path, "/api/user/blocked/")
+		switch userName {
+		case "ghost":
+			http.Error(w, `{"error": "no such user"}`, http.StatusNotFound)
+		case "MehantAdmin":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			mu.Lock()
+			blocked = append(blocked, userName)
+			mu.Unlock()
+			w.Write([]byte(`{"status": "success"}`))
+		}
+	}))
+
+	results, err := b.Admin.BlockUsers(context.Background(), []string{"rand", "ghost", "MehantAdmin", "", "Mehant"}, "spam")
+	if err != nil {
+		t.Fatalf("BlockUsers: %v", err)
+	}
+	if len(results) != 5 {
+		t.Fatalf("got %d results, want 5", len(results))
+	}
+	if results["rand"] != nil || results["Mehant"] != nil {
+		t.Errorf("successful users reported errors: %v", results)
+	}
+	if results["ghost"] == nil {
+		t.Error("ghost: want an error")
+	}
+	if !errors.Is(results["MehantAdmin"], ErrForbidden) {
+		t.Errorf("MehantAdmin: got %v, want ErrForbidden", results["MehantAdmin"])
+	}
+	if !errors.Is(results[""], ErrInvalidParams) {
+		t.Errorf("empty name: got %v, want ErrInvalidParams", results[""])
+	}
+	sort.Strings(blocked)
+	if !reflect.DeepEqual(blocked, []string{"Mehant", "rand"}) {
+		t.Errorf("blocked = %v, want [Mehant rand]", blocked)
+	}
+}
+
+func TestUnblockUsers(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" {
+			t.Errorf("method = %s, want DELETE", r.Method)
+		}
+		if r.URL.Path == "/api/user/blocked/ghost" {
+			http.Error(w, `{"error": "no such user"}`, http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"status": "success"}`))
+	}))
+	results, err := b.Admin.UnblockUsers(context.Background(), []string{"blockeduser", "ghost"})
+	if err != nil {
+		t.Fatalf("UnblockUsers: %v", err)
+	}
+	if results["blockeduser"] != nil || results["ghost"] == nil {
+		t.Errorf("results = %v", results)
+	}
+}
+
+func TestBlockUsersDuplicate(t *testing.T) {
+	var calls int32
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	if _, err := b.Admin.BlockUsers(context.Background(), []string{"alice", "bob", "alice"}, "spam"); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("BlockUsers = %v, want ErrInvalidParams", err)
+	}
+	if _, err := b.Admin.UnblockUsers(context.Background(), []string{"bob", "bob"}); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("UnblockUsers = %v, want ErrInvalidParams", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 0 {
+		t.Errorf("sent %d requests, want none", n)
+	}
+}
+
+func TestBlockUsersEmpty(t *testing.T) {
+	b := newOfflineClient()
+	if _, err := b.Admin.BlockUsers(context.Background(), nil, "spam"); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("BlockUsers = %v, want ErrInvalidParams", err)
+	}
+	if _, err := b.Admin.UnblockUsers(context.Background(), []string{}); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("UnblockUsers = %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestBlockUsersCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		w.Write([]byte(`{"status": "success"}`))
+	}), WithBulkConcurrency(1))
+	results, err := b.Admin.BlockUsers(ctx, []string{"rand", "Mehant"}, "spam")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if _, ok := results["Mehant"]; ok {
+		t.Errorf("Mehant was attempted after cancellation: %v", results)
+	}
+}
+
+func TestBulkUserOperations(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	active, maxActive := 0, 0
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if active++; active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+		if strings.HasSuffix(r.URL.Path, "/ghost") {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}), WithBulkConcurrency(2))
+
+	names := []string{"u1", "u2", "u3", "u4", "ghost"}
+	approved, err := b.Admin.ApproveUsers(context.Background(), names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed, err := b.Users.RemoveUsers(context.Background(), names)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, results := range []map[string]error{approved, removed} {
+		if len(results) != len(names) || results["u1"] != nil || !errors.Is(results["ghost"], ErrNotFound) {
+			t.Errorf("results = %v", results)
+		}
+	}
+	sort.Strings(requests)
+	if requests[0] != "DELETE /api/user/ghost" || requests[len(requests)-1] != "POST /api/user/approve/u4" || len(requests) != 10 {
+		t.Errorf("requests = %q", requests)
+	}
+	if maxActive > 2 {
+		t.Errorf("%d users processed at once, want at most 2", maxActive)
+	}
+	if _, err := NewClient("http://localhost:5000", WithBulkConcurrency(0)); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("WithBulkConcurrency(0) = %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/user/rand/verification":
+			if r.Method != "POST" {
+				t.Errorf("method = %s, want POST", r.Method)
+			}
+			w.Write([]byte(`{"status": "success"}`))
+		case "/api/user/ghost/verification":
+			http.Error(w, `{"error": "no such user"}`, http.StatusNotFound)
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	if err := b.Users.ResendVerification(context.Background(), "rand"); err != nil {
+		t.Fatalf("ResendVerification: %v", err)
+	}
+	if err := b.Users.ResendVerification(context.Background(), "ghost"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+	if err := b.Users.ResendVerification(context.Background(), ""); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestSetEmailVerified(t *testing.T) {
+	verified := map[string]bool{}
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("token") != "admin-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.Method != "PUT" || r.URL.Path != "/api/user/Mehant/verified" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		var body map[string]bool
+		json.NewDecoder(r.Body).Decode(&body)
+		verified["Mehant"] = body["verified"]
+		w.Write([]byte(`{"status": "success"}`))
+	}))
+	if err := b.Admin.SetEmailVerified(context.Background(), "Mehant", true); !errors.Is(err, ErrForbidden) {
+		t.Errorf("without admin token: got %v, want ErrForbidden", err)
+	}
+	b.token = "admin-token"
+	if err := b.Admin.SetEmailVerified(context.Background(), "Mehant", true); err != nil {
+		t.Fatalf("SetEmailVerified: %v", err)
+	}
+	if !verified["Mehant"] {
+		t.Error("verified flag not sent")
+	}
+	if err := b.Admin.SetEmailVerified(context.Background(), " ", true); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestUserDecodesVerified(t *testing.T) {
+	var user User
+	if err := decodeFields([]byte(`{"user_name": "rand", "verified": true}`), &user); err != nil {
+		t.Fatal(err)
+	}
+	if !user.Verified {
+		t.Errorf("got %+v", user)
+	}
+}
+
+func TestIsUsernameAvailable(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "HEAD" {
+			t.Errorf("method = %s, want HEAD", r.Method)
+		}
+		switch r.URL.Path {
+		case "/api/user/rand":
+			w.WriteHeader(http.StatusOK)
+		case "/api/user/newcomer":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	tests := []struct {
+		userName  string
+		available bool
+		err       error
+	}{
+		{"rand", false, nil},
+		{"newcomer", true, nil},
+		{"other", false, ErrForbidden},
+		{"", false, ErrInvalidParams},
+	}
+	for _, tt := range tests {
+		available, err := b.Users.IsUsernameAvailable(context.Background(), tt.userName)
+		if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+			t.Errorf("%q: err = %v, want %v", tt.userName, err, tt.err)
+		}
+		if available != tt.available {
+			t.Errorf("%q: available = %v, want %v", tt.userName, available, tt.available)
+		}
+	}
+}
+
+func TestIsUsernameAvailableTransportError(t *testing.T) {
+	b := &Client{}
+	b.Init("http://127.0.0.1:1", 1000, 0)
+	available, err := b.Users.IsUsernameAvailable(context.Background(), "rand")
+	if err == nil || available {
+		t.Errorf("got (%v, %v), want (false, error)", available, err)
+	}
+}
+
+func TestGetUserDownloadStats(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.EscapedPath() != "/api/user/jo%20ann/stats" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.EscapedPath())
+		}
+		w.Write([]byte(`{"completed": 6, "failed": 1, "cancelled": 1, "success_ratio": 1}`))
+	}))
+	stats, err := b.Users.GetUserDownloadStats(context.Background(), "jo ann")
+	if err != nil {
+		t.Fatalf("GetUserDownloadStats: %v", err)
+	}
+	want := UserDownloadStats{Completed: 6, Failed: 1, Cancelled: 1, SuccessRatio: 0.75}
+	if stats != want {
+		t.Errorf("got %+v, want %+v", stats, want)
+	}
+}
+
+func TestGetUserDownloadStatsNoDownloads(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"completed": 0, "failed": 0, "cancelled": 0}`))
+	}))
+	stats, err := b.Users.GetUserDownloadStats(context.Background(), "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.SuccessRatio != 0 {
+		t.Errorf("SuccessRatio = %v, want 0", stats.SuccessRatio)
+	}
+}
+
+func TestGetUserDownloadStatsErrors(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotImplemented)
+	}))
+	if _, err := b.Users.GetUserDownloadStats(context.Background(), "rand"); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("got %v, want ErrNotSupported", err)
+	}
+	if _, err := b.Users.GetUserDownloadStats(context.Background(), ""); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("empty name: got %v, want ErrInvalidParams", err)
+	}
+}
+
+// accountServer : Handler logging the requests to the self-service and
+// admin account endpoints, logging in userName at authLevel
+func accountServer(t *testing.T, authLevel AuthLevel, requests *[]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/login" {
+			w.Header().Set("token", "t")
+			fmt.Fprintf(w, `{"user_name": %q, "auth_level": %d}`, r.FormValue("user_name"), authLevel)
+			return
+		}
+		*requests = append(*requests, r.Method+" "+r.URL.Path)
+		if r.Method == "GET" {
+			w.Write([]byte(`{"limit_bytes": 1000, "used_bytes": 250}`))
+		}
+	}
+}
+
+func TestAccountRoutingRegularUser(t *testing.T) {
+	var requests []string
+	b := newTestClient(t, accountServer(t, AuthLevelRegular, &requests))
+	if err := b.Auth.Login(context.Background(), "rand", "secret"); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := b.Users.ChangePassword(ctx, "rand", "n3w-secret"); err != nil {
+		t.Fatalf("own password: %v", err)
+	}
+	quota, err := b.Users.GetQuota(ctx, "")
+	if err != nil {
+		t.Fatalf("own quota: %v", err)
+	}
+	if quota != (Quota{LimitBytes: 1000, UsedBytes: 250}) {
+		t.Errorf("quota = %+v", quota)
+	}
+	if err := b.Users.ChangePassword(ctx, "mat", "n3w-secret"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("other password: got %v, want ErrForbidden", err)
+	}
+	if _, err := b.Users.GetQuota(ctx, "mat"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("other quota: got %v, want ErrForbidden", err)
+	}
+	want := []string{"PUT /api/account/password", "GET /api/account/quota"}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests = %q, want %q", requests, want)
+	}
+}
+
+func TestAccountRoutingAdmin(t *testing.T) {
+	var requests []string
+	b := newTestClient(t, accountServer(t, AuthLevelAdmin, &requests))
+	if err := b.Auth.Login(context.Background(), "admin", "secret"); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := b.Users.ChangePassword(ctx, "admin", "n3w-secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Users.ChangePassword(ctx, "mat", "n3w-secret"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Users.GetQuota(ctx, "mat"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"PUT /api/account/password", "PUT /api/user/mat/password", "GET /api/user/mat/quota"}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests = %q, want %q", requests, want)
+	}
+}
+
+func TestAccountRoutingUnknownLevel(t *testing.T) {
+	var requests []string
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/login" {
+			// No auth level reported
+			w.Header().Set("token", "t")
+			w.Write([]byte(`{"user_name": "rand", "email": "rand@bassa.org"}`))
+			return
+		}
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	if err := b.Auth.Login(context.Background(), "rand", "secret"); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if level := b.currentAccount().AuthLevel; level != AuthLevelUnknown {
+		t.Errorf("auth level = %d, want AuthLevelUnknown", level)
+	}
+	if err := b.Users.ChangePassword(context.Background(), "mat", "n3w-secret"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("got %v, want the server's ErrForbidden", err)
+	}
+	if want := []string{"PUT /api/user/mat/password"}; !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests = %q, want %q", requests, want)
+	}
+}
+
+func TestChangePasswordValidation(t *testing.T) {
+	b := newOfflineClient()
+	for _, password := range []string{"", "short"} {
+		if err := b.Users.ChangePassword(context.Background(), "rand", password); !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("%q: got %v, want ErrInvalidParams", password, err)
+		}
+	}
+}
+
+func TestUserPolicyRoundTrip(t *testing.T) {
+	var stored []byte
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/user/rand/policy" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		switch r.Method {
+		case "PUT":
+			stored, _ = ioutil.ReadAll(r.Body)
+		case "GET":
+			w.Write(stored)
+		}
+	}))
+	policy := UserPolicy{MaxConcurrentDownloads: 3, QuotaBytes: 10 << 30, BandwidthLimit: 1 << 20}
+	if err := b.Admin.SetUserPolicy(context.Background(), "rand", policy); err != nil {
+		t.Fatalf("SetUserPolicy: %v", err)
+	}
+	if !strings.Contains(string(stored), `"max_concurrent_downloads":3`) {
+		t.Errorf("sent %s", stored)
+	}
+	got, err := b.Admin.GetUserPolicy(context.Background(), "rand")
+	if err != nil {
+		t.Fatalf("GetUserPolicy: %v", err)
+	}
+	if got != policy {
+		t.Errorf("got %+v, want %+v", got, policy)
+	}
+}
+
+func TestUserPolicyForbidden(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	if _, err := b.Admin.GetUserPolicy(context.Background(), "rand"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("GetUserPolicy = %v, want ErrForbidden", err)
+	}
+	if err := b.Admin.SetUserPolicy(context.Background(), "rand", UserPolicy{}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("SetUserPolicy = %v, want ErrForbidden", err)
+	}
+}
+
+func TestUserPolicyValidation(t *testing.T) {
+	b := newOfflineClient()
+	err := b.Admin.SetUserPolicy(context.Background(), "rand", UserPolicy{MaxConcurrentDownloads: -1, BandwidthLimit: -5})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || !reflect.DeepEqual(errs.Fields(), []string{"maxConcurrentDownloads", "bandwidthLimit"}) {
+		t.Errorf("got %v, want maxConcurrentDownloads and bandwidthLimit errors", err)
+	}
+	if err := b.Admin.SetUserPolicy(context.Background(), " ", UserPolicy{}); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("empty user: got %v, want ErrInvalidParams", err)
+	}
+	if _, err := b.Admin.GetUserPolicy(context.Background(), ""); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("GetUserPolicy empty user: got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestUpdateUserFields(t *testing.T) {
+	var mu sync.Mutex
+	user := User{UserName: "rand", Email: "rand@bassa.org", AuthLevel: AuthLevelRegular, Verified: true}
+	version := 1
+	var puts int
+	var body map[string]interface{}
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path != "/api/user/rand" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		switch r.Method {
+		case "GET":
+			w.Header().Set("ETag", fmt.Sprintf(`"%d"`, version))
+			json.NewEncoder(w).Encode(user)
+		case "PUT":
+			puts++
+			if puts == 1 {
+				// Someone else changed the user since it was fetched
+				version++
+			}
+			if r.Header.Get("If-Match") != fmt.Sprintf(`"%d"`, version) {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+			body = nil
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("decoding PUT: %v", err)
+			}
+			version++
+		}
+	}))
+	err := b.Users.UpdateUserFields(context.Background(), "rand", func(in *UpdateUserInput) { in.Email = "new@bassa.org" })
+	if err != nil {
+		t.Fatalf("UpdateUserFields: %v", err)
+	}
+	want := map[string]interface{}{"user_name": "rand", "email": "new@bassa.org", "auth_level": float64(AuthLevelRegular)}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("PUT body = %v, want %v", body, want)
+	}
+	if puts != 2 {
+		t.Errorf("%d PUTs, want a retry after the conflict", puts)
+	}
+
+	err = b.Users.UpdateUserFields(context.Background(), "rand", func(in *UpdateUserInput) { in.Password = "new-secret" })
+	if err != nil {
+		t.Fatalf("UpdateUserFields with a password: %v", err)
+	}
+	want = map[string]interface{}{"user_name": "rand", "password": "new-secret", "email": "rand@bassa.org", "auth_level": float64(AuthLevelRegular)}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("PUT body = %v, want %v", body, want)
+	}
+}
+
+func TestUpdateUserFieldsValidation(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			t.Error("invalid update was sent")
+		}
+		w.Write([]byte(`{"user_name": "rand", "email": "rand@bassa.org", "auth_level": 1}`))
+	}))
+	err := b.Users.UpdateUserFields(context.Background(), "rand", func(in *UpdateUserInput) {
+		in.Email = "rand@"
+		in.AuthLevel = 7
+	})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || !reflect.DeepEqual(errs.Fields(), []string{"email", "authLevel"}) {
+		t.Errorf("got %v, want email and authLevel violations", err)
+	}
+}
+
+func TestUpdateUserFieldsGivesUp(t *testing.T) {
+	var puts int32
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			atomic.AddInt32(&puts, 1)
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		w.Write([]byte(`{"user_name": "rand", "email": "rand@bassa.org"}`))
+	}))
+	err := b.Users.UpdateUserFields(context.Background(), "rand", func(in *UpdateUserInput) {})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+		t.Errorf("got %v, want the conflict", err)
+	}
+	if n := atomic.LoadInt32(&puts); n != updateUserAttempts {
+		t.Errorf("%d PUTs, want %d", n, updateUserAttempts)
+	}
+	if err := b.Users.UpdateUserFields(context.Background(), "rand", nil); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("nil apply: got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestAddAndUpdateUserRequestBodies(t *testing.T) {
+	var got []map[string]interface{}
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("%s %s: invalid JSON: %v", r.Method, r.URL.Path, err)
+		}
+		body["request"] = r.Method + " " + r.URL.Path
+		got = append(got, body)
+	}))
+	ctx := context.Background()
+	if err := b.Users.AddUserRequest(ctx, CreateUserInput{UserName: "rand", Password: "se\"cretpass", Email: "rand@bassa.org", AuthLevel: AuthLevelAdmin}); err != nil {
+		t.Fatalf("AddUserRequest: %v", err)
+	}
+	if err := b.Users.UpdateUserRequest(ctx, "rand", UpdateUserInput{UserName: "randall", Password: "secretpass", Email: "rand@bassa.org", AuthLevel: AuthLevelRegular}); err != nil {
+		t.Fatalf("UpdateUserRequest: %v", err)
+	}
+	want := []map[string]interface{}{
+		{"request": "POST /api/user", "user_name": "rand", "password": "se\"cretpass", "email": "rand@bassa.org", "auth_level": float64(AuthLevelAdmin)},
+		{"request": "PUT /api/user/rand", "user_name": "randall", "password": "secretpass", "email": "rand@bassa.org", "auth_level": float64(AuthLevelRegular)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("bodies = %v, want %v", got, want)
+	}
+}
+
+func TestAuthLevel(t *testing.T) {
+	for level, want := range map[AuthLevel]string{AuthLevelAdmin: "admin", AuthLevelRegular: "regular", AuthLevelUnknown: "unknown", 7: "AuthLevel(7)"} {
+		if got := level.String(); got != want {
+			t.Errorf("%d: got %q, want %q", int(level), got, want)
+		}
+	}
+	if !(User{AuthLevel: AuthLevelAdmin}).IsAdmin() || (User{AuthLevel: AuthLevelRegular}).IsAdmin() {
+		t.Error("User.IsAdmin should only hold for admins")
+	}
+	if (LoginResult{AuthLevel: AuthLevelUnknown}).IsAdmin() {
+		t.Error("a login of unknown level isn't an admin")
+	}
+}
+
+func TestSetQuota(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/api/user/rand/quota" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		var body map[string]int64
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body["limit_bytes"] != 5<<30 {
+			t.Errorf("body = %v, %v", body, err)
+		}
+	}))
+	if err := b.Admin.SetQuota(context.Background(), "rand", 5<<30); err != nil {
+		t.Fatal(err)
+	}
+	err := b.Admin.SetQuota(context.Background(), " ", -1)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || !reflect.DeepEqual(errs.Fields(), []string{"userName", "limitBytes"}) {
+		t.Errorf("got %v, want userName and limitBytes violations", err)
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/user/rand":
+			w.Write([]byte(`{"user_name": "rand", "email": "rand@scorelab.org", "auth_level": 0, "verified": true}`))
+		default:
+			http.Error(w, `{"error": "no such user"}`, http.StatusNotFound)
+		}
+	}))
+	user, err := b.Users.GetUser(context.Background(), "rand")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (User{UserName: "rand", Email: "rand@scorelab.org", AuthLevel: AuthLevelAdmin, Verified: true}); user != want {
+		t.Errorf("got %+v, want %+v", user, want)
+	}
+	if _, err := b.Users.GetUser(context.Background(), "ghost"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown user: got %v, want ErrNotFound", err)
+	}
+	if _, err := b.Users.GetUser(context.Background(), " "); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("empty name: got %v, want ErrInvalidParams", err)
+	}
+}
@@ -0,0 +1,186 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ValidationError : An argument that failed validation. It matches
+// ErrInvalidParams with errors.Is.
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%v: %s %s", ErrInvalidParams, e.Field, e.Reason)
+}
+
+// Is : Function reporting that validation errors are ErrInvalidParams
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidParams
+}
+
+// ValidationErrors : Every argument of a call that failed validation, so a
+// UI can flag all of them at once. errors.As finds the first with a
+// *ValidationError target.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	reasons := make([]string, len(e))
+	for i, err := range e {
+		reasons[i] = err.Field + " " + err.Reason
+	}
+	return fmt.Sprintf("%v: %s", ErrInvalidParams, strings.Join(reasons, "; "))
+}
+
+// Unwrap : Function exposing the single errors to errors.Is and errors.As
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Fields : Function listing the names of the invalid fields
+func (e ValidationErrors) Fields() []string {
+	fields := make([]string, len(e))
+	for i, err := range e {
+		fields[i] = err.Field
+	}
+	return fields
+}
+
+// invalidParam : Helper function to build the validation error for one argument
+func invalidParam(name string, reason string) error {
+	return &ValidationError{Field: name, Reason: reason}
+}
+
+// defaultUserNamePattern : User names accepted unless WithValidationRules
+// says otherwise
+var defaultUserNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// MinPasswordLength : Shortest password, in characters, accepted for new
+// or updated users unless a PasswordPolicy sets another minimum
+const MinPasswordLength = 8
+
+// PasswordPolicy : Requirements on new passwords, checked before they are
+// sent. The zero value only enforces MinPasswordLength, leaving the rest to
+// the server.
+type PasswordPolicy struct {
+	// MinLength is the shortest password in characters, MinPasswordLength
+	// if 0; 1 accepts any password that isn't empty
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+// ValidationRules : Rules checked by the client before creating or
+// updating accounts, set with WithValidationRules
+type ValidationRules struct {
+	// UserName matches the valid user names. When nil, names of up to 64
+	// letters, digits, dots, underscores and dashes are accepted.
+	UserName *regexp.Regexp
+	Password PasswordPolicy
+}
+
+// validateUser : Function to check the fields of a user account, nameField
+// being how the user name argument is reported
+func (r ValidationRules) validateUser(nameField string, userName string, password string, email string) ValidationErrors {
+	var errs ValidationErrors
+	pattern := r.UserName
+	if pattern == nil {
+		pattern = defaultUserNamePattern
+	}
+	switch {
+	case strings.TrimSpace(userName) == "":
+		errs = append(errs, &ValidationError{Field: nameField, Reason: "must not be empty"})
+	case !pattern.MatchString(userName):
+		errs = append(errs, &ValidationError{Field: nameField, Reason: "doesn't match " + pattern.String()})
+	}
+	errs = append(errs, r.validatePassword("password", password)...)
+	switch {
+	case email == "":
+		errs = append(errs, &ValidationError{Field: "email", Reason: "must not be empty"})
+	case validateEmail(email) != nil:
+		errs = append(errs, &ValidationError{Field: "email", Reason: "is not a valid email address"})
+	}
+	return errs
+}
+
+// validatePassword : Function to check password against the policy,
+// reporting every requirement it misses
+func (r ValidationRules) validatePassword(field string, password string) ValidationErrors {
+	if password == "" {
+		return ValidationErrors{{Field: field, Reason: "must not be empty"}}
+	}
+	policy := r.Password
+	minLength := policy.MinLength
+	if minLength == 0 {
+		minLength = MinPasswordLength
+	}
+	var errs ValidationErrors
+	if n := utf8.RuneCountInString(password); n < minLength {
+		errs = append(errs, &ValidationError{Field: field, Reason: fmt.Sprintf("must be at least %d characters long", minLength)})
+	}
+	var upper, lower, digit, symbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			upper = true
+		case unicode.IsLower(c):
+			lower = true
+		case unicode.IsDigit(c):
+			digit = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c):
+			symbol = true
+		}
+	}
+	if policy.RequireUpper && !upper {
+		errs = append(errs, &ValidationError{Field: field, Reason: "must contain an upper case letter"})
+	}
+	if policy.RequireLower && !lower {
+		errs = append(errs, &ValidationError{Field: field, Reason: "must contain a lower case letter"})
+	}
+	if policy.RequireDigit && !digit {
+		errs = append(errs, &ValidationError{Field: field, Reason: "must contain a digit"})
+	}
+	if policy.RequireSymbol && !symbol {
+		errs = append(errs, &ValidationError{Field: field, Reason: "must contain a symbol"})
+	}
+	return errs
+}
+
+// validateEmail : Helper function to check that email is a bare address as
+// defined by RFC 5322, without a display name
+func validateEmail(email string) error {
+	address, err := mail.ParseAddress(email)
+	if err != nil {
+		return err
+	}
+	if address.Name != "" || address.Address != email {
+		return errors.New("not a bare email address")
+	}
+	return nil
+}
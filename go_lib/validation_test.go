@@ -0,0 +1,167 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAddUserRequestValidationFields(t *testing.T) {
+	b := newOfflineClient()
+	tests := []struct {
+		name     string
+		userName string
+		password string
+		email    string
+		fields   []string
+	}{
+		{"empty username", "", "secretpass", "kmehant@scorelab.org", []string{"userName"}},
+		{"bad email", "Mehant", "secretpass", "kmehant@", []string{"email"}},
+		{"weak password", "Mehant", "pass", "kmehant@scorelab.org", []string{"password"}},
+		{"empty password", "Mehant", "", "kmehant@scorelab.org", []string{"password"}},
+		{"everything", " ", "pw", "not-an-email", []string{"userName", "password", "email"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := b.Users.AddUserRequest(context.Background(), CreateUserInput{UserName: tt.userName, Password: tt.password, Email: tt.email, AuthLevel: AuthLevelRegular})
+			var errs ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("got %v, want ValidationErrors", err)
+			}
+			if !reflect.DeepEqual(errs.Fields(), tt.fields) {
+				t.Errorf("fields = %v, want %v", errs.Fields(), tt.fields)
+			}
+			if !errors.Is(err, ErrInvalidParams) {
+				t.Error("validation errors should match ErrInvalidParams")
+			}
+			var first *ValidationError
+			if !errors.As(err, &first) || first.Field != tt.fields[0] {
+				t.Errorf("errors.As(*ValidationError) = %v", first)
+			}
+		})
+	}
+}
+
+func TestUpdateUserRequestValidationFields(t *testing.T) {
+	b := newOfflineClient()
+	err := b.Users.UpdateUserRequest(context.Background(), "", UpdateUserInput{Password: "newsecretpass", Email: "kmehant@gmail.com"})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("got %v, want ValidationErrors", err)
+	}
+	if want := []string{"userName", "newUserName"}; !reflect.DeepEqual(errs.Fields(), want) {
+		t.Errorf("fields = %v, want %v", errs.Fields(), want)
+	}
+}
+
+func TestValidationErrorMessage(t *testing.T) {
+	err := invalidParam("downloadID", "must be positive")
+	if got, want := err.Error(), "invalid parameters: downloadID must be positive"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	errs := ValidationRules{}.validateUser("userName", "", "secretpass", "bad")
+	if got, want := errs.Error(), "invalid parameters: userName must not be empty; email is not a valid email address"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestValidateEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		valid bool
+	}{
+		{"kmehant@scorelab.org", true},
+		{"first.last+tag@example.co.uk", true},
+		{"kmehant@", false},
+		{"@scorelab.org", false},
+		{"Mehant <kmehant@scorelab.org>", false},
+		{" kmehant@scorelab.org", false},
+		{"two@at@signs.org", false},
+	}
+	for _, tt := range tests {
+		if err := validateEmail(tt.email); (err == nil) != tt.valid {
+			t.Errorf("validateEmail(%q) = %v, want valid %v", tt.email, err, tt.valid)
+		}
+	}
+}
+
+func TestValidateUserName(t *testing.T) {
+	valid := []string{"Mehant", "rand_user-1.test"}
+	invalid := []string{"with space", "slash/name", "ünïcode", strings.Repeat("a", 65)}
+	rules := ValidationRules{}
+	for _, name := range valid {
+		if errs := rules.validateUser("userName", name, "secretpass", "kmehant@scorelab.org"); len(errs) > 0 {
+			t.Errorf("%q: got %v, want valid", name, errs)
+		}
+	}
+	for _, name := range invalid {
+		if errs := rules.validateUser("userName", name, "secretpass", "kmehant@scorelab.org"); !reflect.DeepEqual(errs.Fields(), []string{"userName"}) {
+			t.Errorf("%q: got %v, want a userName violation", name, errs)
+		}
+	}
+	custom := ValidationRules{UserName: regexp.MustCompile(`^[a-z]+$`)}
+	if errs := custom.validateUser("userName", "Mehant", "secretpass", "kmehant@scorelab.org"); len(errs) != 1 {
+		t.Errorf("custom pattern: got %v, want one violation", errs)
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	rules := ValidationRules{Password: PasswordPolicy{MinLength: 10, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}}
+	errs := rules.validatePassword("password", "short")
+	want := "invalid parameters: password must be at least 10 characters long; password must contain an upper case letter; password must contain a digit; password must contain a symbol"
+	if errs.Error() != want {
+		t.Errorf("got %q, want %q", errs.Error(), want)
+	}
+	if errs := rules.validatePassword("password", "Secret-pass1"); len(errs) > 0 {
+		t.Errorf("got %v, want a valid password", errs)
+	}
+	if errs := (ValidationRules{}).validatePassword("password", "seven77"); len(errs) != 1 {
+		t.Errorf("default policy: got %v, want a MinPasswordLength violation", errs)
+	}
+	if errs := (ValidationRules{}).validatePassword("password", "eight888"); len(errs) > 0 {
+		t.Errorf("default policy: got %v, want a valid password", errs)
+	}
+	if errs := (ValidationRules{Password: PasswordPolicy{MinLength: 1}}).validatePassword("password", "x"); len(errs) > 0 {
+		t.Errorf("MinLength 1: got %v, want only non-empty checked", errs)
+	}
+}
+
+func TestWithValidationRules(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+	}), WithValidationRules(ValidationRules{Password: PasswordPolicy{MinLength: 12}}))
+	err := b.Users.ChangePassword(context.Background(), "", "secretpass")
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || !reflect.DeepEqual(errs.Fields(), []string{"newPassword"}) {
+		t.Errorf("got %v, want a newPassword violation", err)
+	}
+	if _, err := NewClient("http://localhost:5000", WithValidationRules(ValidationRules{Password: PasswordPolicy{MinLength: -1}})); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("negative MinLength: got %v, want ErrInvalidParams", err)
+	}
+}
+
+func TestAddUserRequestAuthLevel(t *testing.T) {
+	b := newOfflineClient()
+	err := b.Users.AddUserRequest(context.Background(), CreateUserInput{UserName: "rand", Password: "secretpass", Email: "rand@scorelab.org", AuthLevel: 5})
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || !reflect.DeepEqual(errs.Fields(), []string{"authLevel"}) {
+		t.Errorf("got %v, want an authLevel violation", err)
+	}
+}
@@ -0,0 +1,294 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// timeAfter is swapped out by tests needing a fake clock for polling delays
+var timeAfter = time.After
+
+// watcherShutdownTimeout : How long Close waits for watchers to stop, a
+// variable so that tests can shorten it
+var watcherShutdownTimeout = 5 * time.Second
+
+// WatchDownload : Function to poll a download every interval. Each state
+// whose status or progress differs from the previous one is sent on the
+// first channel, and polling errors on the second. Both channels are closed
+// once the download completes or fails, ctx is cancelled or the client is
+// closed. An interval below the server's recommendation, once applied with
+// ApplyRecommended, is raised to it.
+func (s *DownloadsService) WatchDownload(ctx context.Context, downloadID int, interval time.Duration) (<-chan Download, <-chan error) {
+	updates := make(chan Download)
+	errs := make(chan error, 1)
+	err := validateWatch(downloadID, interval)
+	if err == nil {
+		err = s.client.startWatcher(ctx, func(ctx context.Context) {
+			defer close(updates)
+			defer close(errs)
+			s.client.watchDownload(ctx, downloadID, s.client.pollInterval(interval), updates, errs)
+		})
+	}
+	if err != nil {
+		errs <- err
+		close(updates)
+		close(errs)
+	}
+	return updates, errs
+}
+
+func (b *Client) watchDownload(ctx context.Context, downloadID int, interval time.Duration, updates chan<- Download, errs chan<- error) {
+	var last *Download
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		download, err := b.getDownload(ctx, downloadID)
+		if err != nil {
+			if !sendError(ctx, errs, err) {
+				return
+			}
+		} else if downloadChanged(last, download) {
+			select {
+			case updates <- download:
+			case <-ctx.Done():
+				return
+			}
+			last = &download
+			if download.Status == StatusCompleted || download.Status == StatusFailed {
+				return
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// WatchDownloadBackoff : Function to poll a download like WatchDownload with
+// an interval adapting to it: polling starts at min and the interval doubles,
+// up to max, after every poll without a change, going back to min as soon as
+// the download changes. Idle downloads are thus polled rarely without
+// slowing down the updates of active ones.
+func (s *DownloadsService) WatchDownloadBackoff(ctx context.Context, downloadID int, min time.Duration, max time.Duration) (<-chan Download, <-chan error) {
+	updates := make(chan Download)
+	errs := make(chan error, 1)
+	err := validateWatch(downloadID, min)
+	if err == nil && max < min {
+		err = invalidParam("max", "must not be less than min")
+	}
+	if err == nil {
+		if min = s.client.pollInterval(min); max < min {
+			max = min
+		}
+		err = s.client.startWatcher(ctx, func(ctx context.Context) {
+			defer close(updates)
+			defer close(errs)
+			s.client.watchDownloadBackoff(ctx, downloadID, min, max, updates, errs)
+		})
+	}
+	if err != nil {
+		errs <- err
+		close(updates)
+		close(errs)
+	}
+	return updates, errs
+}
+
+func (b *Client) watchDownloadBackoff(ctx context.Context, downloadID int, min time.Duration, max time.Duration, updates chan<- Download, errs chan<- error) {
+	var last *Download
+	interval := min
+	for {
+		download, err := b.getDownload(ctx, downloadID)
+		changed := false
+		if err != nil {
+			if !sendError(ctx, errs, err) {
+				return
+			}
+		} else if changed = downloadChanged(last, download); changed {
+			select {
+			case updates <- download:
+			case <-ctx.Done():
+				return
+			}
+			last = &download
+			if download.Status == StatusCompleted || download.Status == StatusFailed {
+				return
+			}
+		}
+		if changed {
+			interval = min
+		} else if interval *= 2; interval > max {
+			interval = max
+		}
+		select {
+		case <-timeAfter(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// downloadChanged : Helper function telling whether a polled download
+// differs from the last state sent, nil before the first one
+func downloadChanged(last *Download, download Download) bool {
+	return last == nil || last.Status != download.Status || last.Progress != download.Progress
+}
+
+// WatchAllDownloads : Function to poll the download listing every interval,
+// sending it whenever a download was added, removed or changed status or
+// progress. Polling errors go to the second channel. Both channels are
+// closed once ctx is cancelled or the client is closed. The interval is
+// raised to the server's recommendation like for WatchDownload.
+func (s *DownloadsService) WatchAllDownloads(ctx context.Context, interval time.Duration) (<-chan []Download, <-chan error) {
+	updates := make(chan []Download)
+	errs := make(chan error, 1)
+	err := validateInterval(interval)
+	if err == nil {
+		err = s.client.startWatcher(ctx, func(ctx context.Context) {
+			defer close(updates)
+			defer close(errs)
+			s.client.watchAllDownloads(ctx, s.client.pollInterval(interval), updates, errs)
+		})
+	}
+	if err != nil {
+		errs <- err
+		close(updates)
+		close(errs)
+	}
+	return updates, errs
+}
+
+func (b *Client) watchAllDownloads(ctx context.Context, interval time.Duration, updates chan<- []Download, errs chan<- error) {
+	var last []Download
+	first := true
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		downloads, err := b.Downloads.GetDownloadsFiltered(ctx, DownloadFilter{})
+		if err != nil {
+			if !sendError(ctx, errs, err) {
+				return
+			}
+		} else if added, removed, changed := DiffDownloads(last, downloads); first || len(added)+len(removed)+len(changed) > 0 {
+			select {
+			case updates <- downloads:
+			case <-ctx.Done():
+				return
+			}
+			last, first = downloads, false
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// validateWatch : Helper function to check the arguments of WatchDownload
+func validateWatch(downloadID int, interval time.Duration) error {
+	if downloadID <= 0 {
+		return invalidParam("downloadID", "must be positive")
+	}
+	return validateInterval(interval)
+}
+
+// validateInterval : Helper function to check a polling interval
+func validateInterval(interval time.Duration) error {
+	if interval <= 0 {
+		return invalidParam("interval", "must be positive")
+	}
+	return nil
+}
+
+// sendError : Helper function to report a polling error, false once the
+// watcher should stop instead
+func sendError(ctx context.Context, errs chan<- error, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	select {
+	case errs <- err:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// startWatcher : Function to run a watcher loop in a goroutine that Close
+// stops and waits for. The loop gets a context that is also cancelled by
+// Close. A closed client returns ErrClosed without starting the loop.
+func (b *Client) startWatcher(ctx context.Context, loop func(ctx context.Context)) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrClosed
+	}
+	if b.done == nil {
+		b.done = make(chan struct{})
+	}
+	done := b.done
+	b.watchers.Add(1)
+	b.mu.Unlock()
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	go func() {
+		defer b.watchers.Done()
+		defer cancel()
+		go func() {
+			select {
+			case <-done:
+				cancel()
+			case <-watchCtx.Done():
+			}
+		}()
+		loop(watchCtx)
+	}()
+	return nil
+}
+
+// Close : Function to stop every watcher started on the client and wait for
+// them to exit, at most 5 seconds before giving up with ErrCloseTimeout.
+// Idle connections are closed as well.
+// Watchers started after Close stop right away with ErrClosed.
+func (b *Client) Close() error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		if b.done != nil {
+			close(b.done)
+		}
+	}
+	b.mu.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		b.watchers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(watcherShutdownTimeout):
+		return ErrCloseTimeout
+	}
+	if client, ok := b.doer.(*http.Client); ok {
+		client.CloseIdleConnections()
+	}
+	return nil
+}
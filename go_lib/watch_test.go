@@ -0,0 +1,226 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatchDownload(t *testing.T) {
+	var polls int32
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/download/7" {
+			t.Errorf("path = %s, want /api/download/7", r.URL.Path)
+		}
+		switch atomic.AddInt32(&polls, 1) {
+		case 1, 2:
+			w.Write([]byte(`{"id": 7, "status": "downloading", "progress": 10}`))
+		case 3:
+			w.Write([]byte(`{"id": 7, "status": "downloading", "progress": 60}`))
+		default:
+			w.Write([]byte(`{"id": 7, "status": "completed", "progress": 100}`))
+		}
+	}))
+
+	updates, errs := b.Downloads.WatchDownload(context.Background(), 7, time.Millisecond)
+	var progress []float64
+	for download := range updates {
+		progress = append(progress, download.Progress)
+	}
+	for err := range errs {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(progress) != 3 || progress[0] != 10 || progress[1] != 60 || progress[2] != 100 {
+		t.Errorf("progress = %v, want [10 60 100]", progress)
+	}
+}
+
+func TestWatchDownloadValidation(t *testing.T) {
+	b := newOfflineClient()
+	updates, errs := b.Downloads.WatchDownload(context.Background(), 0, time.Second)
+	if err := <-errs; !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("got %v, want ErrInvalidParams", err)
+	}
+	if _, ok := <-updates; ok {
+		t.Error("updates channel left open")
+	}
+}
+
+func TestWatchAllDownloads(t *testing.T) {
+	var polls int32
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&polls, 1) {
+		case 1, 2:
+			w.Write([]byte(`[{"id": 1, "status": "queued"}]`))
+		default:
+			w.Write([]byte(`[{"id": 1, "status": "downloading"}, {"id": 2, "status": "queued"}]`))
+		}
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	updates, errs := b.Downloads.WatchAllDownloads(ctx, time.Millisecond)
+	first := <-updates
+	second := <-updates
+	cancel()
+	for range updates {
+	}
+	for range errs {
+	}
+	if len(first) != 1 || len(second) != 2 || second[0].Status != StatusDownloading {
+		t.Errorf("updates = %+v, %+v", first, second)
+	}
+}
+
+func TestCloseStopsWatchers(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/downloads" {
+			w.Write([]byte(`[{"id": 1, "status": "downloading"}]`))
+			return
+		}
+		w.Write([]byte(`{"id": 1, "status": "downloading", "progress": 5}`))
+	}))
+	before := runtime.NumGoroutine()
+
+	var channels []<-chan error
+	for i := 0; i < 5; i++ {
+		// The updates are never read, so every watcher blocks sending
+		_, errs := b.Downloads.WatchDownload(context.Background(), 1, time.Millisecond)
+		channels = append(channels, errs)
+		_, errs = b.Downloads.WatchAllDownloads(context.Background(), time.Millisecond)
+		channels = append(channels, errs)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	for _, errs := range channels {
+		for err := range errs {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines: %d before watching, %d after Close", before, after)
+	}
+
+	_, errs := b.Downloads.WatchDownload(context.Background(), 1, time.Millisecond)
+	if err := <-errs; !errors.Is(err, ErrClosed) {
+		t.Errorf("watching a closed client: got %v, want ErrClosed", err)
+	}
+}
+
+func TestCloseTimeout(t *testing.T) {
+	timeout := watcherShutdownTimeout
+	watcherShutdownTimeout = 10 * time.Millisecond
+	t.Cleanup(func() { watcherShutdownTimeout = timeout })
+
+	b := newOfflineClient()
+	// A watcher that never returns
+	b.watchers.Add(1)
+	defer b.watchers.Done()
+	if err := b.Close(); !errors.Is(err, ErrCloseTimeout) {
+		t.Errorf("Close = %v, want ErrCloseTimeout", err)
+	}
+}
+
+func TestWatchAllDownloadsInterval(t *testing.T) {
+	b := newOfflineClient()
+	_, errs := b.Downloads.WatchAllDownloads(context.Background(), 0)
+	if err := <-errs; !errors.Is(err, ErrInvalidParams) || !strings.Contains(err.Error(), "interval") {
+		t.Errorf("got %v, want an invalid interval", err)
+	}
+}
+
+// fakeAfter : Helper function replacing timeAfter with a clock firing right
+// away, returning the delays waited for
+func fakeAfter(t *testing.T) func() []time.Duration {
+	var mu sync.Mutex
+	var delays []time.Duration
+	timeAfter = func(d time.Duration) <-chan time.Time {
+		mu.Lock()
+		delays = append(delays, d)
+		mu.Unlock()
+		fired := make(chan time.Time, 1)
+		fired <- time.Time{}
+		return fired
+	}
+	t.Cleanup(func() { timeAfter = time.After })
+	return func() []time.Duration {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]time.Duration(nil), delays...)
+	}
+}
+
+func TestWatchDownloadBackoff(t *testing.T) {
+	delays := fakeAfter(t)
+	// The state of the download at each poll
+	states := []string{
+		`{"id": 1, "status": "downloading", "progress": 0}`,
+		`{"id": 1, "status": "downloading", "progress": 0}`,
+		`{"id": 1, "status": "downloading", "progress": 0}`,
+		`{"id": 1, "status": "downloading", "progress": 0}`,
+		`{"id": 1, "status": "downloading", "progress": 0}`,
+		`{"id": 1, "status": "downloading", "progress": 40}`,
+		`{"id": 1, "status": "downloading", "progress": 40}`,
+		`{"id": 1, "status": "completed", "progress": 100}`,
+	}
+	var polls int32
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&polls, 1)) - 1
+		if n >= len(states) {
+			t.Errorf("poll %d after the download completed", n)
+			n = len(states) - 1
+		}
+		w.Write([]byte(states[n]))
+	}))
+
+	updates, errs := b.Downloads.WatchDownloadBackoff(context.Background(), 1, time.Second, 5*time.Second)
+	var progress []float64
+	for download := range updates {
+		progress = append(progress, download.Progress)
+	}
+	for err := range errs {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if want := []float64{0, 40, 100}; !reflect.DeepEqual(progress, want) {
+		t.Errorf("updates = %v, want %v", progress, want)
+	}
+	// Doubling while unchanged, capped at max, back to min after a change
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second, time.Second, 2 * time.Second}
+	if got := delays(); !reflect.DeepEqual(got, want) {
+		t.Errorf("delays = %v, want %v", got, want)
+	}
+}
+
+func TestWatchDownloadBackoffValidation(t *testing.T) {
+	b := newOfflineClient()
+	for _, bounds := range [][2]time.Duration{{0, time.Second}, {time.Second, time.Millisecond}} {
+		_, errs := b.Downloads.WatchDownloadBackoff(context.Background(), 1, bounds[0], bounds[1])
+		if err := <-errs; !errors.Is(err, ErrInvalidParams) {
+			t.Errorf("min %v, max %v: got %v, want ErrInvalidParams", bounds[0], bounds[1], err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// Events a webhook can subscribe to
+const (
+	EventDownloadAdded     = "download.added"
+	EventDownloadCompleted = "download.completed"
+	EventDownloadFailed    = "download.failed"
+	EventUserApproved      = "user.approved"
+	EventUserBlocked       = "user.blocked"
+)
+
+var webhookEvents = map[string]bool{
+	EventDownloadAdded:     true,
+	EventDownloadCompleted: true,
+	EventDownloadFailed:    true,
+	EventUserApproved:      true,
+	EventUserBlocked:       true,
+}
+
+// Webhook : Callback registered on the server for a set of events
+type Webhook struct {
+	ID     int      `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+// RegisterWebhook : Function to have the server call callbackURL when one of
+// the given events happens
+func (s *AdminService) RegisterWebhook(ctx context.Context, callbackURL string, events []string) (Webhook, error) {
+	u, err := url.Parse(callbackURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Webhook{}, invalidParam("url", "must be an absolute http(s) URL")
+	}
+	if len(events) == 0 {
+		return Webhook{}, invalidParam("events", "must not be empty")
+	}
+	for _, event := range events {
+		if !webhookEvents[event] {
+			return Webhook{}, invalidParam("events", "contains unknown event "+strconv.Quote(event))
+		}
+	}
+
+	if err := s.client.requireFeature(ctx, FeatureWebhooks); err != nil {
+		return Webhook{}, err
+	}
+	var webhook Webhook
+	body := Webhook{URL: callbackURL, Events: events}
+	if err := s.client.do(ctx, "POST", "/api/webhooks", body, &webhook); err != nil {
+		return Webhook{}, err
+	}
+	return webhook, nil
+}
+
+// ListWebhooks : Function to get the webhooks registered on the server
+func (s *AdminService) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	if err := s.client.requireFeature(ctx, FeatureWebhooks); err != nil {
+		return nil, err
+	}
+	var webhooks []Webhook
+	if err := s.client.do(ctx, "GET", "/api/webhooks", nil, &webhooks); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook : Function to remove a registered webhook
+func (s *AdminService) DeleteWebhook(ctx context.Context, id int) error {
+	if id <= 0 {
+		return invalidParam("id", "must be positive")
+	}
+	if err := s.client.requireFeature(ctx, FeatureWebhooks); err != nil {
+		return err
+	}
+	return s.client.do(ctx, "DELETE", "/api/webhooks/"+strconv.Itoa(id), nil, nil)
+}
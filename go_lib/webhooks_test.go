@@ -0,0 +1,137 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// webhookServer : In-memory stand-in for the server's webhook endpoints
+type webhookServer struct {
+	mu       sync.Mutex
+	nextID   int
+	webhooks map[int]Webhook
+}
+
+func (s *webhookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/api/webhooks":
+		var webhook Webhook
+		if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.nextID++
+		webhook.ID = s.nextID
+		s.webhooks[webhook.ID] = webhook
+		json.NewEncoder(w).Encode(webhook)
+	case r.Method == "GET" && r.URL.Path == "/api/webhooks":
+		webhooks := []Webhook{}
+		for id := 1; id <= s.nextID; id++ {
+			if webhook, ok := s.webhooks[id]; ok {
+				webhooks = append(webhooks, webhook)
+			}
+		}
+		json.NewEncoder(w).Encode(webhooks)
+	case r.Method == "DELETE" && strings.HasPrefix(r.URL.Path, "/api/webhooks/"):
+		id, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/webhooks/"))
+		if _, ok := s.webhooks[id]; !ok {
+			http.Error(w, `{"error": "not found"}`, http.StatusNotFound)
+			return
+		}
+		delete(s.webhooks, id)
+		w.Write([]byte(`{"status": "success"}`))
+	default:
+		http.Error(w, "unexpected request", http.StatusTeapot)
+	}
+}
+
+func TestWebhookLifecycle(t *testing.T) {
+	b := newTestClient(t, &webhookServer{webhooks: map[int]Webhook{}})
+	ctx := context.Background()
+
+	first, err := b.Admin.RegisterWebhook(ctx, "https://hooks.scorelab.org/bassa", []string{EventDownloadCompleted})
+	if err != nil {
+		t.Fatalf("RegisterWebhook: %v", err)
+	}
+	second, err := b.Admin.RegisterWebhook(ctx, "http://localhost:8080/cb", []string{EventDownloadAdded, EventUserApproved})
+	if err != nil {
+		t.Fatalf("RegisterWebhook: %v", err)
+	}
+	if first.ID == 0 || second.ID == first.ID {
+		t.Fatalf("ids = %d, %d", first.ID, second.ID)
+	}
+
+	webhooks, err := b.Admin.ListWebhooks(ctx)
+	if err != nil {
+		t.Fatalf("ListWebhooks: %v", err)
+	}
+	if len(webhooks) != 2 || webhooks[1].URL != "http://localhost:8080/cb" || len(webhooks[1].Events) != 2 {
+		t.Fatalf("ListWebhooks = %+v", webhooks)
+	}
+
+	if err := b.Admin.DeleteWebhook(ctx, first.ID); err != nil {
+		t.Fatalf("DeleteWebhook: %v", err)
+	}
+	webhooks, err = b.Admin.ListWebhooks(ctx)
+	if err != nil {
+		t.Fatalf("ListWebhooks: %v", err)
+	}
+	if len(webhooks) != 1 || webhooks[0].ID != second.ID {
+		t.Fatalf("ListWebhooks after delete = %+v", webhooks)
+	}
+	if err := b.Admin.DeleteWebhook(ctx, first.ID); err == nil {
+		t.Error("deleting a removed webhook returned nil error")
+	}
+}
+
+func TestRegisterWebhookValidation(t *testing.T) {
+	b := newOfflineClient()
+	tests := []struct {
+		name   string
+		url    string
+		events []string
+	}{
+		{"relative url", "/callback", []string{EventDownloadAdded}},
+		{"bad scheme", "ftp://scorelab.org/cb", []string{EventDownloadAdded}},
+		{"no events", "https://scorelab.org/cb", nil},
+		{"unknown event", "https://scorelab.org/cb", []string{"download.exploded"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := b.Admin.RegisterWebhook(context.Background(), tt.url, tt.events)
+			if !errors.Is(err, ErrInvalidParams) {
+				t.Errorf("got %v, want ErrInvalidParams", err)
+			}
+		})
+	}
+}
+
+func TestWebhooksForbidden(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	if _, err := b.Admin.ListWebhooks(context.Background()); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("got %v, want ErrForbidden", err)
+	}
+}
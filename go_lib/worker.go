@@ -0,0 +1,59 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+)
+
+// Start : Function to start the server's download worker, resuming the
+// queue. It sends the key set by WithServerKey, the default key of the
+// server otherwise, and needs an admin login.
+func (s *DownloadsService) Start(ctx context.Context) error {
+	return s.client.controlWorker(ctx, "/api/download/start")
+}
+
+// Kill : Function to stop the server's download worker, pausing the queue
+// until Start. It authenticates like Start.
+func (s *DownloadsService) Kill(ctx context.Context) error {
+	return s.client.controlWorker(ctx, "/api/download/kill")
+}
+
+// StartDownload : Function to start, or resume, a single download, leaving
+// the rest of the queue as it is. Servers without per-download control
+// return ErrNotSupported.
+func (s *DownloadsService) StartDownload(ctx context.Context, downloadID int) error {
+	if downloadID <= 0 {
+		return invalidParam("downloadID", "must be positive")
+	}
+	return s.client.controlWorker(ctx, downloadEndpoint(downloadID, "start"))
+}
+
+// KillDownload : Function to stop a single download, see StartDownload
+func (s *DownloadsService) KillDownload(ctx context.Context, downloadID int) error {
+	if downloadID <= 0 {
+		return invalidParam("downloadID", "must be positive")
+	}
+	return s.client.controlWorker(ctx, downloadEndpoint(downloadID, "kill"))
+}
+
+// controlWorker : Function to send a worker control request, authenticated
+// with the key of the server
+func (b *Client) controlWorker(ctx context.Context, endpoint string) error {
+	response, err := b.roundTrip(ctx, b.clientFor(ctx), "GET", endpoint, nil, serverKeyHeader(b.serverKey))
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
@@ -0,0 +1,78 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License
+
+package bassa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestStartAndKill(t *testing.T) {
+	var paths []string
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Key") != "s3cret" {
+			t.Errorf("key = %q, want s3cret", r.Header.Get("Key"))
+		}
+		paths = append(paths, r.URL.Path)
+		w.Write([]byte(`{"status": "ok"}`))
+	}), WithServerKey("s3cret"))
+	ctx := context.Background()
+	if err := b.Downloads.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if err := b.Downloads.Kill(ctx); err != nil {
+		t.Fatalf("Kill: %v", err)
+	}
+	if err := b.Downloads.StartDownload(ctx, 3); err != nil {
+		t.Fatalf("StartDownload: %v", err)
+	}
+	if err := b.Downloads.KillDownload(ctx, 3); err != nil {
+		t.Fatalf("KillDownload: %v", err)
+	}
+	want := []string{"/api/download/start", "/api/download/kill", "/api/download/3/start", "/api/download/3/kill"}
+	if len(paths) != len(want) {
+		t.Fatalf("requested %v, want %v", paths, want)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Errorf("request %d = %s, want %s", i, paths[i], want[i])
+		}
+	}
+}
+
+func TestStartDefaultKey(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Key") != defaultServerKey {
+			t.Errorf("key = %q, want the default", r.Header.Get("Key"))
+		}
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	if err := b.Downloads.Start(context.Background()); !errors.Is(err, ErrForbidden) {
+		t.Errorf("got %v, want ErrForbidden", err)
+	}
+}
+
+func TestKillDownloadNotSupported(t *testing.T) {
+	b := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	if err := b.Downloads.KillDownload(context.Background(), 3); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("got %v, want ErrNotSupported", err)
+	}
+	if err := b.Downloads.StartDownload(context.Background(), 0); !errors.Is(err, ErrInvalidParams) {
+		t.Errorf("id 0: got %v, want ErrInvalidParams", err)
+	}
+}